@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const cacheUsage = `Usage: addon cache [-host url] [-token token] <command> [arguments]
+
+Administers the cache of a running addon through its admin endpoints.
+
+Commands:
+  keys [prefix] [limit]   List keys starting with prefix
+  get <key>               Show an entry value and TTL
+  delete <key>            Delete an entry
+  delete-prefix <prefix>  Delete all the entries starting with prefix
+  export <file>           Write a backup to file, - for stdout
+  import <file>           Load a backup from file, - for stdin
+`
+
+// runCacheCommand runs a cache administration subcommand against the admin endpoints of a running addon and returns the process exit code.
+func runCacheCommand(cfg config, args []string) int {
+	flags := flag.NewFlagSet("cache", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), cacheUsage) }
+	host := flags.String("host", cfg.AddonHost, "addon base URL")
+	token := flags.String("token", cfg.AdminToken, "admin token")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	client := &cacheAdminClient{
+		httpClient: http.DefaultClient,
+		host:       strings.TrimRight(*host, "/"),
+		token:      *token,
+	}
+
+	var err error
+	switch {
+	case args[0] == "keys" && len(args) <= 3:
+		query := url.Values{}
+		if len(args) > 1 {
+			query.Set("prefix", args[1])
+		}
+		if len(args) > 2 {
+			query.Set("limit", args[2])
+		}
+		err = client.do(http.MethodGet, "/admin/cache/keys", query, nil, os.Stdout)
+	case args[0] == "get" && len(args) == 2:
+		err = client.do(http.MethodGet, "/admin/cache/entry", url.Values{"key": {args[1]}}, nil, os.Stdout)
+	case args[0] == "delete" && len(args) == 2:
+		err = client.do(http.MethodDelete, "/admin/cache/keys", url.Values{"key": {args[1]}}, nil, os.Stdout)
+	case args[0] == "delete-prefix" && len(args) == 2:
+		err = client.do(http.MethodDelete, "/admin/cache/keys", url.Values{"prefix": {args[1]}}, nil, os.Stdout)
+	case args[0] == "export" && len(args) == 2:
+		err = withFile(args[1], os.Stdout, os.Create, func(f *os.File) error {
+			return client.do(http.MethodGet, "/admin/cache/backup", nil, nil, f)
+		})
+	case args[0] == "import" && len(args) == 2:
+		err = withFile(args[1], os.Stdin, os.Open, func(f *os.File) error {
+			return client.do(http.MethodPost, "/admin/cache/backup", nil, f, os.Stdout)
+		})
+	default:
+		flags.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// withFile calls fn with the named file opened by open, or with std when the name is "-".
+func withFile(name string, std *os.File, open func(string) (*os.File, error), fn func(f *os.File) error) error {
+	if name == "-" {
+		return fn(std)
+	}
+
+	f, err := open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+
+	err = fn(f)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		return fmt.Errorf("failed to close %s: %w", name, closeErr)
+	}
+
+	return err
+}
+
+type cacheAdminClient struct {
+	httpClient *http.Client
+	host       string
+	token      string
+}
+
+// do sends a request to an admin endpoint and copies the response body to out.
+func (c *cacheAdminClient) do(method, path string, query url.Values, body io.Reader, out io.Writer) error {
+	endpoint := c.host + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	if body != nil {
+		// The body is owned by the caller, keep http.Client from closing it
+		body = io.NopCloser(body)
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to http.NewRequest: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to http.Client.Do: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s %s: %s", method, path, res.Status)
+	}
+
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		var v any
+		if err = json.NewDecoder(res.Body).Decode(&v); err != nil {
+			return fmt.Errorf("failed to json.Decoder.Decode: %w", err)
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	if _, err = io.Copy(out, res.Body); err != nil {
+		return fmt.Errorf("failed to io.Copy: %w", err)
+	}
+
+	return nil
+}
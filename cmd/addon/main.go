@@ -2,15 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"io"
 	"io/fs"
-	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -20,9 +20,18 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/ogero/stremio-subdivx/frontend"
 	"github.com/ogero/stremio-subdivx/internal"
+	"github.com/ogero/stremio-subdivx/internal/animeid"
 	"github.com/ogero/stremio-subdivx/internal/cache"
 	"github.com/ogero/stremio-subdivx/internal/common"
+	"github.com/ogero/stremio-subdivx/internal/feedback"
 	"github.com/ogero/stremio-subdivx/internal/loki"
+	"github.com/ogero/stremio-subdivx/internal/opensubtitles"
+	"github.com/ogero/stremio-subdivx/internal/provider"
+	"github.com/ogero/stremio-subdivx/internal/ratelimit"
+	"github.com/ogero/stremio-subdivx/internal/subtitleurl"
+	"github.com/ogero/stremio-subdivx/internal/titlemeta"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/ogero/stremio-subdivx/internal/videohash"
 	"github.com/ogero/stremio-subdivx/pkg/stremio"
 	"github.com/ogero/stremio-subdivx/pkg/subx"
 	slogchi "github.com/samber/slog-chi"
@@ -32,14 +41,65 @@ import (
 )
 
 type config struct {
-	AddonHost            string `env:"ADDON_HOST" envDefault:"http://127.0.0.1:3593"`
-	ServerListenAddr     string `env:"SERVER_LISTEN_ADDR" envDefault:":3593"`
-	ServiceName          string `env:"SERVICE_NAME" envDefault:"stremio-subdivx"`
-	ServiceEnvironment   string `env:"SERVICE_ENVIRONMENT" envDefault:"lcl"`
-	ServiceVersion       string `env:"SERVICE_VERSION" envDefault:"v0.0.12"`
-	OtelExporterEndpoint string `env:"OTEL_EXPORTER_ENDPOINT" envDefault:"127.0.0.1:4317"`
-	LokiHost             string `env:"LOKI_HOST" envDefault:"http://127.0.0.1:3100"`
-	StatsWSChannel       string `env:"STATS_WS_CHANNEL" envDefault:"stremio-subdivx:stats"`
+	AddonHost                    string        `env:"ADDON_HOST" envDefault:"http://127.0.0.1:3593"`
+	ServerListenAddr             string        `env:"SERVER_LISTEN_ADDR" envDefault:":3593"`
+	ServiceName                  string        `env:"SERVICE_NAME" envDefault:"stremio-subdivx"`
+	ServiceEnvironment           string        `env:"SERVICE_ENVIRONMENT" envDefault:"lcl"`
+	ServiceVersion               string        `env:"SERVICE_VERSION" envDefault:"v0.0.12"`
+	OtelExporterEndpoint         string        `env:"OTEL_EXPORTER_ENDPOINT" envDefault:"127.0.0.1:4317"`
+	LokiHost                     string        `env:"LOKI_HOST" envDefault:"http://127.0.0.1:3100"`
+	ManifestFile                 string        `env:"MANIFEST_FILE"`
+	ManifestID                   string        `env:"MANIFEST_ID"`
+	ManifestName                 string        `env:"MANIFEST_NAME"`
+	ManifestDescription          string        `env:"MANIFEST_DESCRIPTION"`
+	ManifestLogo                 string        `env:"MANIFEST_LOGO"`
+	ManifestBackground           string        `env:"MANIFEST_BACKGROUND"`
+	ManifestContactEmail         string        `env:"MANIFEST_CONTACT_EMAIL"`
+	StatsWSChannel               string        `env:"STATS_WS_CHANNEL" envDefault:"stremio-subdivx:stats"`
+	UserConfigKeys               string        `env:"USER_CONFIG_KEYS"`
+	UserConfigAcceptLegacy       bool          `env:"USER_CONFIG_ACCEPT_LEGACY" envDefault:"true"`
+	AdminToken                   string        `env:"ADMIN_TOKEN"`
+	SubXQuotaLimit               int           `env:"SUBX_QUOTA_LIMIT" envDefault:"0"`
+	SubXQuotaWindow              time.Duration `env:"SUBX_QUOTA_WINDOW" envDefault:"1h"`
+	SubXProviderTimeout          time.Duration `env:"SUBX_PROVIDER_TIMEOUT" envDefault:"20s"`
+	LocalSubtitlesDir            string        `env:"LOCAL_SUBTITLES_DIR"`
+	LocalSubtitlesRefresh        time.Duration `env:"LOCAL_SUBTITLES_REFRESH" envDefault:"1m"`
+	LocalProviderTimeout         time.Duration `env:"LOCAL_PROVIDER_TIMEOUT" envDefault:"2s"`
+	TrustedProxies               string        `env:"TRUSTED_PROXIES"`
+	RateLimitSearchPerUser       string        `env:"RATE_LIMIT_SEARCH_PER_USER" envDefault:"30/m"`
+	RateLimitSearchPerAPIKey     string        `env:"RATE_LIMIT_SEARCH_PER_API_KEY" envDefault:"30/m"`
+	RateLimitSearchPerIP         string        `env:"RATE_LIMIT_SEARCH_PER_IP" envDefault:"60/m"`
+	RateLimitDownloadPerUser     string        `env:"RATE_LIMIT_DOWNLOAD_PER_USER" envDefault:"30/m"`
+	RateLimitDownloadPerAPIKey   string        `env:"RATE_LIMIT_DOWNLOAD_PER_API_KEY" envDefault:"30/m"`
+	RateLimitDownloadPerIP       string        `env:"RATE_LIMIT_DOWNLOAD_PER_IP" envDefault:"60/m"`
+	SubtitleURLKey               string        `env:"SUBTITLE_URL_KEY"`
+	AnimeMappingFile             string        `env:"ANIME_MAPPING_FILE"`
+	AnimeMappingRefresh          time.Duration `env:"ANIME_MAPPING_REFRESH" envDefault:"1h"`
+	TitleMetadataFile            string        `env:"TITLE_METADATA_FILE"`
+	TitleMetadataURL             string        `env:"TITLE_METADATA_URL" envDefault:"https://v3-cinemeta.strem.io"`
+	FeedbackRanking              bool          `env:"FEEDBACK_RANKING" envDefault:"true"`
+	FeedbackHalfLife             time.Duration `env:"FEEDBACK_HALF_LIFE" envDefault:"336h"`
+	VideoHashTTL                 time.Duration `env:"VIDEO_HASH_TTL" envDefault:"720h"`
+	OpenSubtitlesFacade          bool          `env:"OPENSUBTITLES_FACADE" envDefault:"false"`
+	OpenSubtitlesFileTTL         time.Duration `env:"OPENSUBTITLES_FILE_TTL" envDefault:"24h"`
+	SubtitleURLTTL               time.Duration `env:"SUBTITLE_URL_TTL" envDefault:"6h"`
+	FallbackAPIKey               string        `env:"SUBX_FALLBACK_API_KEY"`
+	FallbackAPIKeyFile           string        `env:"SUBX_FALLBACK_API_KEY_FILE"`
+	FallbackDailyQuotaPerIP      int           `env:"SUBX_FALLBACK_DAILY_QUOTA_PER_IP" envDefault:"50"`
+	FallbackDailyQuotaPerInstall int           `env:"SUBX_FALLBACK_DAILY_QUOTA_PER_INSTALL" envDefault:"50"`
+	CacheBackend                 string        `env:"CACHE_BACKEND" envDefault:"badger"`
+	CacheCodec                   string        `env:"CACHE_CODEC" envDefault:"json"`
+	CacheCompressionThreshold    int           `env:"CACHE_COMPRESSION_THRESHOLD" envDefault:"1024"`
+	CacheBadgerPath              string        `env:"CACHE_BADGER_PATH" envDefault:".cache"`
+	CacheBadgerMemTableSize      int64         `env:"CACHE_BADGER_MEM_TABLE_SIZE" envDefault:"67108864"`
+	CacheBadgerNumMemtables      int           `env:"CACHE_BADGER_NUM_MEMTABLES" envDefault:"5"`
+	CacheBadgerGCInterval        time.Duration `env:"CACHE_BADGER_GC_INTERVAL" envDefault:"10m"`
+	CacheBadgerGCDiscardRatio    float64       `env:"CACHE_BADGER_GC_DISCARD_RATIO" envDefault:"0.5"`
+	CacheBadgerMaxSize           int64         `env:"CACHE_BADGER_MAX_SIZE" envDefault:"0"`
+	CacheMemoryMaxBytes          int           `env:"CACHE_MEMORY_MAX_BYTES" envDefault:"67108864"`
+	CacheRedisAddr               string        `env:"CACHE_REDIS_ADDR" envDefault:"127.0.0.1:6379"`
+	CacheRedisPassword           string        `env:"CACHE_REDIS_PASSWORD"`
+	CacheRedisDB                 int           `env:"CACHE_REDIS_DB" envDefault:"0"`
 }
 
 func main() {
@@ -52,11 +112,20 @@ func main() {
 		panic(fmt.Errorf("failed to env.ParseAs: %w", err))
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		os.Exit(runCacheCommand(cfg, os.Args[2:]))
+	}
+
 	loggerShutdown, err := common.InitLogger(cfg.ServiceName, cfg.ServiceVersion, cfg.ServiceEnvironment, cfg.OtelExporterEndpoint)
 	if err != nil {
 		panic(fmt.Errorf("failed to logger.InitLogger: %w", err))
 	}
 
+	// The background jobs are stopped on shutdown, before the cache backend they may use is closed
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	var background sync.WaitGroup
+
 	stremioManifest := &stremio.Manifest{
 		ID:          "ar.xor.subdivx.go",
 		Version:     strings.TrimLeft(cfg.ServiceVersion, "v"),
@@ -65,38 +134,177 @@ func main() {
 		Types:       []string{"movie", "series"},
 		Catalogs:    []stremio.CatalogItem{},
 		IDPrefixes:  []string{"tt"},
-		Resources:   []string{"subtitles"},
+		Resources:   []stremio.Resource{{Name: "subtitles"}},
 		BehaviorHints: stremio.BehaviorHints{
 			Configurable:          true,
 			ConfigurationRequired: true,
 		},
 	}
 
-	err = cache.InitCache(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	animeIDs, err := newAnimeIDResolver(backgroundCtx, &background, cfg)
 	if err != nil {
-		common.Log.Error("Failed to cache.InitCache", "err", err)
+		common.Log.Error("Failed to newAnimeIDResolver", "err", err)
+		os.Exit(1)
+	}
+	stremioManifest.IDPrefixes = append(stremioManifest.IDPrefixes, animeid.Prefixes...)
+
+	if err = overrideManifest(stremioManifest, cfg); err != nil {
+		common.Log.Error("Failed to overrideManifest", "err", err)
 		os.Exit(1)
 	}
 
+	cacheBackend, err := newCacheBackend(cfg)
+	if err != nil {
+		common.Log.Error("Failed to newCacheBackend", "err", err)
+		os.Exit(1)
+	}
+	cacheCodec, err := cache.CodecByName(cfg.CacheCodec)
+	if err != nil {
+		common.Log.Error("Failed to cache.CodecByName", "err", err)
+		os.Exit(1)
+	}
+	cache.InitCache(cacheBackend, cache.Encoding{
+		Codec:                cacheCodec,
+		CompressionThreshold: cfg.CacheCompressionThreshold,
+	})
+
 	instrumentationShutdown, err := common.InitInstrumentation(cfg.ServiceName, cfg.ServiceVersion, cfg.ServiceEnvironment, cfg.OtelExporterEndpoint)
 	if err != nil {
 		common.Log.Error("Failed to common.InitInstrumentation", "err", err)
 		os.Exit(1)
 	}
 
+	subxClient := subx.NewSubX()
+	subxClient.Quotas = subx.NewQuotaTracker(cfg.SubXQuotaLimit, cfg.SubXQuotaWindow)
+
+	providers := provider.NewAggregator()
+	if err = providers.Register(provider.NewSubX(subxClient), cfg.SubXProviderTimeout); err != nil {
+		common.Log.Error("Failed to provider.Aggregator.Register(subx)", "err", err)
+		os.Exit(1)
+	}
+	if cfg.LocalSubtitlesDir != "" {
+		local, err := provider.NewLocal(cfg.LocalSubtitlesDir)
+		if err != nil {
+			common.Log.Error("Failed to provider.NewLocal", "err", err)
+			os.Exit(1)
+		}
+		for _, err = range local.Skipped() {
+			common.Log.Warn("Failed to provider.NewLocal", "err", err)
+		}
+		if err = providers.Register(local, cfg.LocalProviderTimeout); err != nil {
+			common.Log.Error("Failed to provider.Aggregator.Register(local)", "err", err)
+			os.Exit(1)
+		}
+		if cfg.LocalSubtitlesRefresh > 0 {
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				local.Watch(backgroundCtx, cfg.LocalSubtitlesRefresh, func(err error) {
+					common.Log.Warn("Failed to provider.Local.Watch", "err", err)
+				})
+			}()
+		}
+		common.Log.Info("Local subtitles provider enabled", "dir", cfg.LocalSubtitlesDir, "subtitles", local.Len())
+	}
+
 	stremioService := internal.NewStremioService(
 		cfg.StatsWSChannel,
-		subx.NewSubX(),
+		subxClient,
+		providers,
 		loki.NewLoki(cfg.LokiHost),
 	)
 
+	stremioService.VideoHashes = videohash.NewStore(cacheBackend, cfg.VideoHashTTL)
+	stremioService.Feedback = feedback.NewStore(cacheBackend, cfg.FeedbackHalfLife)
+	stremioService.FeedbackRanking = cfg.FeedbackRanking
+	stremioService.Titles, err = newTitleResolver(cfg)
+	if err != nil {
+		common.Log.Error("Failed to newTitleResolver", "err", err)
+		os.Exit(1)
+	}
+
 	go stremioService.StartPollingStats(1 * time.Minute)
 
-	app, err := internal.NewApp(stremioService, stremioManifest, cfg.AddonHost)
+	userConfigKeys, err := userconfig.ParseKeys(cfg.UserConfigKeys)
+	if err != nil {
+		common.Log.Error("Failed to userconfig.ParseKeys", "err", err)
+		os.Exit(1)
+	}
+	switch {
+	case len(userConfigKeys) == 0 && !cfg.UserConfigAcceptLegacy:
+		common.Log.Error("No USER_CONFIG_KEYS configured while USER_CONFIG_ACCEPT_LEGACY=false, generate one with `openssl rand -base64 32`")
+		os.Exit(1)
+	case len(userConfigKeys) == 0:
+		common.Log.Warn("INSECURE: No USER_CONFIG_KEYS configured, user configs are issued unencrypted and expose the SubX API keys in the addon URLs, generate one with `openssl rand -base64 32`")
+	case cfg.UserConfigAcceptLegacy:
+		// The installs made before the keys were configured keep working during the migration
+		common.Log.Warn("Unencrypted legacy user configs are still accepted, set USER_CONFIG_ACCEPT_LEGACY=false once the installs are migrated")
+	}
+
+	userConfigSealer, err := userconfig.NewSealer(userConfigKeys, cfg.UserConfigAcceptLegacy)
+	if err != nil {
+		common.Log.Error("Failed to userconfig.NewSealer", "err", err)
+		os.Exit(1)
+	}
+
+	subtitleURLKey, err := base64.StdEncoding.DecodeString(cfg.SubtitleURLKey)
+	if err != nil {
+		common.Log.Error("Failed to base64.StdEncoding.DecodeString(SUBTITLE_URL_KEY)", "err", err)
+		os.Exit(1)
+	}
+	if len(subtitleURLKey) == 0 {
+		common.Log.Warn("No SUBTITLE_URL_KEY configured, signed subtitle URLs won't survive restarts nor work across replicas")
+	}
+
+	subtitleURLSigner, err := subtitleurl.NewSigner(subtitleURLKey, cfg.SubtitleURLTTL)
+	if err != nil {
+		common.Log.Error("Failed to subtitleurl.NewSigner", "err", err)
+		os.Exit(1)
+	}
+
+	// User configs are kept twice as long as the signed URLs that reference them, so they outlive any valid URL
+	userConfigStore := userconfig.NewStore(cacheBackend, 2*cfg.SubtitleURLTTL)
+
+	trustedProxies, err := ratelimit.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		common.Log.Error("Failed to ratelimit.ParseTrustedProxies", "err", err)
+		os.Exit(1)
+	}
+
+	fallbackAPIKey := cfg.FallbackAPIKey
+	if cfg.FallbackAPIKeyFile != "" {
+		b, err := os.ReadFile(cfg.FallbackAPIKeyFile)
+		if err != nil {
+			common.Log.Error("Failed to os.ReadFile(SUBX_FALLBACK_API_KEY_FILE)", "err", err)
+			os.Exit(1)
+		}
+		fallbackAPIKey = string(b)
+	}
+	fallbackKey := internal.NewFallbackKey(strings.TrimSpace(fallbackAPIKey), cfg.FallbackDailyQuotaPerIP, cfg.FallbackDailyQuotaPerInstall, cacheBackend)
+	if fallbackKey != nil {
+		common.Log.Info("Fallback SubX API key enabled", "daily_quota_per_ip", cfg.FallbackDailyQuotaPerIP, "daily_quota_per_install", cfg.FallbackDailyQuotaPerInstall)
+	}
+
+	app, err := internal.NewApp(stremioService, stremioManifest, cfg.AddonHost, cfg.AdminToken, userConfigSealer, userConfigStore, subtitleURLSigner, fallbackKey, trustedProxies, animeIDs)
 	if err != nil {
 		common.Log.Error("Failed to internal.NewApp", "err", err)
 		os.Exit(1)
 	}
+	if cfg.OpenSubtitlesFacade {
+		app.OpenSubtitlesFiles = opensubtitles.NewStore(cacheBackend, cfg.OpenSubtitlesFileTTL)
+		common.Log.Info("OpenSubtitles compatible facade enabled", "file_ttl", cfg.OpenSubtitlesFileTTL)
+	}
+
+	searchRateLimit, err := newRateLimitMiddleware("search", cfg.RateLimitSearchPerUser, cfg.RateLimitSearchPerAPIKey, cfg.RateLimitSearchPerIP, trustedProxies, app.UserRateLimitKey)
+	if err != nil {
+		common.Log.Error("Failed to newRateLimitMiddleware(search)", "err", err)
+		os.Exit(1)
+	}
+	downloadRateLimit, err := newRateLimitMiddleware("download", cfg.RateLimitDownloadPerUser, cfg.RateLimitDownloadPerAPIKey, cfg.RateLimitDownloadPerIP, trustedProxies, app.UserRateLimitKey)
+	if err != nil {
+		common.Log.Error("Failed to newRateLimitMiddleware(download)", "err", err)
+		os.Exit(1)
+	}
 
 	distFS, err := fs.Sub(fs.FS(frontend.Dist), "dist")
 	if err != nil {
@@ -113,9 +321,10 @@ func main() {
 	r.Use(otelRoutePattern)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
 		AllowedHeaders: []string{
 			"Content-Type",
+			"Api-Key",
 			"X-Requested-With",
 			"Accept",
 			"Accept-Language",
@@ -132,9 +341,31 @@ func main() {
 	}))
 	r.Handle("GET /manifest.json", http.HandlerFunc(app.ManifestHandler))
 	r.Handle("GET /{userConfig}/manifest.json", http.HandlerFunc(app.ManifestHandler))
-	r.Handle("GET /{userConfig}/subtitles/{type}/{id}/*", http.HandlerFunc(app.SubtitlesHandler))
-	r.Handle("GET /{userConfig}/subx/{id}", http.HandlerFunc(app.SubXSubtitleHandler))
+	r.With(app.UserConfigMiddleware, searchRateLimit).Handle("GET /subtitles/{type}/{id}", http.HandlerFunc(app.SubtitlesHandler))
+	r.With(app.UserConfigMiddleware, searchRateLimit).Handle("GET /subtitles/{type}/{id}/*", http.HandlerFunc(app.SubtitlesHandler))
+	r.With(app.UserConfigMiddleware, searchRateLimit).Handle("GET /{userConfig}/subtitles/{type}/{id}", http.HandlerFunc(app.SubtitlesHandler))
+	r.With(app.UserConfigMiddleware, searchRateLimit).Handle("GET /{userConfig}/subtitles/{type}/{id}/*", http.HandlerFunc(app.SubtitlesHandler))
+	r.With(app.UserConfigMiddleware).Handle("GET /{userConfig}/quota.json", http.HandlerFunc(app.QuotaHandler))
+	r.With(app.UserConfigMiddleware, downloadRateLimit).Handle("GET /subx/{id}", http.HandlerFunc(app.SubXSubtitleHandler))
+	r.With(app.UserConfigMiddleware, downloadRateLimit).Handle("GET /{userConfig}/subx/{id}", http.HandlerFunc(app.SubXSubtitleHandler))
+	r.With(downloadRateLimit).Handle("GET /subtitle/{token}/{id}", http.HandlerFunc(app.SignedSubtitleHandler))
+	r.With(app.UserConfigMiddleware, searchRateLimit).Handle("GET /api/search", http.HandlerFunc(app.SearchHandler))
+	r.With(app.UserConfigMiddleware, searchRateLimit).Handle("GET /{userConfig}/api/search", http.HandlerFunc(app.SearchHandler))
+	r.With(app.UserConfigMiddleware, searchRateLimit).Handle("GET /api/v1/subtitles", http.HandlerFunc(app.SubtitlesV1Handler))
+	r.With(app.UserConfigMiddleware, searchRateLimit).Handle("GET /{userConfig}/api/v1/subtitles", http.HandlerFunc(app.SubtitlesV1Handler))
+	r.Handle("GET /api/v1/openapi.json", http.HandlerFunc(app.OpenAPIHandler))
+	if app.OpenSubtitlesFiles != nil {
+		r.With(app.OpenSubtitlesAPIKeyMiddleware, searchRateLimit).Handle("GET /opensubtitles/api/v1/subtitles", http.HandlerFunc(app.OpenSubtitlesSearchHandler))
+		r.With(app.OpenSubtitlesAPIKeyMiddleware, downloadRateLimit).Handle("POST /opensubtitles/api/v1/download", http.HandlerFunc(app.OpenSubtitlesDownloadHandler))
+	}
 	r.Handle("GET /ws", http.HandlerFunc(app.WebsocketHandler))
+	r.Handle("POST /api/config", http.HandlerFunc(app.ConfigHandler))
+	r.With(searchRateLimit).Handle("POST /api/config/validate", http.HandlerFunc(app.ConfigValidateHandler))
+	r.With(app.AdminMiddleware).Handle("GET /admin/cache/keys", http.HandlerFunc(app.CacheKeysHandler))
+	r.With(app.AdminMiddleware).Handle("DELETE /admin/cache/keys", http.HandlerFunc(app.CacheDeleteHandler))
+	r.With(app.AdminMiddleware).Handle("GET /admin/cache/entry", http.HandlerFunc(app.CacheEntryHandler))
+	r.With(app.AdminMiddleware).Handle("GET /admin/cache/backup", http.HandlerFunc(app.CacheBackupHandler))
+	r.With(app.AdminMiddleware).Handle("POST /admin/cache/backup", http.HandlerFunc(app.CacheRestoreHandler))
 	r.Handle("GET /configure", spaIndexHandler(distFS))
 	r.Handle("GET /{userConfig}/configure", spaIndexHandler(distFS))
 	r.Handle("/*", http.FileServer(http.FS(distFS)))
@@ -165,6 +396,9 @@ func main() {
 		common.Log.Error("Failed to http.Server.Shutdown", "err", err)
 	}
 
+	stopBackground()
+	background.Wait()
+
 	if err := cache.Close(); err != nil {
 		common.Log.Error("Failed to cache.Close", "err", err)
 	}
@@ -180,13 +414,146 @@ func main() {
 	common.Log.Info("Bye!")
 }
 
+func newCacheBackend(cfg config) (cache.Backend, error) {
+	switch cfg.CacheBackend {
+	case "badger":
+		return cache.NewBadgerBackend(cache.BadgerOptions{
+			Path:           cfg.CacheBadgerPath,
+			MemTableSize:   cfg.CacheBadgerMemTableSize,
+			NumMemtables:   cfg.CacheBadgerNumMemtables,
+			GCInterval:     cfg.CacheBadgerGCInterval,
+			GCDiscardRatio: cfg.CacheBadgerGCDiscardRatio,
+			MaxSize:        cfg.CacheBadgerMaxSize,
+		}, common.Log.WithGroup("cache"))
+	case "memory":
+		return cache.NewMemoryBackend(cfg.CacheMemoryMaxBytes), nil
+	case "redis":
+		return cache.NewRedisBackend(cfg.CacheRedisAddr, cfg.CacheRedisPassword, cfg.CacheRedisDB)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.CacheBackend)
+	}
+}
+
+// newAnimeIDResolver creates the anime ID resolver with the ANIME_MAPPING_FILE mapping, reloaded when it changes until
+// ctx is done, or the bundled one. The reloading goroutine is tracked by background.
+func newAnimeIDResolver(ctx context.Context, background *sync.WaitGroup, cfg config) (*animeid.Resolver, error) {
+	resolver, err := animeid.NewResolver()
+	if err != nil {
+		return nil, fmt.Errorf("failed to animeid.NewResolver: %w", err)
+	}
+
+	if cfg.AnimeMappingFile != "" {
+		if err = resolver.LoadFile(cfg.AnimeMappingFile); err != nil {
+			return nil, fmt.Errorf("failed to animeid.Resolver.LoadFile: %w", err)
+		}
+		if cfg.AnimeMappingRefresh > 0 {
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				resolver.Refresh(ctx, cfg.AnimeMappingFile, cfg.AnimeMappingRefresh, func(err error) {
+					common.Log.Warn("Failed to animeid.Resolver.Refresh", "err", err)
+				})
+			}()
+		}
+	}
+
+	common.Log.Info("Anime ID mapping loaded", "ids", resolver.Len())
+
+	return resolver, nil
+}
+
+// newTitleResolver creates the title resolver of the title search fallback, looking up the TITLE_METADATA_FILE dataset
+// first and then the TITLE_METADATA_URL Cinemeta compatible addon. The fallback is disabled when both are empty.
+func newTitleResolver(cfg config) (*titlemeta.Resolver, error) {
+	var sources []titlemeta.Source
+
+	if cfg.TitleMetadataFile != "" {
+		fileSource, err := titlemeta.LoadFileSource(cfg.TitleMetadataFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to titlemeta.LoadFileSource: %w", err)
+		}
+		common.Log.Info("Title metadata dataset loaded", "titles", fileSource.Len())
+		sources = append(sources, fileSource)
+	}
+	if cfg.TitleMetadataURL != "" {
+		sources = append(sources, titlemeta.NewCinemetaSource(cfg.TitleMetadataURL))
+	}
+
+	return titlemeta.NewResolver(sources...), nil
+}
+
+// overrideManifest overlays the MANIFEST_FILE and then the MANIFEST_* variables onto the default manifest, and validates the result.
+func overrideManifest(manifest *stremio.Manifest, cfg config) error {
+	if cfg.ManifestFile != "" {
+		if err := stremio.LoadManifest(cfg.ManifestFile, manifest); err != nil {
+			return fmt.Errorf("failed to stremio.LoadManifest: %w", err)
+		}
+	}
+
+	for _, override := range []struct {
+		field *string
+		value string
+	}{
+		{&manifest.ID, cfg.ManifestID},
+		{&manifest.Name, cfg.ManifestName},
+		{&manifest.Description, cfg.ManifestDescription},
+		{&manifest.Logo, cfg.ManifestLogo},
+		{&manifest.Background, cfg.ManifestBackground},
+		{&manifest.ContactEmail, cfg.ManifestContactEmail},
+	} {
+		if override.value != "" {
+			*override.field = override.value
+		}
+	}
+
+	if err := manifest.Validate(); err != nil {
+		return fmt.Errorf("failed to stremio.Manifest.Validate: %w", err)
+	}
+
+	return nil
+}
+
+// newRateLimitMiddleware limits the requests of a route budget by client IP, by install as keyed by userKey and by SubX API key.
+func newRateLimitMiddleware(route string, perUser string, perAPIKey string, perIP string, trustedProxies ratelimit.TrustedProxies, userKey func(r *http.Request) string) (func(http.Handler) http.Handler, error) {
+	perUserLimit, err := ratelimit.ParseLimit(perUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ratelimit.ParseLimit(perUser): %w", err)
+	}
+	perAPIKeyLimit, err := ratelimit.ParseLimit(perAPIKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ratelimit.ParseLimit(perAPIKey): %w", err)
+	}
+	perIPLimit, err := ratelimit.ParseLimit(perIP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ratelimit.ParseLimit(perIP): %w", err)
+	}
+
+	common.Log.Info("Rate limiting", "route", route, "per_user", perUserLimit.String(), "per_api_key", perAPIKeyLimit.String(), "per_ip", perIPLimit.String())
+
+	onReject := func(ctx context.Context, route string, scope string) {
+		common.Log.WarnContext(ctx, "Rate limit exceeded", "route", route, "scope", scope)
+		common.RateLimitRejectionsTotalIncr(ctx, route, scope)
+	}
+
+	return ratelimit.Middleware(route, onReject,
+		ratelimit.Rule{Scope: "ip", Key: trustedProxies.ClientIP, Limiter: ratelimit.NewLimiter(perIPLimit)},
+		ratelimit.Rule{Scope: "user", Key: userKey, Limiter: ratelimit.NewLimiter(perUserLimit)},
+		ratelimit.Rule{Scope: "api_key", Key: internal.APIKeyRateLimitKey, Limiter: ratelimit.NewLimiter(perAPIKeyLimit)},
+	), nil
+}
+
 func handlersFilter(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/admin/") || strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/opensubtitles/") {
+		return true
+	}
+
 	if r.Method != http.MethodGet {
 		return false
 	}
 
 	path := r.URL.Path
-	if path == "/" || path == "/ws" || path == "/manifest.json" || path == "/configure" {
+	if path == "/" || path == "/ws" || path == "/manifest.json" || path == "/configure" || strings.HasPrefix(path, "/subtitle/") ||
+		strings.HasPrefix(path, "/subtitles/") || strings.HasPrefix(path, "/subx/") {
 		return true
 	}
 
@@ -196,6 +563,8 @@ func handlersFilter(r *http.Request) bool {
 	}
 
 	return pathAfterUserConfig == "manifest.json" ||
+		pathAfterUserConfig == "quota.json" ||
+		strings.HasPrefix(pathAfterUserConfig, "api/") ||
 		pathAfterUserConfig == "configure" ||
 		strings.HasPrefix(pathAfterUserConfig, "subtitles/") ||
 		strings.HasPrefix(pathAfterUserConfig, "subx/")
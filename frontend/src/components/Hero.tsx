@@ -13,13 +13,35 @@ import {
 } from "./ui/dialog";
 import {Button} from "./ui/button";
 import {Input} from "./ui/input";
+import {Label} from "./ui/label";
+import {Select, SelectContent, SelectItem, SelectTrigger, SelectValue} from "./ui/select";
+import {Switch} from "./ui/switch";
 
+type Preferences = {
+  maxResults: number;
+  dialect: string;
+  hideHearingImpaired: boolean;
+  format: string;
+  charset: string;
+  publicStats: boolean;
+};
+
+const defaultPreferences: Preferences = {
+  maxResults: 50,
+  dialect: "",
+  hideHearingImpaired: false,
+  format: "srt",
+  charset: "utf-8",
+  publicStats: true,
+};
 
-const decodeConfigApiKey = (encodedConfig?: string) => {
+
+const decodeConfig = (encodedConfig?: string) => {
   if (!encodedConfig) {
-    return "";
+    return {apiKey: "", preferences: defaultPreferences};
   }
 
+  // Only legacy plain tokens can be decoded, encrypted ones start with "e1." and can't be read back
   try {
     const base64 = encodedConfig
       .replace(/-/g, "+")
@@ -29,9 +51,19 @@ const decodeConfigApiKey = (encodedConfig?: string) => {
     const bytes = Uint8Array.from(binary, (char) => char.charCodeAt(0));
     const config = JSON.parse(new TextDecoder().decode(bytes));
 
-    return typeof config.apiKey === "string" ? config.apiKey : "";
+    return {
+      apiKey: typeof config.apiKey === "string" ? config.apiKey : "",
+      preferences: {
+        maxResults: typeof config.maxResults === "number" ? config.maxResults : defaultPreferences.maxResults,
+        dialect: typeof config.dialect === "string" ? config.dialect : defaultPreferences.dialect,
+        hideHearingImpaired: config.hideHearingImpaired === true,
+        format: typeof config.format === "string" ? config.format : defaultPreferences.format,
+        charset: typeof config.charset === "string" ? config.charset : defaultPreferences.charset,
+        publicStats: config.publicStats !== false,
+      },
+    };
   } catch {
-    return "";
+    return {apiKey: "", preferences: defaultPreferences};
   }
 };
 
@@ -39,8 +71,10 @@ const Hero = ({t}) => {
   const {userConfig} = useParams();
   const {pathname} = useLocation();
   const isConfigureRoute = pathname.endsWith("/configure");
-  const configuredApiKey = useMemo(() => decodeConfigApiKey(userConfig), [userConfig]);
+  const configured = useMemo(() => decodeConfig(userConfig), [userConfig]);
+  const configuredApiKey = configured.apiKey;
   const [apiKey, setApiKey] = useState(configuredApiKey);
+  const [preferences, setPreferences] = useState<Preferences>(configured.preferences);
   const [isInstallModalOpen, setIsInstallModalOpen] = useState(isConfigureRoute || Boolean(configuredApiKey));
 
   useEffect(() => {
@@ -49,12 +83,18 @@ const Hero = ({t}) => {
     }
 
     setApiKey(configuredApiKey);
+    setPreferences(configured.preferences);
     setIsInstallModalOpen(true);
-  }, [configuredApiKey, isConfigureRoute]);
+  }, [configured, configuredApiKey, isConfigureRoute]);
+
+  const setPreference = <K extends keyof Preferences>(key: K, value: Preferences[K]) => {
+    setPreferences((current) => ({...current, [key]: value}));
+  };
 
   const clearAndCloseModal = () => {
     setIsInstallModalOpen(false);
     setApiKey("");
+    setPreferences(defaultPreferences);
   };
 
   const handleInstallModalOpenChange = (open) => {
@@ -64,20 +104,85 @@ const Hero = ({t}) => {
     }
   };
 
-  const encodedConfig = (() => {
+  // The server doesn't require configuration when it has a fallback SubX API key, so installs without a key work too
+  const [isFallbackEnabled, setIsFallbackEnabled] = useState(false);
+
+  useEffect(() => {
+    const controller = new AbortController();
+    fetch("/manifest.json", {signal: controller.signal})
+      .then((response) => response.ok ? response.json() : Promise.reject(new Error(response.statusText)))
+      .then((manifest) => setIsFallbackEnabled(manifest?.behaviorHints?.configurationRequired === false))
+      .catch(() => setIsFallbackEnabled(false));
+
+    return () => controller.abort();
+  }, []);
+
+  const [encodedConfig, setEncodedConfig] = useState("");
+  const [validationError, setValidationError] = useState("");
+  const [isValidating, setIsValidating] = useState(false);
+
+  useEffect(() => {
     const trimmedApiKey = apiKey.trim();
-    if (!trimmedApiKey) {
-      return "";
+    setEncodedConfig("");
+    setValidationError("");
+    if (!trimmedApiKey && !isFallbackEnabled) {
+      return;
     }
 
-    const configJson = JSON.stringify({apiKey: trimmedApiKey});
-    const bytes = new TextEncoder().encode(configJson);
-    const binary = Array.from(bytes, (byte) => String.fromCharCode(byte)).join("");
-    return btoa(binary)
-      .replace(/\+/g, "-")
-      .replace(/\//g, "_")
-      .replace(/=+$/g, "");
-  })();
+    // The server checks the API key against SubX and issues the encrypted userConfig token, so the API key never shows
+    // up in plain text in the addon URLs
+    const controller = new AbortController();
+    const timeout = setTimeout(() => {
+      setIsValidating(true);
+      fetch("/api/config/validate", {
+        method: "POST",
+        headers: {"Content-Type": "application/json"},
+        body: JSON.stringify({apiKey: trimmedApiKey, ...preferences}),
+        signal: controller.signal,
+      })
+        .then((response) => response.json().catch(() => ({})).then((data) => ({ok: response.ok, data})))
+        .then(({ok, data}) => {
+          if (ok && typeof data.userConfig === "string") {
+            setEncodedConfig(data.userConfig);
+            return;
+          }
+          setValidationError(typeof data.error === "string" ? data.error : "invalid_config");
+        })
+        .catch((error) => {
+          if (error.name !== "AbortError") {
+            setValidationError("network_error");
+          }
+        })
+        .finally(() => {
+          if (!controller.signal.aborted) {
+            setIsValidating(false);
+          }
+        });
+    }, 500);
+
+    return () => {
+      clearTimeout(timeout);
+      controller.abort();
+      setIsValidating(false);
+    };
+  }, [apiKey, preferences, isFallbackEnabled]);
+
+  const [quota, setQuota] = useState<{ limit: number, remaining: number, resetAt?: string } | null>(null);
+
+  useEffect(() => {
+    setQuota(null);
+    if (!encodedConfig) {
+      return;
+    }
+
+    const controller = new AbortController();
+    fetch(`/${encodedConfig}/quota.json`, {signal: controller.signal})
+      .then((response) => response.ok ? response.json() : Promise.reject(new Error(response.statusText)))
+      .then((data) => setQuota(data.known ? data : null))
+      .catch(() => setQuota(null));
+
+    return () => controller.abort();
+  }, [encodedConfig]);
 
   const manifestPath = encodedConfig ? `/${encodedConfig}/manifest.json` : "/manifest.json";
   const installUrl = `stremio://${window.location.host}${manifestPath}`;
@@ -164,6 +269,80 @@ const Hero = ({t}) => {
                     placeholder={t('SubX API Key')}
                     autoFocus
                   />
+                  {isFallbackEnabled && !apiKey.trim() &&
+                    <p className="mt-2 text-sm text-gray-400">{t('fallback_api_key')}</p>}
+                  {isValidating && <p className="mt-2 text-sm text-gray-400">{t('Checking your SubX API Key...')}</p>}
+                  {!isValidating && validationError &&
+                    <p className="mt-2 text-sm text-red-400">{t(`validation_${validationError}`)}</p>}
+                  {!isValidating && quota &&
+                    <p className="mt-2 text-sm text-gray-400">
+                      {t('subx_quota', {remaining: quota.remaining, limit: quota.limit})}
+                      {quota.resetAt && quota.remaining < quota.limit &&
+                        ` ${t('subx_quota_reset', {time: new Date(quota.resetAt).toLocaleTimeString()})}`}
+                    </p>}
+                </div>
+                <div className="grid grid-cols-2 gap-4 py-2 text-left">
+                  <div className="space-y-2">
+                    <Label htmlFor="maxResults">{t('Max results')}</Label>
+                    <Input
+                      id="maxResults"
+                      type="number"
+                      min={1}
+                      max={50}
+                      value={preferences.maxResults}
+                      onChange={(event) => setPreference("maxResults", Math.min(50, Math.max(1, Number(event.target.value) || 1)))}
+                    />
+                  </div>
+                  <div className="space-y-2">
+                    <Label>{t('Preferred dialect')}</Label>
+                    <Select
+                      value={preferences.dialect || "any"}
+                      onValueChange={(value) => setPreference("dialect", value === "any" ? "" : value)}
+                    >
+                      <SelectTrigger><SelectValue/></SelectTrigger>
+                      <SelectContent>
+                        <SelectItem value="any">{t('Any')}</SelectItem>
+                        <SelectItem value="latam">{t('Latin American')}</SelectItem>
+                        <SelectItem value="spain">{t('Spain')}</SelectItem>
+                      </SelectContent>
+                    </Select>
+                  </div>
+                  <div className="space-y-2">
+                    <Label>{t('Format')}</Label>
+                    <Select value={preferences.format} onValueChange={(value) => setPreference("format", value)}>
+                      <SelectTrigger><SelectValue/></SelectTrigger>
+                      <SelectContent>
+                        <SelectItem value="srt">SRT</SelectItem>
+                        <SelectItem value="vtt">WebVTT</SelectItem>
+                      </SelectContent>
+                    </Select>
+                  </div>
+                  <div className="space-y-2">
+                    <Label>{t('Charset')}</Label>
+                    <Select value={preferences.charset} onValueChange={(value) => setPreference("charset", value)}>
+                      <SelectTrigger><SelectValue/></SelectTrigger>
+                      <SelectContent>
+                        <SelectItem value="utf-8">{t('Convert to UTF-8')}</SelectItem>
+                        <SelectItem value="original">{t('Keep original')}</SelectItem>
+                      </SelectContent>
+                    </Select>
+                  </div>
+                  <div className="col-span-2 flex items-center justify-between">
+                    <Label htmlFor="hideHearingImpaired">{t('Hide hearing impaired subtitles')}</Label>
+                    <Switch
+                      id="hideHearingImpaired"
+                      checked={preferences.hideHearingImpaired}
+                      onCheckedChange={(checked) => setPreference("hideHearingImpaired", checked)}
+                    />
+                  </div>
+                  <div className="col-span-2 flex items-center justify-between">
+                    <Label htmlFor="publicStats">{t('Share what I watch in the public stats')}</Label>
+                    <Switch
+                      id="publicStats"
+                      checked={preferences.publicStats}
+                      onCheckedChange={(checked) => setPreference("publicStats", checked)}
+                    />
+                  </div>
                 </div>
                 <DialogFooter>
                   <Button
@@ -177,14 +356,14 @@ const Hero = ({t}) => {
                     type="button"
                     variant="outline"
                     onClick={handleCopy}
-                    disabled={!apiKey.trim()}
+                    disabled={!encodedConfig}
                   >
                     {t('Copy')}
                   </Button>
                   <Button
                     type="button"
                     onClick={handleInstall}
-                    disabled={!apiKey.trim()}
+                    disabled={!encodedConfig}
                   >
                     {t('Install')}
                   </Button>
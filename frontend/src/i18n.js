@@ -16,6 +16,26 @@ const resources = {
             "Cancel": "Cancel",
             "Copy": "Copy",
             "Install": "Install",
+            "Max results": "Max results",
+            "Preferred dialect": "Preferred dialect",
+            "Any": "Any",
+            "Latin American": "Latin American",
+            "Spain": "Spain",
+            "Format": "Format",
+            "Charset": "Charset",
+            "Convert to UTF-8": "Convert to UTF-8",
+            "Keep original": "Keep original",
+            "Hide hearing impaired subtitles": "Hide hearing impaired subtitles",
+            "Share what I watch in the public stats": "Share what I watch in the public stats",
+            "Checking your SubX API Key...": "Checking your SubX API Key...",
+            validation_invalid_api_key: "SubX rejected this API Key, check it was copied correctly.",
+            validation_rate_limited: "SubX is rate limiting this API Key, try again in a few minutes.",
+            validation_subx_unavailable: "SubX can't be reached right now, try again later.",
+            validation_invalid_config: "The configuration is not valid.",
+            validation_network_error: "The addon can't be reached, check your connection.",
+            subx_quota: "SubX quota: {{remaining}} of {{limit}} requests left.",
+            subx_quota_reset: "Fully refilled at {{time}}.",
+            fallback_api_key: "Optional: without an API key the addon uses a shared one with a daily limit.",
             "Buy Me a Coffee": "Buy Me a Coffee on cafecito.app",
             "Install manually": "Install manually",
             "Donate": "Donate",
@@ -49,6 +69,26 @@ const resources = {
             "Cancel": "Cancelar",
             "Copy": "Copiar",
             "Install": "Instalar",
+            "Max results": "Máximo de resultados",
+            "Preferred dialect": "Dialecto preferido",
+            "Any": "Cualquiera",
+            "Latin American": "Latinoamericano",
+            "Spain": "España",
+            "Format": "Formato",
+            "Charset": "Codificación",
+            "Convert to UTF-8": "Convertir a UTF-8",
+            "Keep original": "Mantener original",
+            "Hide hearing impaired subtitles": "Ocultar subtítulos para sordos",
+            "Share what I watch in the public stats": "Compartir lo que veo en las estadísticas públicas",
+            "Checking your SubX API Key...": "Verificando tu clave API de SubX...",
+            validation_invalid_api_key: "SubX rechazó esta clave API, revisá que esté bien copiada.",
+            validation_rate_limited: "SubX está limitando esta clave API, probá de nuevo en unos minutos.",
+            validation_subx_unavailable: "No se puede acceder a SubX en este momento, probá más tarde.",
+            validation_invalid_config: "La configuración no es válida.",
+            validation_network_error: "No se puede acceder al addon, revisá tu conexión.",
+            subx_quota: "Cuota de SubX: quedan {{remaining}} de {{limit}} consultas.",
+            subx_quota_reset: "Se recarga por completo a las {{time}}.",
+            fallback_api_key: "Opcional: sin API Key el addon usa una compartida con un límite diario.",
             "Buy Me a Coffee": "Invitame un café en cafecito.app",
             "Install manually": "Instalar manualmente",
             "Donate": "Donar",
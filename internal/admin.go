@@ -0,0 +1,229 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+	"github.com/ogero/stremio-subdivx/internal/common"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CacheEntry is the JSON representation of a cache entry served by the admin endpoints.
+type CacheEntry struct {
+	Key string `json:"key"`
+	// SchemaVersion is the schema version of the value when it was stored by cache.Memoize.
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+	// CreatedAt is the time the value was stored by cache.Memoize.
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	// Codec is the name of the codec the value was encoded with by cache.Memoize.
+	Codec string `json:"codec,omitempty"`
+	// Compressed reports whether the value was compressed by cache.Memoize.
+	Compressed bool `json:"compressed,omitempty"`
+	// Value holds the entry value when it can be represented as JSON.
+	Value any `json:"value,omitempty"`
+	// ValueBase64 holds the raw entry value otherwise.
+	ValueBase64 []byte `json:"valueBase64,omitempty"`
+	// ExpiresAt is the entry expiration time, nil if it never expires.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// TTL is the number of seconds left before the entry expires, nil if it never expires.
+	TTL *int64 `json:"ttl,omitempty"`
+}
+
+// CacheDeleteResponse is the JSON response of the admin cache delete by prefix endpoint.
+type CacheDeleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+/*
+AdminMiddleware protects the admin endpoints requiring an "Authorization: Bearer <token>" header matching the configured admin token.
+
+Admin endpoints are disabled, answering 404, when no admin token is configured.
+*/
+func (a *App) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.AdminToken == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.AdminToken)) != 1 {
+			common.Log.WarnContext(r.Context(), "Failed to authorize admin request", "path", r.URL.Path)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CacheKeysHandler lists the cache keys starting with the "prefix" query parameter, up to "limit" keys.
+func (a *App) CacheKeysHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "CacheKeysHandler")
+
+	limit := 0
+	if queryLimit := r.URL.Query().Get("limit"); queryLimit != "" {
+		var err error
+		if limit, err = strconv.Atoi(queryLimit); err != nil {
+			common.Log.WarnContext(ctx, "Failed to convert limit to a number", "err", err)
+			span.RecordError(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	keys, err := cache.Keys(ctx, r.URL.Query().Get("prefix"), limit)
+	if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to cache.Keys", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(cacheAdminErrorStatus(err))
+		return
+	}
+
+	writeJSON(w, r, keys)
+}
+
+// CacheEntryHandler shows the value and TTL of the cache entry stored under the "key" query parameter.
+func (a *App) CacheEntryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "CacheEntryHandler")
+
+	entry, err := cache.GetEntry(ctx, r.URL.Query().Get("key"))
+	if err != nil {
+		common.Log.WarnContext(ctx, "Failed to cache.GetEntry", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(cacheAdminErrorStatus(err))
+		return
+	}
+
+	response := CacheEntry{Key: entry.Key}
+	if info, err := cache.InspectEnvelope(entry.Value); err == nil {
+		response.SchemaVersion = info.Version
+		response.CreatedAt = &info.CreatedAt
+		response.Codec = info.Codec
+		response.Compressed = info.Compressed
+		response.Value = info.Value
+	} else if json.Valid(entry.Value) {
+		response.Value = json.RawMessage(entry.Value)
+	}
+	if response.Value == nil {
+		response.ValueBase64 = entry.Value
+	}
+	if !entry.ExpiresAt.IsZero() {
+		ttl := int64(time.Until(entry.ExpiresAt).Seconds())
+		response.ExpiresAt = &entry.ExpiresAt
+		response.TTL = &ttl
+	}
+
+	writeJSON(w, r, response)
+}
+
+// CacheDeleteHandler deletes the cache entry stored under the "key" query parameter, or all the entries starting with the "prefix" query parameter reporting how many were deleted.
+func (a *App) CacheDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "CacheDeleteHandler")
+
+	query := r.URL.Query()
+
+	if query.Has("key") {
+		err := cache.Delete(ctx, query.Get("key"))
+		if err != nil {
+			common.Log.ErrorContext(ctx, "Failed to cache.Delete", "err", err)
+			span.RecordError(err)
+			w.WriteHeader(cacheAdminErrorStatus(err))
+			return
+		}
+
+		common.Log.InfoContext(ctx, "Deleted cache key", "key", query.Get("key"))
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if !query.Has("prefix") {
+		err := fmt.Errorf("key or prefix is required")
+		common.Log.WarnContext(ctx, "Failed to get key or prefix", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := cache.DeletePrefix(ctx, query.Get("prefix"))
+	if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to cache.DeletePrefix", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(cacheAdminErrorStatus(err))
+		return
+	}
+
+	common.Log.InfoContext(ctx, "Deleted cache prefix", "prefix", query.Get("prefix"), "deleted", deleted)
+
+	writeJSON(w, r, CacheDeleteResponse{Deleted: deleted})
+}
+
+// CacheBackupHandler streams a full backup of the cache.
+func (a *App) CacheBackupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "CacheBackupHandler")
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"cache-%s.bak\"", time.Now().UTC().Format("20060102T150405Z")))
+
+	err := cache.Backup(ctx, w)
+	if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to cache.Backup", "err", err)
+		span.RecordError(err)
+		if errors.Is(err, cache.ErrUnsupported) {
+			w.Header().Del("Content-Disposition")
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+		return
+	}
+}
+
+// CacheRestoreHandler loads a backup from the request body into the cache.
+func (a *App) CacheRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "CacheRestoreHandler")
+
+	err := cache.Restore(ctx, r.Body)
+	if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to cache.Restore", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(cacheAdminErrorStatus(err))
+		return
+	}
+
+	common.Log.InfoContext(ctx, "Restored cache backup")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func cacheAdminErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, cache.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, cache.ErrUnsupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,211 @@
+package animeid
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Prefixes of the anime IDs the Resolver understands, as used by the Stremio anime addons, such as "kitsu:1376:5".
+const (
+	PrefixKitsu   = "kitsu"
+	PrefixMAL     = "mal"
+	PrefixAniList = "anilist"
+)
+
+// Prefixes are the anime ID prefixes, in the form declared in the manifest idPrefixes.
+var Prefixes = []string{PrefixKitsu + ":", PrefixMAL + ":", PrefixAniList + ":"}
+
+var (
+	// ErrUnsupported is returned when an ID doesn't carry an anime ID prefix.
+	ErrUnsupported = errors.New("unsupported anime id")
+	// ErrUnknown is returned when an anime ID is not in the mapping.
+	ErrUnknown = errors.New("unknown anime id")
+)
+
+var imdbIDRegexp = regexp.MustCompile(`^tt\d+$`)
+
+// bundledMapping is the mapping shipped with the addon, a seed of well known titles for when no mapping file is configured.
+//
+//go:embed mapping.json
+var bundledMapping []byte
+
+// Segment maps a range of the absolute episodes of an anime entry to an IMDB season.
+type Segment struct {
+	// From is the first absolute episode of the segment, segments last until the next one.
+	From int `json:"from"`
+	// Season is the IMDB season number of the segment.
+	Season int `json:"season"`
+	// Offset is added to the absolute episode to get the IMDB episode number.
+	Offset int `json:"offset"`
+}
+
+// Entry maps an anime entry, identified by any of its Kitsu, MyAnimeList or AniList IDs, to its IMDB title.
+type Entry struct {
+	Kitsu   int    `json:"kitsu,omitempty"`
+	MAL     int    `json:"mal,omitempty"`
+	AniList int    `json:"anilist,omitempty"`
+	IMDB    string `json:"imdb"`
+	// Season is the IMDB season of the whole entry, zero for movies. Ignored when Segments is set.
+	Season int `json:"season,omitempty"`
+	// Offset is added to the absolute episode to get the IMDB episode number. Ignored when Segments is set.
+	Offset int `json:"offset,omitempty"`
+	// Segments split an entry spanning several IMDB seasons, sorted by From.
+	Segments []Segment `json:"segments,omitempty"`
+}
+
+// Resolved is an anime ID resolved to an IMDB title, season and episode.
+type Resolved struct {
+	// IMDBID is the IMDB title ID, such as "tt0877057".
+	IMDBID string
+	// Season is the IMDB season number, zero for movies.
+	Season int
+	// Episode is the IMDB episode number, zero for movies.
+	Episode int
+}
+
+// Resolver maps the anime IDs to IMDB IDs. The mapping can be swapped at runtime with Load, so it's refreshed from a
+// file prepared offline without restarting.
+type Resolver struct {
+	index atomic.Pointer[map[string]*Entry]
+}
+
+// NewResolver creates a Resolver loaded with the bundled mapping.
+func NewResolver() (*Resolver, error) {
+	r := new(Resolver)
+	if err := r.Load(bundledMapping); err != nil {
+		return nil, fmt.Errorf("failed to Resolver.Load(bundled): %w", err)
+	}
+	return r, nil
+}
+
+// Load validates the JSON mapping and replaces the current one with it, the current one is kept on error.
+func (r *Resolver) Load(data []byte) error {
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to json.Unmarshal: %w", err)
+	}
+
+	index := make(map[string]*Entry, len(entries)*3)
+	for i, entry := range entries {
+		if !imdbIDRegexp.MatchString(entry.IMDB) {
+			return fmt.Errorf("entry %d has an invalid imdb id %q", i, entry.IMDB)
+		}
+		if !sort.SliceIsSorted(entry.Segments, func(a, b int) bool { return entry.Segments[a].From < entry.Segments[b].From }) {
+			return fmt.Errorf("entry %d segments are not sorted", i)
+		}
+		for prefix, id := range map[string]int{PrefixKitsu: entry.Kitsu, PrefixMAL: entry.MAL, PrefixAniList: entry.AniList} {
+			if id > 0 {
+				index[prefix+":"+strconv.Itoa(id)] = entry
+			}
+		}
+	}
+
+	r.index.Store(&index)
+
+	return nil
+}
+
+// LoadFile loads the JSON mapping file at path, see Load.
+func (r *Resolver) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to os.ReadFile: %w", err)
+	}
+	return r.Load(data)
+}
+
+// Refresh reloads the mapping file at path every interval when it was modified, it blocks until ctx is done so it's
+// meant to run in a goroutine. Failed reloads are reported to onError and keep the current mapping.
+func (r *Resolver) Refresh(ctx context.Context, path string, interval time.Duration, onError func(err error)) {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			onError(fmt.Errorf("failed to os.Stat: %w", err))
+			continue
+		}
+		if !info.ModTime().After(modTime) {
+			continue
+		}
+		modTime = info.ModTime()
+
+		if err = r.LoadFile(path); err != nil {
+			onError(err)
+		}
+	}
+}
+
+// Len returns the number of anime IDs in the mapping.
+func (r *Resolver) Len() int {
+	return len(*r.index.Load())
+}
+
+// IsAnimeID reports whether id carries one of the anime ID prefixes.
+func IsAnimeID(id string) bool {
+	for _, prefix := range Prefixes {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve maps an anime ID, such as "kitsu:1376" or "kitsu:1376:5" with the absolute episode, to its IMDB title, season
+// and episode.
+func (r *Resolver) Resolve(id string) (Resolved, error) {
+	if !IsAnimeID(id) {
+		return Resolved{}, fmt.Errorf("%w: %q", ErrUnsupported, id)
+	}
+
+	parts := strings.Split(id, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return Resolved{}, fmt.Errorf("%w: %q", ErrUnsupported, id)
+	}
+
+	entry, ok := (*r.index.Load())[parts[0]+":"+parts[1]]
+	if !ok {
+		return Resolved{}, fmt.Errorf("%w: %q", ErrUnknown, id)
+	}
+
+	resolved := Resolved{IMDBID: entry.IMDB}
+	if len(parts) == 2 {
+		return resolved, nil
+	}
+
+	episode, err := strconv.Atoi(parts[2])
+	if err != nil || episode < 1 {
+		return Resolved{}, fmt.Errorf("%w: invalid episode in %q", ErrUnsupported, id)
+	}
+
+	resolved.Season, resolved.Episode = entry.Season, episode+entry.Offset
+	for _, segment := range entry.Segments {
+		if episode < segment.From {
+			break
+		}
+		resolved.Season, resolved.Episode = segment.Season, episode+segment.Offset
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,94 @@
+package animeid_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/animeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolverBundled(t *testing.T) {
+	resolver, err := animeid.NewResolver()
+	require.NoError(t, err)
+
+	resolved, err := resolver.Resolve("kitsu:1376:5")
+	require.NoError(t, err)
+	assert.Equal(t, animeid.Resolved{IMDBID: "tt0877057", Season: 1, Episode: 5}, resolved)
+
+	resolved, err = resolver.Resolve("mal:1535")
+	require.NoError(t, err)
+	assert.Equal(t, animeid.Resolved{IMDBID: "tt0877057"}, resolved)
+}
+
+func TestResolverSegments(t *testing.T) {
+	resolver, err := animeid.NewResolver()
+	require.NoError(t, err)
+	require.NoError(t, resolver.Load([]byte(`[
+		{"kitsu": 100, "imdb": "tt0000100", "segments": [{"from": 1, "season": 1}, {"from": 13, "season": 2, "offset": -12}]}
+	]`)))
+
+	tests := map[string]animeid.Resolved{
+		"kitsu:100:1":  {IMDBID: "tt0000100", Season: 1, Episode: 1},
+		"kitsu:100:12": {IMDBID: "tt0000100", Season: 1, Episode: 12},
+		"kitsu:100:13": {IMDBID: "tt0000100", Season: 2, Episode: 1},
+		"kitsu:100:24": {IMDBID: "tt0000100", Season: 2, Episode: 12},
+	}
+	for id, expected := range tests {
+		resolved, err := resolver.Resolve(id)
+		require.NoError(t, err, id)
+		assert.Equal(t, expected, resolved, id)
+	}
+
+	// The loaded mapping replaces the bundled one
+	_, err = resolver.Resolve("kitsu:1376")
+	assert.ErrorIs(t, err, animeid.ErrUnknown)
+}
+
+func TestResolverErrors(t *testing.T) {
+	resolver, err := animeid.NewResolver()
+	require.NoError(t, err)
+
+	_, err = resolver.Resolve("tt0877057")
+	assert.ErrorIs(t, err, animeid.ErrUnsupported)
+	_, err = resolver.Resolve("kitsu:1376:zero")
+	assert.ErrorIs(t, err, animeid.ErrUnsupported)
+
+	assert.Error(t, resolver.Load([]byte(`[{"kitsu": 1, "imdb": "1234"}]`)))
+	_, err = resolver.Resolve("kitsu:1376")
+	assert.NoError(t, err, "a failed load keeps the current mapping")
+}
+
+func TestResolverRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapping.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"kitsu": 100, "imdb": "tt0000100"}]`), 0o644))
+
+	resolver, err := animeid.NewResolver()
+	require.NoError(t, err)
+	require.NoError(t, resolver.LoadFile(path))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resolver.Refresh(ctx, path, 10*time.Millisecond, func(err error) { t.Error(err) })
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The modification time keeps moving forward, Refresh may stat the file after it's written
+	require.NoError(t, os.WriteFile(path, []byte(`[{"kitsu": 200, "imdb": "tt0000200"}]`), 0o644))
+	modTime := time.Now()
+	assert.Eventually(t, func() bool {
+		modTime = modTime.Add(time.Second)
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+		_, err := resolver.Resolve("kitsu:200")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,375 @@
+package internal
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ogero/stremio-subdivx/internal/animeid"
+	"github.com/ogero/stremio-subdivx/internal/common"
+	"github.com/ogero/stremio-subdivx/internal/feedback"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/ogero/stremio-subdivx/internal/videohash"
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// openAPIDocument describes the JSON APIs, served as is on /api/v1/openapi.json.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// Sort fields of the v1 subtitles endpoint.
+const (
+	SortRelevance = "relevance"
+	SortScore     = "score"
+	SortDownloads = "downloads"
+	SortPostedAt  = "postedAt"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// errInvalidParameter is returned by parseSubtitlesQuery for the malformed query parameters.
+var errInvalidParameter = errors.New("invalid parameter")
+
+// APISubtitle is a subtitle of the JSON APIs, with the SubX metadata and the computed score.
+type APISubtitle struct {
+	ID          string `json:"id"`
+	Lang        string `json:"lang"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Uploader    string `json:"uploader"`
+	PostedAt    string `json:"postedAt"`
+	Downloads   int    `json:"downloads"`
+	IMDBID      string `json:"imdbId,omitempty"`
+	Season      int    `json:"season,omitempty"`
+	Episodes    []int  `json:"episodes,omitempty"`
+	SeasonPack  bool   `json:"seasonPack,omitempty"`
+	AirDate     string `json:"airDate,omitempty"`
+	// Dialect is the Spanish dialect guessed from the description, "latam", "spain" or empty if unknown.
+	Dialect         string `json:"dialect,omitempty"`
+	HearingImpaired bool   `json:"hearingImpaired"`
+	// Score is how well the subtitle description matches the filename.
+	Score int `json:"score"`
+	// URL downloads the subtitle, converted to the user config format and charset.
+	URL string `json:"url"`
+}
+
+// apiSubtitle returns the API representation of the subtitle, downloaded through a URL signed for userRef.
+func (a *App) apiSubtitle(subtitle RankedSubtitle, userRef string, downloadQuery string) APISubtitle {
+	return APISubtitle{
+		ID:              subtitle.ID,
+		Lang:            "spa",
+		Title:           subtitle.Title,
+		Description:     subtitle.Description,
+		Uploader:        subtitle.UploaderName,
+		PostedAt:        subtitle.PostedAt,
+		Downloads:       subtitle.Downloads,
+		IMDBID:          subtitle.IMDBID,
+		Season:          subtitle.Season,
+		Episodes:        subtitle.Episodes,
+		SeasonPack:      subtitle.SeasonPack,
+		AirDate:         subtitle.AirDate,
+		Dialect:         subtitle.Dialect(),
+		HearingImpaired: subtitle.HearingImpaired(),
+		Score:           subtitle.Score,
+		URL:             a.signedSubtitleURL(subtitle.ID, userRef, downloadQuery),
+	}
+}
+
+// APIError is the JSON error response of the v1 endpoints.
+type APIError struct {
+	// Error is a machine readable reason, such as "invalid_parameter".
+	Error string `json:"error"`
+	// Message is a human readable description of Error.
+	Message string `json:"message"`
+}
+
+// SubtitlesPage is the JSON response of the v1 subtitles endpoint.
+type SubtitlesPage struct {
+	Data     []APISubtitle `json:"data"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+	// Total is the number of subtitles matching the filters, across all the pages.
+	Total int `json:"total"`
+}
+
+// SubtitlesQuery is the parsed query of the v1 subtitles endpoint.
+type SubtitlesQuery struct {
+	// Type and ID are the Stremio type and video ID, such as "series" and "tt0903747:1:2".
+	Type string
+	ID   string
+	// Filename is the video filename the subtitles are scored against.
+	Filename string
+	Video    videohash.Video
+
+	// Dialect keeps the subtitles of a Spanish dialect only.
+	Dialect string
+	// HearingImpaired keeps the subtitles meant, or not meant, for the hearing impaired only, nil keeps both.
+	HearingImpaired *bool
+	// Uploader keeps the subtitles of an uploader only, case insensitive.
+	Uploader string
+	// MinDownloads keeps the subtitles downloaded at least that many times only.
+	MinDownloads int
+
+	// Sort is one of SortRelevance, SortScore, SortDownloads or SortPostedAt.
+	Sort string
+	// Descending sorts from the highest value first, the most relevant first for SortRelevance.
+	Descending bool
+
+	Page     int
+	PageSize int
+}
+
+// parseSubtitlesQuery parses the query parameters of the v1 subtitles endpoint, wrapping errInvalidParameter for the malformed ones.
+func parseSubtitlesQuery(values url.Values) (SubtitlesQuery, error) {
+	q := SubtitlesQuery{
+		Type:       values.Get("type"),
+		ID:         values.Get("id"),
+		Filename:   strings.TrimSpace(values.Get("filename")),
+		Dialect:    strings.ToLower(values.Get("dialect")),
+		Uploader:   strings.TrimSpace(values.Get("uploader")),
+		Sort:       SortRelevance,
+		Descending: true,
+		Page:       1,
+		PageSize:   defaultPageSize,
+	}
+
+	if err := common.ValidateSubtitleType(q.Type); err != nil {
+		return q, fmt.Errorf("%w: type must be movie or series", errInvalidParameter)
+	}
+	if q.ID == "" {
+		return q, fmt.Errorf("%w: id is required", errInvalidParameter)
+	}
+	if len(q.Filename) > maxSearchFilenameLength {
+		return q, fmt.Errorf("%w: filename is too long", errInvalidParameter)
+	}
+	if q.Dialect != userconfig.DialectAny && q.Dialect != userconfig.DialectLatam && q.Dialect != userconfig.DialectSpain {
+		return q, fmt.Errorf("%w: dialect must be latam or spain", errInvalidParameter)
+	}
+
+	q.Video.Hash = values.Get("videoHash")
+	if s := values.Get("videoSize"); s != "" {
+		size, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || size < 0 {
+			return q, fmt.Errorf("%w: videoSize must be a positive integer", errInvalidParameter)
+		}
+		q.Video.Size = size
+	}
+
+	if s := values.Get("hearingImpaired"); s != "" {
+		hearingImpaired, err := strconv.ParseBool(s)
+		if err != nil {
+			return q, fmt.Errorf("%w: hearingImpaired must be true or false", errInvalidParameter)
+		}
+		q.HearingImpaired = &hearingImpaired
+	}
+
+	intParams := []struct {
+		name  string
+		value *int
+		min   int
+	}{
+		{name: "minDownloads", value: &q.MinDownloads, min: 0},
+		{name: "page", value: &q.Page, min: 1},
+		{name: "pageSize", value: &q.PageSize, min: 1},
+	}
+	for _, param := range intParams {
+		s := values.Get(param.name)
+		if s == "" {
+			continue
+		}
+		value, err := strconv.Atoi(s)
+		if err != nil || value < param.min {
+			return q, fmt.Errorf("%w: %s must be an integer of at least %d", errInvalidParameter, param.name, param.min)
+		}
+		*param.value = value
+	}
+	if q.PageSize > maxPageSize {
+		return q, fmt.Errorf("%w: pageSize must be at most %d", errInvalidParameter, maxPageSize)
+	}
+
+	if s := values.Get("sort"); s != "" {
+		if s != SortRelevance && s != SortScore && s != SortDownloads && s != SortPostedAt {
+			return q, fmt.Errorf("%w: sort must be relevance, score, downloads or postedAt", errInvalidParameter)
+		}
+		q.Sort = s
+	}
+	switch values.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.Descending = false
+	default:
+		return q, fmt.Errorf("%w: order must be asc or desc", errInvalidParameter)
+	}
+
+	return q, nil
+}
+
+// apply filters, sorts and paginates the ranked subtitles, returning the page and the number of subtitles matching the filters.
+func (q SubtitlesQuery) apply(subtitles []RankedSubtitle) ([]RankedSubtitle, int) {
+	filtered := make([]RankedSubtitle, 0, len(subtitles))
+	for _, subtitle := range subtitles {
+		if q.Dialect != "" && subtitle.Dialect() != q.Dialect {
+			continue
+		}
+		if q.HearingImpaired != nil && subtitle.HearingImpaired() != *q.HearingImpaired {
+			continue
+		}
+		if q.Uploader != "" && !strings.EqualFold(subtitle.UploaderName, q.Uploader) {
+			continue
+		}
+		if subtitle.Downloads < q.MinDownloads {
+			continue
+		}
+		filtered = append(filtered, subtitle)
+	}
+
+	// The subtitles come ranked, the most relevant first
+	if q.Sort != SortRelevance {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			a, b := filtered[i], filtered[j]
+			switch q.Sort {
+			case SortScore:
+				return a.Score > b.Score
+			case SortDownloads:
+				return a.Downloads > b.Downloads
+			default:
+				// The SubX dates are RFC 3339 in UTC, they sort as strings
+				return a.PostedAt > b.PostedAt
+			}
+		})
+	}
+	if !q.Descending {
+		for i, j := 0, len(filtered)-1; i < j; i, j = i+1, j-1 {
+			filtered[i], filtered[j] = filtered[j], filtered[i]
+		}
+	}
+
+	total := len(filtered)
+	// Checked before multiplying, the page number is unbounded
+	if q.Page-1 > total/q.PageSize || (q.Page-1)*q.PageSize >= total {
+		return []RankedSubtitle{}, total
+	}
+	start := (q.Page - 1) * q.PageSize
+	return filtered[start:min(start+q.PageSize, total)], total
+}
+
+/*
+SubtitlesV1Handler serves the v1 subtitles endpoint, the subtitles of a title with their full metadata and score.
+
+It takes the Stremio type and video ID, and optionally the video filename, hash and size to rank the subtitles like the
+addon does. The ranked subtitles can be filtered, sorted and paginated, see the OpenAPI document for the parameters.
+Malformed parameters are answered with 400 and an APIError.
+*/
+func (a *App) SubtitlesV1Handler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "SubtitlesV1Handler")
+
+	query, err := parseSubtitlesQuery(r.URL.Query())
+	if err != nil {
+		common.Log.WarnContext(ctx, "Failed to parseSubtitlesQuery", "err", err)
+		span.RecordError(err)
+		writeAPIError(w, r, http.StatusBadRequest, "invalid_parameter", strings.TrimPrefix(err.Error(), errInvalidParameter.Error()+": "))
+		return
+	}
+	span.SetAttributes(attribute.String("params.type", query.Type))
+	span.SetAttributes(attribute.String("param.id", query.ID))
+
+	page := SubtitlesPage{Data: []APISubtitle{}, Page: query.Page, PageSize: query.PageSize}
+
+	videoID, err := a.resolveVideoID(query.ID)
+	if errors.Is(err, animeid.ErrUnknown) {
+		common.Log.InfoContext(ctx, "Failed to resolveVideoID", "err", err)
+		writeJSON(w, r, page)
+		return
+	}
+	if err == nil {
+		err = common.ValidateIMDBTitleID(videoID.TitleID)
+	}
+	if err != nil {
+		common.Log.WarnContext(ctx, "Failed to resolveVideoID", "err", err)
+		span.RecordError(err)
+		writeAPIError(w, r, http.StatusBadRequest, "invalid_parameter", "id must be an IMDB or anime video ID")
+		return
+	}
+
+	installToken := chi.URLParam(r, "userConfig")
+	config := userconfig.FromContext(ctx)
+	ctx, apiKey, ok := a.resolveAPIKey(w, r, config, installToken)
+	if !ok {
+		return
+	}
+
+	subtitles, err := a.StremioService.SearchSubtitles(ctx, apiKey, query.Type, videoID.TitleID, videoID.Season, videoID.Episode, query.Filename, SubtitlesOptions{
+		Video:   query.Video,
+		Dialect: config.Dialect,
+	})
+	var rateLimitErr *subx.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		common.Log.WarnContext(ctx, "Failed to StremioService.SearchSubtitles", "err", err)
+		span.RecordError(err)
+		writeRetryAfter(w, rateLimitErr.RetryAfter)
+		writeAPIError(w, r, http.StatusTooManyRequests, "rate_limited", "SubX rate limit exceeded, try again later")
+		return
+	} else if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to StremioService.SearchSubtitles", "err", err)
+		span.RecordError(err)
+		writeAPIError(w, r, http.StatusInternalServerError, "internal_error", "Failed to search the subtitles")
+		return
+	}
+
+	userRef, err := a.UserConfigStore.Put(ctx, installToken)
+	if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to userconfig.Store.Put", "err", err)
+		span.RecordError(err)
+		writeAPIError(w, r, http.StatusInternalServerError, "internal_error", "Failed to sign the download URLs")
+		return
+	}
+
+	subtitles, page.Total = query.apply(subtitles)
+	downloadQuery := subtitleDownloadQuery(query.Video, feedback.TitleKey(videoID.TitleID, videoID.Season, videoID.Episode), feedback.NormalizeRelease(query.Filename))
+	for _, subtitle := range subtitles {
+		page.Data = append(page.Data, a.apiSubtitle(subtitle, userRef, downloadQuery))
+	}
+
+	w.Header().Set("CDN-Cache-Control", "public, max-age=600")
+	w.Header().Set("Cache-Control", "public, max-age=600")
+
+	writeJSON(w, r, page)
+}
+
+// OpenAPIHandler serves the OpenAPI document of the JSON APIs.
+func (a *App) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	common.Log.DebugContext(ctx, "OpenAPIHandler")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+
+	if _, err := w.Write(openAPIDocument); err != nil {
+		common.Log.ErrorContext(ctx, "Failed to write response", "err", err)
+		trace.SpanFromContext(ctx).RecordError(err)
+	}
+}
+
+// writeAPIError writes an APIError response with the status code.
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	writeJSON(w, r, APIError{Error: code, Message: message})
+}
@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubtitlesQuery(t *testing.T) {
+	q, err := parseSubtitlesQuery(url.Values{"type": {"series"}, "id": {"tt0903747:1:2"}})
+	require.NoError(t, err)
+	assert.Equal(t, SubtitlesQuery{Type: "series", ID: "tt0903747:1:2", Sort: SortRelevance, Descending: true, Page: 1, PageSize: defaultPageSize}, q)
+
+	q, err = parseSubtitlesQuery(url.Values{
+		"type": {"movie"}, "id": {"tt0133093"}, "dialect": {"LATAM"}, "hearingImpaired": {"false"}, "minDownloads": {"10"},
+		"sort": {"downloads"}, "order": {"asc"}, "page": {"2"}, "pageSize": {"5"}, "videoHash": {"8e245d9679d31e12"}, "videoSize": {"1052791437"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "latam", q.Dialect)
+	require.NotNil(t, q.HearingImpaired)
+	assert.False(t, *q.HearingImpaired)
+	assert.Equal(t, 10, q.MinDownloads)
+	assert.Equal(t, SortDownloads, q.Sort)
+	assert.False(t, q.Descending)
+	assert.Equal(t, 2, q.Page)
+	assert.Equal(t, 5, q.PageSize)
+	assert.True(t, q.Video.Valid())
+
+	for _, values := range []url.Values{
+		{"id": {"tt0133093"}},
+		{"type": {"movie"}},
+		{"type": {"movie"}, "id": {"tt0133093"}, "dialect": {"mexico"}},
+		{"type": {"movie"}, "id": {"tt0133093"}, "hearingImpaired": {"maybe"}},
+		{"type": {"movie"}, "id": {"tt0133093"}, "page": {"0"}},
+		{"type": {"movie"}, "id": {"tt0133093"}, "pageSize": {"101"}},
+		{"type": {"movie"}, "id": {"tt0133093"}, "sort": {"title"}},
+		{"type": {"movie"}, "id": {"tt0133093"}, "order": {"up"}},
+		{"type": {"movie"}, "id": {"tt0133093"}, "videoSize": {"-1"}},
+	} {
+		_, err = parseSubtitlesQuery(values)
+		assert.ErrorIs(t, err, errInvalidParameter, values.Encode())
+	}
+}
+
+func TestSubtitlesQueryApply(t *testing.T) {
+	subtitles := []RankedSubtitle{
+		{Subtitle: &subx.Subtitle{ID: "1", UploaderName: "alice", Downloads: 10, PostedAt: "2020-01-01T00:00:00Z", Description: "latino"}, Score: 1},
+		{Subtitle: &subx.Subtitle{ID: "2", UploaderName: "bob", Downloads: 30, PostedAt: "2022-01-01T00:00:00Z", Description: "castellano"}, Score: 3},
+		{Subtitle: &subx.Subtitle{ID: "3", UploaderName: "Alice", Downloads: 20, PostedAt: "2021-01-01T00:00:00Z", Description: "latino SDH"}, Score: 2},
+	}
+	ids := func(subtitles []RankedSubtitle) []string {
+		ids := make([]string, len(subtitles))
+		for i, subtitle := range subtitles {
+			ids[i] = subtitle.ID
+		}
+		return ids
+	}
+	hearingImpaired := false
+
+	tests := []struct {
+		name     string
+		query    SubtitlesQuery
+		expected []string
+		total    int
+	}{
+		{name: "relevance", query: SubtitlesQuery{Sort: SortRelevance, Descending: true, Page: 1, PageSize: 20}, expected: []string{"1", "2", "3"}, total: 3},
+		{name: "relevance ascending", query: SubtitlesQuery{Sort: SortRelevance, Page: 1, PageSize: 20}, expected: []string{"3", "2", "1"}, total: 3},
+		{name: "downloads", query: SubtitlesQuery{Sort: SortDownloads, Descending: true, Page: 1, PageSize: 20}, expected: []string{"2", "3", "1"}, total: 3},
+		{name: "posted ascending", query: SubtitlesQuery{Sort: SortPostedAt, Page: 1, PageSize: 20}, expected: []string{"1", "3", "2"}, total: 3},
+		{name: "score", query: SubtitlesQuery{Sort: SortScore, Descending: true, Page: 1, PageSize: 20}, expected: []string{"2", "3", "1"}, total: 3},
+		{name: "uploader", query: SubtitlesQuery{Uploader: "alice", Sort: SortRelevance, Descending: true, Page: 1, PageSize: 20}, expected: []string{"1", "3"}, total: 2},
+		{name: "dialect", query: SubtitlesQuery{Dialect: "spain", Sort: SortRelevance, Descending: true, Page: 1, PageSize: 20}, expected: []string{"2"}, total: 1},
+		{name: "hearing impaired", query: SubtitlesQuery{HearingImpaired: &hearingImpaired, Sort: SortRelevance, Descending: true, Page: 1, PageSize: 20}, expected: []string{"1", "2"}, total: 2},
+		{name: "min downloads", query: SubtitlesQuery{MinDownloads: 20, Sort: SortRelevance, Descending: true, Page: 1, PageSize: 20}, expected: []string{"2", "3"}, total: 2},
+		{name: "second page", query: SubtitlesQuery{Sort: SortRelevance, Descending: true, Page: 2, PageSize: 2}, expected: []string{"3"}, total: 3},
+		{name: "past the last page", query: SubtitlesQuery{Sort: SortRelevance, Descending: true, Page: 1 << 62, PageSize: 100}, expected: []string{}, total: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, total := tt.query.apply(subtitles)
+			assert.Equal(t, tt.expected, ids(page))
+			assert.Equal(t, tt.total, total)
+		})
+	}
+}
+
+func TestOpenAPIDocumentDescribesAPISubtitle(t *testing.T) {
+	var document struct {
+		Paths      map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(openAPIDocument, &document))
+	assert.Contains(t, document.Paths, "/api/v1/subtitles")
+
+	apiSubtitle := reflect.TypeOf(APISubtitle{})
+	properties := document.Components.Schemas["Subtitle"].Properties
+	assert.Len(t, properties, apiSubtitle.NumField())
+	for i := 0; i < apiSubtitle.NumField(); i++ {
+		name, _, _ := strings.Cut(apiSubtitle.Field(i).Tag.Get("json"), ",")
+		assert.Contains(t, properties, name)
+	}
+}
@@ -1,17 +1,29 @@
 package internal
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/ogero/stremio-subdivx/internal/animeid"
+	"github.com/ogero/stremio-subdivx/internal/cache"
 	"github.com/ogero/stremio-subdivx/internal/common"
+	"github.com/ogero/stremio-subdivx/internal/feedback"
+	"github.com/ogero/stremio-subdivx/internal/opensubtitles"
+	"github.com/ogero/stremio-subdivx/internal/ratelimit"
+	"github.com/ogero/stremio-subdivx/internal/subtitleurl"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/ogero/stremio-subdivx/internal/videohash"
 	"github.com/ogero/stremio-subdivx/pkg/stremio"
+	"github.com/ogero/stremio-subdivx/pkg/subx"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -21,6 +33,23 @@ type App struct {
 	StremioService  *StremioService
 	StremioManifest *stremio.Manifest
 	AddonHost       string
+	AdminToken      string
+
+	UserConfigSealer  *userconfig.Sealer
+	UserConfigStore   *userconfig.Store
+	SubtitleURLSigner *subtitleurl.Signer
+	FallbackKey       *FallbackKey
+	TrustedProxies    ratelimit.TrustedProxies
+	AnimeIDs          *animeid.Resolver
+
+	// OpenSubtitlesFiles maps the file IDs of the OpenSubtitles compatible facade to the subtitles, nil when it's disabled.
+	OpenSubtitlesFiles *opensubtitles.Store
+}
+
+// ConfigResponse is the JSON response of the config endpoint.
+type ConfigResponse struct {
+	// UserConfig is the token to use as the userConfig path segment.
+	UserConfig string `json:"userConfig"`
 }
 
 /*
@@ -30,22 +59,38 @@ Parameters:
   - stremioService: The service used to interact with Stremio.
   - stremioManifest: The manifest used to interact with Stremio.
   - addonHost: The host address for the addon.
+  - adminToken: The token required by the admin endpoints, empty disables them.
+  - userConfigSealer: The sealer used to issue and open the userConfig tokens.
+  - userConfigStore: The store that keeps the userConfig tokens referenced by the signed subtitle URLs.
+  - subtitleURLSigner: The signer of the short-lived subtitle URLs.
+  - fallbackKey: The operator SubX API key used by the installs without their own, nil disables it.
+  - trustedProxies: The reverse proxies trusted to report the client IP.
+  - animeIDs: The resolver of the anime IDs to IMDB IDs, nil only accepts IMDB IDs.
 
 Returns:
   - A pointer to the newly created App instance.
 */
-func NewApp(stremioService *StremioService, stremioManifest *stremio.Manifest, addonHost string) (*App, error) {
+func NewApp(stremioService *StremioService, stremioManifest *stremio.Manifest, addonHost string, adminToken string, userConfigSealer *userconfig.Sealer, userConfigStore *userconfig.Store, subtitleURLSigner *subtitleurl.Signer, fallbackKey *FallbackKey, trustedProxies ratelimit.TrustedProxies, animeIDs *animeid.Resolver) (*App, error) {
 	return &App{
-		StremioService:  stremioService,
-		StremioManifest: stremioManifest,
-		AddonHost:       addonHost,
+		StremioService:    stremioService,
+		StremioManifest:   stremioManifest,
+		AddonHost:         addonHost,
+		AdminToken:        adminToken,
+		UserConfigSealer:  userConfigSealer,
+		UserConfigStore:   userConfigStore,
+		SubtitleURLSigner: subtitleURLSigner,
+		FallbackKey:       fallbackKey,
+		TrustedProxies:    trustedProxies,
+		AnimeIDs:          animeIDs,
 	}, nil
 }
 
 /*
 ManifestHandler serves the manifest for the addon.
 
-This method writes the manifest as a JSON response to the HTTP writer.
+This method writes the manifest as a JSON response to the HTTP writer. It parses the userConfig path segment itself
+instead of relying on UserConfigMiddleware, so the installs with a malformed or no longer decryptable userConfig get a
+manifest requiring configuration, which leads Stremio to the configure page, instead of an error.
 */
 func (a *App) ManifestHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -55,8 +100,20 @@ func (a *App) ManifestHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
+	var config *userconfig.Config
+	invalidConfig := false
+	if token := chi.URLParam(r, "userConfig"); token != "" {
+		var err error
+		config, err = userconfig.Parse(a.UserConfigSealer, token)
+		if err != nil {
+			common.Log.WarnContext(ctx, "Failed to userconfig.Parse", "err", err)
+			span.RecordError(err)
+			invalidConfig = true
+		}
+	}
+
 	manifest := *a.StremioManifest
-	if apiKeyFromUserConfig(chi.URLParam(r, "userConfig")) != "" {
+	if !invalidConfig && (a.FallbackKey != nil || (config != nil && config.APIKey != "")) {
 		manifest.BehaviorHints.ConfigurationRequired = false
 	}
 
@@ -70,27 +127,224 @@ func (a *App) ManifestHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func apiKeyFromUserConfig(userConfig string) string {
-	if userConfig == "" {
-		return ""
+/*
+UserConfigMiddleware parses the userConfig path segment once and stores the resulting userconfig.Config in the request context.
+
+Tokens that fail to decrypt are rejected with 401, and malformed or invalid configs with 400.
+*/
+func (a *App) UserConfigMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		config, err := userconfig.Parse(a.UserConfigSealer, chi.URLParam(r, "userConfig"))
+		if err != nil {
+			common.Log.WarnContext(ctx, "Failed to userconfig.Parse", "err", err)
+			trace.SpanFromContext(ctx).RecordError(err)
+			w.WriteHeader(userConfigErrorStatus(err))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(userconfig.NewContext(ctx, config)))
+	})
+}
+
+// userConfigErrorStatus maps the errors of userconfig.Parse to HTTP status codes.
+func userConfigErrorStatus(err error) int {
+	if errors.Is(err, userconfig.ErrDecrypt) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
+
+// requireAPIKey returns the config stored in the request context by UserConfigMiddleware, or an error if it lacks an API key.
+func requireAPIKey(r *http.Request) (*userconfig.Config, error) {
+	config := userconfig.FromContext(r.Context())
+	if config == nil || config.APIKey == "" {
+		return nil, fmt.Errorf("api key not found")
+	}
+	return config, nil
+}
+
+/*
+resolveAPIKey returns the SubX API key of the user config, or the fallback API key when it has none and the fallback is enabled.
+
+The returned context is the one to search and download with: with the fallback API key, its requests that reach SubX
+count against the fallback daily quotas, keyed by the client IP and by installToken, the userConfig token the config
+was parsed from. Once a quota is used up they fail with a *FallbackQuotaError, answered as a SubX rate limit.
+It writes the error response and returns false when there's no API key to use, 400 without a fallback.
+*/
+func (a *App) resolveAPIKey(w http.ResponseWriter, r *http.Request, config *userconfig.Config, installToken string) (context.Context, string, bool) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	if config.APIKey != "" {
+		return ctx, config.APIKey, true
+	}
+
+	if a.FallbackKey == nil {
+		common.Log.WarnContext(ctx, "Failed to resolveAPIKey", "err", fmt.Errorf("api key not found"))
+		span.RecordError(fmt.Errorf("api key not found"))
+		w.WriteHeader(http.StatusBadRequest)
+		return ctx, "", false
+	}
+
+	installRef := ""
+	if installToken != "" {
+		installRef = userconfig.Ref(installToken)
 	}
 
-	decoded, err := base64.RawURLEncoding.DecodeString(userConfig)
+	ctx, apiKey, err := a.FallbackKey.Reserve(ctx, a.TrustedProxies.ClientIP(r), installRef)
 	if err != nil {
-		decoded, err = base64.URLEncoding.DecodeString(userConfig)
-		if err != nil {
-			return ""
+		common.Log.ErrorContext(ctx, "Failed to FallbackKey.Reserve", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return ctx, "", false
+	}
+	span.SetAttributes(attribute.Bool("subx.fallback-api-key", true))
+
+	return ctx, apiKey, true
+}
+
+// ConfigValidateResponse is the JSON response of the config validation endpoint.
+type ConfigValidateResponse struct {
+	// Valid reports whether SubX accepted the API key.
+	Valid bool `json:"valid"`
+	// Error is a machine readable reason for an invalid API key: "invalid_api_key", "rate_limited" or "subx_unavailable".
+	Error string `json:"error,omitempty"`
+	// Message is a human readable description of Error.
+	Message string `json:"message,omitempty"`
+	// UserConfig is the token to use as the userConfig path segment.
+	UserConfig string `json:"userConfig,omitempty"`
+	// ManifestURL is the URL of the configured manifest.
+	ManifestURL string `json:"manifestUrl,omitempty"`
+	// InstallURL is the stremio:// URL that installs the configured addon.
+	InstallURL string `json:"installUrl,omitempty"`
+}
+
+/*
+ConfigHandler issues an encrypted userConfig token for the JSON user config in the request body.
+
+The configure page uses it to build the install URL, so the API key never travels in plain text in the addon URLs.
+*/
+func (a *App) ConfigHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	common.Log.DebugContext(ctx, "ConfigHandler")
+
+	token, _, ok := a.sealConfigRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	writeJSON(w, r, ConfigResponse{UserConfig: token})
+}
+
+/*
+ConfigValidateHandler checks the API key of the JSON user config in the request body against SubX.
+Configs without an API key are accepted unchecked when the fallback API key is enabled.
+
+On success it answers with the userConfig token and the install URLs, otherwise with the reason the key can't be used:
+401 when SubX rejects it, 429 when SubX rate limits it and 502 when SubX can't be reached.
+*/
+func (a *App) ConfigValidateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "ConfigValidateHandler")
+
+	token, config, ok := a.sealConfigRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	// Configs without an API key rely on the fallback API key, there's nothing to check against SubX
+	var err error
+	if config.APIKey != "" {
+		err = a.StremioService.ValidateAPIKey(ctx, config.APIKey)
+	}
+	if err != nil {
+		common.Log.WarnContext(ctx, "Failed to StremioService.ValidateAPIKey", "err", err)
+		span.RecordError(err)
+
+		var rateLimitErr *subx.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			writeRetryAfter(w, rateLimitErr.RetryAfter)
 		}
+
+		status, response := configValidateError(err)
+		w.WriteHeader(status)
+		writeJSON(w, r, response)
+		return
 	}
 
-	var config struct {
-		APIKey string `json:"apiKey"`
+	manifestURL := fmt.Sprintf("%s/%s/manifest.json", a.AddonHost, token)
+	installURL := "stremio://" + manifestURL
+	if _, rest, found := strings.Cut(manifestURL, "://"); found {
+		installURL = "stremio://" + rest
 	}
-	if err = json.Unmarshal(decoded, &config); err != nil {
-		return ""
+
+	writeJSON(w, r, ConfigValidateResponse{
+		Valid:       true,
+		UserConfig:  token,
+		ManifestURL: manifestURL,
+		InstallURL:  installURL,
+	})
+}
+
+// configValidateError maps the errors of StremioService.ValidateAPIKey to HTTP status codes and responses.
+func configValidateError(err error) (int, ConfigValidateResponse) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, subx.ErrUnauthorized):
+		return http.StatusUnauthorized, ConfigValidateResponse{Error: "invalid_api_key", Message: "SubX rejected the API key"}
+	case errors.Is(err, subx.ErrRateLimited):
+		return http.StatusTooManyRequests, ConfigValidateResponse{Error: "rate_limited", Message: "SubX rate limit exceeded, try again later"}
+	case errors.Is(err, subx.ErrUnavailable), errors.As(err, &netErr):
+		return http.StatusBadGateway, ConfigValidateResponse{Error: "subx_unavailable", Message: "SubX can't be reached, try again later"}
+	default:
+		return http.StatusBadGateway, ConfigValidateResponse{Error: "subx_unavailable", Message: "SubX failed to validate the API key"}
+	}
+}
+
+// sealConfigRequest decodes the JSON user config in the request body and seals it into a userConfig token.
+// It writes the error response and returns false when the config can't be sealed.
+func (a *App) sealConfigRequest(w http.ResponseWriter, r *http.Request) (string, *userconfig.Config, bool) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	config := new(userconfig.Config)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*1024)).Decode(config); err != nil {
+		common.Log.WarnContext(ctx, "Failed to json.Decoder.Decode", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return "", nil, false
 	}
 
-	return strings.TrimSpace(config.APIKey)
+	if strings.TrimSpace(config.APIKey) == "" && a.FallbackKey == nil {
+		common.Log.WarnContext(ctx, "Failed to get api key", "err", fmt.Errorf("api key not found"))
+		span.RecordError(fmt.Errorf("api key not found"))
+		w.WriteHeader(http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	token, err := userconfig.Seal(a.UserConfigSealer, config)
+	if errors.Is(err, userconfig.ErrInvalid) {
+		common.Log.WarnContext(ctx, "Failed to userconfig.Seal", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return "", nil, false
+	} else if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to userconfig.Seal", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return "", nil, false
+	}
+
+	return token, config, true
 }
 
 /*
@@ -104,70 +358,83 @@ func (a *App) SubtitlesHandler(w http.ResponseWriter, r *http.Request) {
 
 	common.Log.DebugContext(ctx, "SubtitlesHandler")
 
-	paramsType := chi.URLParam(r, "type")
-	if err := common.ValidateSubtitleType(paramsType); err != nil {
-		common.Log.WarnContext(ctx, "Failed to common.ValidateSubtitleType", "err", err)
+	request, err := stremio.ParseRequestPath(r.URL.EscapedPath())
+	if err != nil {
+		common.Log.WarnContext(ctx, "Failed to stremio.ParseRequestPath", "err", err)
 		span.RecordError(err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	span.SetAttributes(attribute.String("params.type", paramsType))
 
-	paramsID, err := url.PathUnescape(chi.URLParam(r, "id"))
-	if err != nil {
-		common.Log.WarnContext(ctx, "Failed to url.PathUnescape", "err", err)
+	if err = common.ValidateSubtitleType(request.Type); err != nil {
+		common.Log.WarnContext(ctx, "Failed to common.ValidateSubtitleType", "err", err)
 		span.RecordError(err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	span.SetAttributes(attribute.String("param.id", paramsID))
-
-	var imdbID string
-	var seasonNumber, episodeNumber int
+	span.SetAttributes(attribute.String("params.type", request.Type))
+	span.SetAttributes(attribute.String("param.id", request.ID))
+
+	videoID, err := a.resolveVideoID(request.ID)
+	if errors.Is(err, animeid.ErrUnknown) {
+		// Anime catalogs ask for every title, the ones missing from the mapping just have no subtitles
+		common.Log.InfoContext(ctx, "Failed to resolveVideoID", "err", err)
+		w.Header().Set("CDN-Cache-Control", "public, max-age=600")
+		w.Header().Set("Cache-Control", "public, max-age=600")
+		writeJSON(w, r, stremio.Subtitles{Subtitles: []stremio.Subtitle{}})
+		return
+	} else if err != nil {
+		common.Log.WarnContext(ctx, "Failed to resolveVideoID", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.String("imdb.id", videoID.TitleID))
 
-	paramsIds := strings.Split(paramsID, ":")
-	imdbID = paramsIds[0]
-	if err = common.ValidateIMDBTitleID(imdbID); err != nil {
+	if err = common.ValidateIMDBTitleID(videoID.TitleID); err != nil {
 		common.Log.WarnContext(ctx, "Failed to common.ValidateIMDBTitleID", "err", err)
 		span.RecordError(err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	if len(paramsIds) == 3 {
-		seasonNumber, err = strconv.Atoi(paramsIds[1])
-		if err != nil {
-			common.Log.WarnContext(ctx, "Failed to convert season to a number", "err", err)
-		}
-		episodeNumber, err = strconv.Atoi(paramsIds[2])
-		if err != nil {
-			common.Log.WarnContext(ctx, "Failed to convert episode to a number", "err", err)
-		}
+	if request.Extra.Filename == "" {
+		common.Log.WarnContext(ctx, "Failed to get extra filename", "err", fmt.Errorf("filename not found"))
 	}
 
-	paramsWildcard := chi.URLParam(r, "*")
-	var queryFilename string
-	if queryValues, err := url.ParseQuery(paramsWildcard); err != nil {
-		common.Log.WarnContext(ctx, "Failed to url.ParseQuery", "err", err)
-		span.RecordError(err)
-		w.WriteHeader(http.StatusBadRequest)
+	config := userconfig.FromContext(ctx)
+	ctx, apiKey, ok := a.resolveAPIKey(w, r, config, request.Config)
+	if !ok {
 		return
-	} else if queryFilename = queryValues.Get("filename"); queryFilename == "" {
-		common.Log.WarnContext(ctx, "Failed to url.Values.Get(filename)", "err", fmt.Errorf("filename not found"))
 	}
 
-	userConfig := chi.URLParam(r, "userConfig")
-	apiKey := apiKeyFromUserConfig(userConfig)
-	if apiKey == "" {
-		common.Log.WarnContext(ctx, "Failed to apiKeyFromUserConfig", "err", fmt.Errorf("api key not found"))
-		span.RecordError(fmt.Errorf("api key not found"))
-		w.WriteHeader(http.StatusBadRequest)
+	video := videohash.Video{Hash: request.Extra.VideoHash, Size: request.Extra.VideoSize}
+	span.SetAttributes(attribute.Bool("params.video-hash", video.Valid()))
+
+	subtitles, err := a.StremioService.GetSubtitles(ctx, apiKey, request.Type, videoID.TitleID, videoID.Season, videoID.Episode, request.Extra.Filename, SubtitlesOptions{
+		Video:               video,
+		MaxResults:          config.MaxResults,
+		Dialect:             config.Dialect,
+		HideHearingImpaired: config.HideHearingImpaired,
+		PublicStats:         config.SharesPublicStats(),
+	})
+	var rateLimitErr *subx.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		common.Log.WarnContext(ctx, "Failed to StremioService.GetSubtitles", "err", err)
+		span.RecordError(err)
+		writeRetryAfter(w, rateLimitErr.RetryAfter)
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to StremioService.GetSubtitles", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	subtitles, err := a.StremioService.GetSubtitles(ctx, apiKey, paramsType, imdbID, seasonNumber, episodeNumber, queryFilename)
+	userRef, err := a.UserConfigStore.Put(ctx, request.Config)
 	if err != nil {
-		common.Log.ErrorContext(ctx, "Failed to StremioService.GetSubtitles", "err", err)
+		common.Log.ErrorContext(ctx, "Failed to userconfig.Store.Put", "err", err)
 		span.RecordError(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -176,11 +443,12 @@ func (a *App) SubtitlesHandler(w http.ResponseWriter, r *http.Request) {
 	response := stremio.Subtitles{
 		Subtitles: make([]stremio.Subtitle, 0, len(subtitles.IDs)),
 	}
+	downloadQuery := subtitleDownloadQuery(video, feedback.TitleKey(videoID.TitleID, videoID.Season, videoID.Episode), feedback.NormalizeRelease(request.Extra.Filename))
 	for _, id := range subtitles.IDs {
 		response.Subtitles = append(response.Subtitles, stremio.Subtitle{
 			ID:   id,
 			Lang: subtitles.Lang,
-			URL:  fmt.Sprintf("%s/%s/subx/%s", a.AddonHost, userConfig, id),
+			URL:  a.signedSubtitleURL(id, userRef, downloadQuery),
 		})
 	}
 
@@ -200,6 +468,7 @@ func (a *App) SubtitlesHandler(w http.ResponseWriter, r *http.Request) {
 SubXSubtitleHandler handles requests for a specific subtitle by ID.
 
 This method validates the subtitle ID, fetches the subtitle data, and writes it to the response with the appropriate content type.
+It serves the legacy URLs that embed the userConfig, kept while the listed subtitles move to SignedSubtitleHandler URLs.
 */
 func (a *App) SubXSubtitleHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -207,25 +476,193 @@ func (a *App) SubXSubtitleHandler(w http.ResponseWriter, r *http.Request) {
 
 	common.Log.DebugContext(ctx, "SubXSubtitleHandler")
 
-	apiKey := apiKeyFromUserConfig(chi.URLParam(r, "userConfig"))
-	if apiKey == "" {
-		common.Log.WarnContext(ctx, "Failed to apiKeyFromUserConfig", "err", fmt.Errorf("api key not found"))
-		span.RecordError(fmt.Errorf("api key not found"))
+	paramsID := chi.URLParam(r, "id")
+	if err := common.ValidateSubXSubtitleID(paramsID); err != nil {
+		common.Log.WarnContext(ctx, "Failed to common.ValidateSubXSubtitleID", "err", err)
+		span.RecordError(err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	span.SetAttributes(attribute.String("param.id", paramsID))
+
+	config := userconfig.FromContext(ctx)
+	ctx, apiKey, ok := a.resolveAPIKey(w, r, config, chi.URLParam(r, "userConfig"))
+	if !ok {
+		return
+	}
 
+	a.serveSubtitle(w, r.WithContext(ctx), apiKey, paramsID, subtitleDownloadOptions(r, config, ""), 1296000)
+}
+
+/*
+SignedSubtitleHandler handles requests for a specific subtitle by ID through a signed short-lived token.
+
+The token binds the subtitle ID, an expiry and the opaque reference the user config was stored under, so neither the API key nor
+the userConfig travel in the subtitle URLs. Expired tokens answer 410 so players don't retry them.
+*/
+func (a *App) SignedSubtitleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "SignedSubtitleHandler")
+
+	// The IDs of the providers other than SubX are qualified with their namespace, as in "local:abc"
 	paramsID := chi.URLParam(r, "id")
-	if err := common.ValidateSubXSubtitleID(paramsID); err != nil {
-		common.Log.WarnContext(ctx, "Failed to common.ValidateSubXSubtitleID", "err", err)
+	if _, _, err := a.StremioService.Providers.Resolve(paramsID); err != nil {
+		common.Log.WarnContext(ctx, "Failed to provider.Aggregator.Resolve", "err", err)
 		span.RecordError(err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 	span.SetAttributes(attribute.String("param.id", paramsID))
 
-	data, err := a.StremioService.GetSubtitle(ctx, apiKey, paramsID)
+	// The title, release and video feed the feedback and video hash rankings, see subtitleDownloadBound
+	claims, err := a.SubtitleURLSigner.Verify(chi.URLParam(r, "token"), paramsID, subtitleDownloadBound(r.URL.Query())...)
+	if err != nil {
+		common.Log.WarnContext(ctx, "Failed to subtitleurl.Signer.Verify", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(signedSubtitleErrorStatus(err))
+		return
+	}
+
+	token, err := a.UserConfigStore.Get(ctx, claims.UserRef)
+	if errors.Is(err, cache.ErrNotFound) {
+		common.Log.WarnContext(ctx, "Failed to userconfig.Store.Get", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusGone)
+		return
+	} else if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to userconfig.Store.Get", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	config, err := userconfig.Parse(a.UserConfigSealer, token)
 	if err != nil {
+		common.Log.WarnContext(ctx, "Failed to userconfig.Parse", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(userConfigErrorStatus(err))
+		return
+	}
+
+	ctx, apiKey, ok := a.resolveAPIKey(w, r, config, token)
+	if !ok {
+		return
+	}
+
+	a.serveSubtitle(w, r.WithContext(ctx), apiKey, paramsID, subtitleDownloadOptions(r, config, claims.UserRef), int(time.Until(claims.ExpiresAt).Seconds()))
+}
+
+// signedSubtitleErrorStatus maps the errors of subtitleurl.Signer.Verify to HTTP status codes.
+func signedSubtitleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, subtitleurl.ErrExpired):
+		return http.StatusGone
+	case errors.Is(err, subtitleurl.ErrInvalidSignature):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// resolveVideoID parses a content ID, mapping the anime IDs to their IMDB title, season and episode.
+func (a *App) resolveVideoID(id string) (stremio.VideoID, error) {
+	if a.AnimeIDs == nil || !animeid.IsAnimeID(id) {
+		return stremio.ParseVideoID(id)
+	}
+
+	resolved, err := a.AnimeIDs.Resolve(id)
+	if err != nil {
+		return stremio.VideoID{}, fmt.Errorf("failed to animeid.Resolver.Resolve: %w", err)
+	}
+
+	return stremio.VideoID{
+		TitleID: resolved.IMDBID,
+		Season:  resolved.Season,
+		Episode: resolved.Episode,
+	}, nil
+}
+
+// subtitleDownloadQuery returns the query string of the subtitle download URLs, carrying what the subtitle was listed
+// for so the episode file is picked from the archives and the download counts towards the video hash and feedback rankings.
+func subtitleDownloadQuery(video videohash.Video, titleKey string, release string) string {
+	values := url.Values{}
+	if video.Valid() {
+		values.Set("videoHash", video.Hash)
+		values.Set("videoSize", strconv.FormatInt(video.Size, 10))
+	}
+	if titleKey != "" {
+		values.Set("title", titleKey)
+	}
+	if release != "" {
+		values.Set("release", release)
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
+
+// subtitleDownloadBound returns the values of a subtitleDownloadQuery the signed URLs are bound to, the ones feeding the
+// rankings, so they can't be forged to boost a subtitle for another title, release or video file.
+func subtitleDownloadBound(query url.Values) []string {
+	return []string{query.Get("title"), query.Get("release"), query.Get("videoHash"), query.Get("videoSize")}
+}
+
+// signedSubtitleURL returns the download URL of a subtitle, signed for the user config stored under userRef and for the
+// subtitleDownloadBound values of the subtitleDownloadQuery it carries.
+func (a *App) signedSubtitleURL(id string, userRef string, downloadQuery string) string {
+	values, _ := url.ParseQuery(strings.TrimPrefix(downloadQuery, "?"))
+	token := a.SubtitleURLSigner.Sign(id, userRef, subtitleDownloadBound(values)...)
+	return fmt.Sprintf("%s/subtitle/%s/%s%s", a.AddonHost, token, id, downloadQuery)
+}
+
+// subtitleDownloadOptions returns the options of a subtitle download, from the user config preferences and the
+// subtitleDownloadQuery of the URL. Malformed values are dropped, they only feed the rankings. The downloads without
+// userRef come from unsigned URLs, their title only picks the episode file and nothing feeds the rankings. Neither do
+// the downloads of the installs without a user config, they share userconfig.AnonymousRef and would count as one.
+func subtitleDownloadOptions(r *http.Request, config *userconfig.Config, userRef string) SubtitleOptions {
+	if userRef == userconfig.AnonymousRef {
+		userRef = ""
+	}
+	query := r.URL.Query()
+	videoSize, _ := strconv.ParseInt(query.Get("videoSize"), 10, 64)
+
+	options := SubtitleOptions{
+		Format:  config.Format,
+		Charset: config.Charset,
+		UserRef: userRef,
+	}
+	if userRef != "" {
+		options.Video = videohash.Video{Hash: query.Get("videoHash"), Size: videoSize}
+	}
+
+	if videoID, err := stremio.ParseVideoID(query.Get("title")); err == nil && common.ValidateIMDBTitleID(videoID.TitleID) == nil {
+		options.Season, options.Episode = videoID.Season, videoID.Episode
+		if userRef != "" {
+			options.TitleKey = feedback.TitleKey(videoID.TitleID, videoID.Season, videoID.Episode)
+			options.Release = feedback.NormalizeRelease(query.Get("release"))
+		}
+	}
+
+	return options
+}
+
+// serveSubtitle fetches the subtitle with the API key and options and writes it, cacheable for maxAge seconds.
+func (a *App) serveSubtitle(w http.ResponseWriter, r *http.Request, apiKey string, id string, options SubtitleOptions, maxAge int) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	data, err := a.StremioService.GetSubtitle(ctx, apiKey, id, options)
+	var rateLimitErr *subx.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		common.Log.WarnContext(ctx, "Failed to StremioService.GetSubtitle", "err", err)
+		span.RecordError(err)
+		writeRetryAfter(w, rateLimitErr.RetryAfter)
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	} else if err != nil {
 		common.Log.ErrorContext(ctx, "Failed to StremioService.GetSubtitle", "err", err)
 		span.RecordError(err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -233,9 +670,9 @@ func (a *App) SubXSubtitleHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/force-download")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.srt\"", paramsID))
-	w.Header().Set("CDN-Cache-Control", "public, max-age=1296000")
-	w.Header().Set("Cache-Control", "public, max-age=1296000")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", strings.ReplaceAll(id, ":", "-"), options.Format))
+	w.Header().Set("CDN-Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
 
 	_, err = w.Write(data)
 	if err != nil {
@@ -245,6 +682,63 @@ func (a *App) SubXSubtitleHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// QuotaResponse is the JSON response of the quota endpoint.
+type QuotaResponse struct {
+	// Known reports whether the quota of the API key is known, the other fields are empty otherwise.
+	Known bool `json:"known"`
+	// Limit is the number of requests allowed per window.
+	Limit int `json:"limit,omitempty"`
+	// Remaining is the number of requests left right now.
+	Remaining int `json:"remaining"`
+	// WindowSeconds is the period the limit refills over.
+	WindowSeconds int `json:"windowSeconds,omitempty"`
+	// ResetAt is when the quota is expected to be fully refilled.
+	ResetAt *time.Time `json:"resetAt,omitempty"`
+	// UpdatedAt is when SubX last reported the quota, nil if it never did.
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// QuotaHandler serves the known SubX quota of the API key of the user config, so the configure page can show it.
+func (a *App) QuotaHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "QuotaHandler")
+
+	config, err := requireAPIKey(r)
+	if err != nil {
+		common.Log.WarnContext(ctx, "Failed to requireAPIKey", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	response := QuotaResponse{}
+	if quota, known := a.StremioService.Quota(config.APIKey); known {
+		response = QuotaResponse{
+			Known:         true,
+			Limit:         quota.Limit,
+			Remaining:     quota.Remaining,
+			WindowSeconds: int(quota.Window.Seconds()),
+			ResetAt:       &quota.ResetAt,
+		}
+		if !quota.UpdatedAt.IsZero() {
+			response.UpdatedAt = &quota.UpdatedAt
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	writeJSON(w, r, response)
+}
+
+// writeRetryAfter sets the Retry-After header to retryAfter rounded up to seconds, if it's known.
+func writeRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+	}
+}
+
 // WebsocketHandler handles WebSocket connections
 func (a *App) WebsocketHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -253,3 +747,16 @@ func (a *App) WebsocketHandler(w http.ResponseWriter, r *http.Request) {
 
 	a.StremioService.ServeHTTP(w, r)
 }
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	ctx := r.Context()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to write response", "err", err)
+		trace.SpanFromContext(ctx).RecordError(err)
+	}
+}
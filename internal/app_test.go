@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ogero/stremio-subdivx/internal/common"
+	"github.com/ogero/stremio-subdivx/internal/subtitleurl"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/ogero/stremio-subdivx/internal/videohash"
+	"github.com/ogero/stremio-subdivx/pkg/stremio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	common.Log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	os.Exit(m.Run())
+}
+
+func TestManifestHandlerConfigurationRequired(t *testing.T) {
+	sealer, err := userconfig.NewSealer([][]byte{make([]byte, 32)}, false)
+	require.NoError(t, err)
+	token, err := userconfig.Seal(sealer, &userconfig.Config{APIKey: "key"})
+	require.NoError(t, err)
+
+	app := &App{
+		StremioManifest:  &stremio.Manifest{BehaviorHints: stremio.BehaviorHints{Configurable: true, ConfigurationRequired: true}},
+		UserConfigSealer: sealer,
+	}
+	r := chi.NewRouter()
+	r.Handle("GET /manifest.json", http.HandlerFunc(app.ManifestHandler))
+	r.Handle("GET /{userConfig}/manifest.json", http.HandlerFunc(app.ManifestHandler))
+
+	tests := []struct {
+		name     string
+		path     string
+		required bool
+	}{
+		{name: "unconfigured", path: "/manifest.json", required: true},
+		{name: "configured", path: "/" + token + "/manifest.json", required: false},
+		{name: "malformed", path: "/not-a-token/manifest.json", required: true},
+		{name: "undecryptable", path: "/e1.AAAAAAAA.AAAA/manifest.json", required: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var manifest stremio.Manifest
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &manifest))
+			assert.Equal(t, tt.required, manifest.BehaviorHints.ConfigurationRequired)
+		})
+	}
+}
+
+func TestSignedSubtitleURLBindsDownloadQuery(t *testing.T) {
+	signer, err := subtitleurl.NewSigner(nil, time.Hour)
+	require.NoError(t, err)
+	app := &App{AddonHost: "https://addon.test", SubtitleURLSigner: signer}
+
+	video := videohash.Video{Hash: "8e245d9679d31e12", Size: 1073741824}
+	link, err := url.Parse(app.signedSubtitleURL("100", "user-ref", subtitleDownloadQuery(video, "tt0133093", "the matrix 1080p")))
+	require.NoError(t, err)
+	token := strings.Split(link.Path, "/")[2]
+
+	_, err = signer.Verify(token, "100", subtitleDownloadBound(link.Query())...)
+	require.NoError(t, err)
+
+	// The video the download proves the subtitle for can't be swapped
+	for param, value := range map[string]string{"videoHash": "0123456789abcdef", "videoSize": "1", "release": "other"} {
+		query := link.Query()
+		query.Set(param, value)
+		_, err = signer.Verify(token, "100", subtitleDownloadBound(query)...)
+		assert.ErrorIs(t, err, subtitleurl.ErrInvalidSignature, param)
+	}
+}
+
+func TestUserRateLimitKey(t *testing.T) {
+	signer, err := subtitleurl.NewSigner(nil, time.Hour)
+	require.NoError(t, err)
+	app := &App{SubtitleURLSigner: signer}
+
+	var key string
+	r := chi.NewRouter()
+	r.Handle("GET /subtitle/{token}/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { key = app.UserRateLimitKey(r) }))
+	r.Handle("GET /{userConfig}/subx/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { key = app.UserRateLimitKey(r) }))
+
+	token := signer.Sign("100", "user-ref", subtitleDownloadBound(nil)...)
+	tests := map[string]string{
+		"/token/subx/100":                       userconfig.Ref("token"),
+		"/subtitle/" + token + "/100":           "user-ref",
+		"/subtitle/" + token + "/200":           "",
+		"/subtitle/victim-ref.abc.forged/100":   "",
+		"/subtitle/" + token + "/100?title=tt1": "",
+	}
+	for target, expected := range tests {
+		key = "unset"
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, expected, key, target)
+	}
+}
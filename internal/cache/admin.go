@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrUnsupported is returned when the app global cache backend does not support an administration operation.
+var ErrUnsupported = errors.New("operation not supported by the cache backend")
+
+// Entry is a cache entry as seen by the administration operations.
+type Entry struct {
+	Key   string
+	Value []byte
+	// ExpiresAt is the entry expiration time, zero if it never expires.
+	ExpiresAt time.Time
+}
+
+// Inspector is implemented by the backends that can be inspected and edited while they are being used.
+type Inspector interface {
+	// Keys returns up to limit keys starting with prefix, limit <= 0 means no limit.
+	Keys(ctx context.Context, prefix string, limit int) ([]string, error)
+	// Entry returns the entry stored under key, or ErrNotFound if it's missing or expired.
+	Entry(ctx context.Context, key string) (*Entry, error)
+	// Delete removes the entry stored under key, it's not an error if it's missing.
+	Delete(ctx context.Context, key string) error
+	// DeletePrefix removes all the entries whose key starts with prefix and returns how many were removed.
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// Backuper is implemented by the backends that can stream a full backup of their contents and load it back.
+type Backuper interface {
+	// Backup writes a backup of all the entries to w.
+	Backup(ctx context.Context, w io.Writer) error
+	// Restore replaces all the entries with a backup previously written by Backup from r.
+	Restore(ctx context.Context, r io.Reader) error
+}
+
+// Keys returns up to limit keys of the app global cache starting with prefix.
+func Keys(ctx context.Context, prefix string, limit int) ([]string, error) {
+	inspector, ok := backend.(Inspector)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return inspector.Keys(ctx, prefix, limit)
+}
+
+// GetEntry returns the app global cache entry stored under key.
+func GetEntry(ctx context.Context, key string) (*Entry, error) {
+	inspector, ok := backend.(Inspector)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return inspector.Entry(ctx, key)
+}
+
+// Delete removes the app global cache entry stored under key.
+func Delete(ctx context.Context, key string) error {
+	inspector, ok := backend.(Inspector)
+	if !ok {
+		return ErrUnsupported
+	}
+	return inspector.Delete(ctx, key)
+}
+
+// DeletePrefix removes the app global cache entries whose key starts with prefix.
+func DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	inspector, ok := backend.(Inspector)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	return inspector.DeletePrefix(ctx, prefix)
+}
+
+// Backup writes a backup of the app global cache to w.
+func Backup(ctx context.Context, w io.Writer) error {
+	backuper, ok := backend.(Backuper)
+	if !ok {
+		return ErrUnsupported
+	}
+	return backuper.Backup(ctx, w)
+}
+
+// Restore loads a backup into the app global cache from r.
+func Restore(ctx context.Context, r io.Reader) error {
+	backuper, ok := backend.(Backuper)
+	if !ok {
+		return ErrUnsupported
+	}
+	return backuper.Restore(ctx, r)
+}
@@ -0,0 +1,398 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// BadgerOptions holds the Badger backend storage and maintenance settings.
+type BadgerOptions struct {
+	// Path is the directory where the database files are stored.
+	Path string
+	// MemTableSize is the size in bytes of each in-memory table.
+	MemTableSize int64
+	// NumMemtables is the maximum number of in-memory tables kept before stalling writes.
+	NumMemtables int
+	// GCInterval is the interval between maintenance runs, zero disables the maintenance loop.
+	GCInterval time.Duration
+	// GCDiscardRatio is the fraction of stale data a value log file must hold to be rewritten.
+	GCDiscardRatio float64
+	// MaxSize is the on-disk size in bytes above which the oldest memoized entries are evicted until the live ones fit in
+	// it, zero disables the cap. The state entries, see stateNamespaces, are never evicted.
+	MaxSize int64
+}
+
+type badgerBackend struct {
+	db      *badger.DB
+	opts    BadgerOptions
+	logger  *slog.Logger
+	done    chan struct{}
+	closing sync.Once
+	wg      sync.WaitGroup
+	// writes is held by the writes for reading and by Restore for writing, badger.DB.Load can't run alongside them.
+	writes sync.RWMutex
+}
+
+// NewBadgerBackend opens a Badger database with the given options and returns it as a cache Backend.
+// Unless disabled, a background loop periodically runs value log GC and enforces the size cap until the backend is closed.
+func NewBadgerBackend(opts BadgerOptions, logger *slog.Logger) (Backend, error) {
+	if opts.GCInterval > 0 && (opts.GCDiscardRatio <= 0 || opts.GCDiscardRatio >= 1) {
+		return nil, fmt.Errorf("invalid gc discard ratio %v, it must be between 0 and 1 exclusive", opts.GCDiscardRatio)
+	}
+
+	db, err := badger.Open(
+		badger.DefaultOptions(opts.Path).
+			WithNumVersionsToKeep(0).
+			WithValueLogFileSize(1024 * 1024 * 100).
+			WithMemTableSize(opts.MemTableSize).
+			WithNumMemtables(opts.NumMemtables).
+			WithLogger(&l{logger: logger}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize badger database: %w", err)
+	}
+
+	b := &badgerBackend{
+		db:     db,
+		opts:   opts,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	if opts.GCInterval > 0 {
+		b.wg.Add(1)
+		go b.maintenanceLoop()
+	}
+
+	return b, nil
+}
+
+// Get returns the value stored under key, or ErrNotFound if it's missing or expired.
+func (b *badgerBackend) Get(_ context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Set stores value under key, expiring it after ttl.
+func (b *badgerBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	b.writes.RLock()
+	defer b.writes.RUnlock()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry([]byte(key), value).WithTTL(ttl))
+	})
+}
+
+// Keys returns up to limit keys starting with prefix, limit <= 0 means no limit.
+func (b *badgerBackend) Keys(_ context.Context, prefix string, limit int) ([]string, error) {
+	keys := make([]string, 0)
+	err := b.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: []byte(prefix)})
+		defer it.Close()
+
+		for it.Rewind(); it.Valid() && (limit <= 0 || len(keys) < limit); it.Next() {
+			keys = append(keys, string(it.Item().Key()))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Entry returns the entry stored under key, or ErrNotFound if it's missing or expired.
+func (b *badgerBackend) Entry(_ context.Context, key string) (*Entry, error) {
+	entry := &Entry{Key: key}
+	err := b.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		if expiresAt := item.ExpiresAt(); expiresAt > 0 {
+			entry.ExpiresAt = time.Unix(int64(expiresAt), 0)
+		}
+		entry.Value, err = item.ValueCopy(nil)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Delete removes the entry stored under key, it's not an error if it's missing.
+func (b *badgerBackend) Delete(_ context.Context, key string) error {
+	b.writes.RLock()
+	defer b.writes.RUnlock()
+
+	return b.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(key))
+	})
+}
+
+// DeletePrefix removes all the entries whose key starts with prefix and returns how many were removed.
+// Unlike badger.DB.DropPrefix it doesn't block writes, so it's safe to use on a live database.
+func (b *badgerBackend) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	keys, err := b.Keys(ctx, prefix, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	b.writes.RLock()
+	defer b.writes.RUnlock()
+
+	wb := b.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, key := range keys {
+		if err = wb.Delete([]byte(key)); err != nil {
+			return 0, fmt.Errorf("failed to badger.WriteBatch.Delete: %w", err)
+		}
+	}
+
+	if err = wb.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to badger.WriteBatch.Flush: %w", err)
+	}
+
+	return len(keys), nil
+}
+
+// Backup writes a full backup of the database to w using badger stream backups.
+func (b *badgerBackend) Backup(_ context.Context, w io.Writer) error {
+	if _, err := b.db.Backup(w, 0); err != nil {
+		return fmt.Errorf("failed to badger.DB.Backup: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the database contents with a backup written by Backup from r. The writes are blocked until it's
+// done, as badger.DB.Load doesn't support concurrent writes, and the entries the backup doesn't have are dropped.
+func (b *badgerBackend) Restore(_ context.Context, r io.Reader) error {
+	b.writes.Lock()
+	defer b.writes.Unlock()
+
+	if err := b.db.DropAll(); err != nil {
+		return fmt.Errorf("failed to badger.DB.DropAll: %w", err)
+	}
+	if err := b.db.Load(r, 256); err != nil {
+		return fmt.Errorf("failed to badger.DB.Load: %w", err)
+	}
+	return nil
+}
+
+// Close stops the maintenance loop and closes the DB. Calling it multiple times would still only close the DB once.
+func (b *badgerBackend) Close() error {
+	b.closing.Do(func() {
+		close(b.done)
+	})
+	b.wg.Wait()
+
+	return b.db.Close()
+}
+
+func (b *badgerBackend) maintenanceLoop() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.opts.GCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			if err := b.maintenance(); err != nil {
+				b.logger.Warn("Failed to run cache maintenance", "err", err)
+			}
+		}
+	}
+}
+
+// maintenance enforces the size cap and then rewrites value log files until none is worth collecting.
+func (b *badgerBackend) maintenance() error {
+	if b.opts.MaxSize > 0 {
+		size, err := b.diskSize()
+		if err != nil {
+			return fmt.Errorf("failed to get disk size: %w", err)
+		}
+		if size > b.opts.MaxSize {
+			evicted, err := b.evictOldest(b.opts.MaxSize)
+			if err != nil {
+				return fmt.Errorf("failed to evict oldest entries: %w", err)
+			}
+			if evicted > 0 {
+				b.logger.Info("Evicted oldest cache entries", "count", evicted, "size", size, "max_size", b.opts.MaxSize)
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-b.done:
+			return nil
+		default:
+		}
+
+		err := b.db.RunValueLogGC(b.opts.GCDiscardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to badger.DB.RunValueLogGC: %w", err)
+		}
+	}
+}
+
+// diskSize returns the size in bytes of the table and value log files.
+// It's computed from the directory because badger.DB.Size is only refreshed once a minute.
+func (b *badgerBackend) diskSize() (int64, error) {
+	var size int64
+	err := filepath.WalkDir(b.opts.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch filepath.Ext(path) {
+		case ".sst", ".vlog":
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// stateNamespaces are the namespaces of the state kept in the backend besides the memoized values. Evicting them would
+// break the signed URLs, reset the fallback quotas and drop the download counts, so they only expire.
+var stateNamespaces = []string{"userconfig.", "fallback.", "videohash.", "feedback.", "opensubtitles."}
+
+// evictOldest deletes the memoized entries with the oldest commit versions until the estimated size of the live entries
+// is down to maxSize bytes, or there are none left. The disk size only shrinks once compactions and value log GC reclaim
+// the deleted entries, so the live size is what's compared, otherwise every maintenance run would evict another batch
+// until then.
+func (b *badgerBackend) evictOldest(maxSize int64) (int, error) {
+	type entry struct {
+		key     []byte
+		version uint64
+		size    int64
+	}
+
+	var entries []entry
+	var liveSize int64
+	err := b.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{})
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			liveSize += item.EstimatedSize()
+			if isStateKey(item.Key()) {
+				continue
+			}
+			entries = append(entries, entry{
+				key:     item.KeyCopy(nil),
+				version: item.Version(),
+				size:    item.EstimatedSize(),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if liveSize <= maxSize {
+		return 0, nil
+	}
+
+	b.writes.RLock()
+	defer b.writes.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].version < entries[j].version
+	})
+
+	wb := b.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	var evicted int
+	for _, e := range entries {
+		if liveSize <= maxSize {
+			break
+		}
+		if err = wb.Delete(e.key); err != nil {
+			return evicted, fmt.Errorf("failed to badger.WriteBatch.Delete: %w", err)
+		}
+		liveSize -= e.size
+		evicted++
+	}
+
+	if err = wb.Flush(); err != nil {
+		return evicted, fmt.Errorf("failed to badger.WriteBatch.Flush: %w", err)
+	}
+
+	return evicted, nil
+}
+
+// isStateKey reports whether key belongs to one of the stateNamespaces.
+func isStateKey(key []byte) bool {
+	for _, namespace := range stateNamespaces {
+		if bytes.HasPrefix(key, []byte(namespace)) {
+			return true
+		}
+	}
+	return false
+}
+
+// l adapts a slog.Logger to badger.Logger, only forwarding warnings and errors as badger is chatty on the lower levels.
+type l struct {
+	logger *slog.Logger
+}
+
+func (l *l) Errorf(s string, i ...interface{}) {
+	l.logger.Error(fmt.Sprintf(s, i...))
+}
+
+func (l *l) Warningf(s string, i ...interface{}) {
+	l.logger.Warn(fmt.Sprintf(s, i...))
+}
+
+func (l *l) Infof(string, ...interface{}) {}
+
+func (l *l) Debugf(string, ...interface{}) {}
@@ -1,79 +1,70 @@
 package cache
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"log/slog"
 	"time"
-
-	"github.com/dgraph-io/badger/v4"
 )
 
-var cacheDefaultPath = ".cache"
+// ErrNotFound is returned by a Backend when the requested key is missing or expired.
+var ErrNotFound = errors.New("cache key not found")
+
+// Backend is a byte oriented key/value store with per entry expiration used to back the app cache.
+type Backend interface {
+	// Get returns the value stored under key, or ErrNotFound if it's missing or expired.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key, expiring it after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Close releases the resources held by the backend.
+	Close() error
+}
 
-var badgerDB *badger.DB
+var backend Backend
 
-// InitCache initializes the app global cache
-func InitCache(logger *slog.Logger) error {
+var encoding Encoding
 
-	var err error
-	badgerDB, err = badger.Open(
-		badger.DefaultOptions(cacheDefaultPath).
-			WithNumVersionsToKeep(0).
-			WithValueLogFileSize(1024 * 1024 * 100).
-			WithLogger(&l{logger: logger}),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to initialize badger database: %w", err)
-	}
-
-	return nil
+// InitCache initializes the app global cache with the given backend and values encoding
+func InitCache(b Backend, e Encoding) {
+	backend = b
+	encoding = e
 }
 
-// Memoize retrieves a cached value for the specified cacheKey.
-// If the value is present and its type matches, it is returned. Otherwise, the provided function fn
-// is called to compute the value, which is then stored in the cache with the specified expiration
-// and returned. If the cached value has an unexpected type or if fn returns an error,
-// Memoize returns the corresponding error.
-func Memoize[V any](cacheKey string, ttl time.Duration, fn func() (*V, error)) (*V, error) {
-
-	value := new(V)
+// Memoize retrieves a cached value for the specified cacheKey from the app global cache.
+// If the value is present and was stored with the key schema version, it is returned. Otherwise, the provided
+// function fn is called to compute the value, which is then stored in the cache with the specified expiration
+// and returned. Values stored with another schema version, or that can't be decoded, are treated as missing
+// and overwritten. If fn returns an error, Memoize returns it.
+func Memoize[V any](ctx context.Context, cacheKey Key, ttl time.Duration, fn func() (*V, error)) (*V, error) {
+	return MemoizeWith(ctx, backend, encoding, cacheKey, ttl, fn)
+}
 
-	err := badgerDB.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(cacheKey))
-		if err != nil {
-			return err
-		}
+// MemoizeWith behaves like Memoize but uses the given backend and encoding instead of the app global cache ones.
+func MemoizeWith[V any](ctx context.Context, b Backend, e Encoding, cacheKey Key, ttl time.Duration, fn func() (*V, error)) (*V, error) {
 
-		err = item.Value(func(val []byte) error {
-			return json.Unmarshal(val, value)
-		})
-		if err != nil {
-			return fmt.Errorf("failed to json.Unmarshal: %w", err)
-		}
+	key := cacheKey.String()
 
-		return nil
-	})
-	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+	envelopeBytes, err := b.Get(ctx, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		return nil, fmt.Errorf("failed to get from cache: %w", err)
 	} else if err == nil {
-		return value, nil
+		value := new(V)
+		if err = e.decode(envelopeBytes, value, cacheKey.Version); err == nil {
+			return value, nil
+		}
 	}
 
-	value, err = fn()
+	value, err := fn()
 	if err != nil {
 		return nil, err
 	}
 
-	err = badgerDB.Update(func(txn *badger.Txn) error {
-		valueJSONBytes, err := json.Marshal(value)
-		if err != nil {
-			return fmt.Errorf("failed to json.Marshal: %w", err)
-		}
-		entry := badger.NewEntry([]byte(cacheKey), valueJSONBytes).WithTTL(ttl)
-		return txn.SetEntry(entry)
-	})
+	envelopeBytes, err = e.encode(value, cacheKey.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.Set(ctx, key, envelopeBytes, ttl)
 	if err != nil {
 		return nil, fmt.Errorf("failed to store on cache: %w", err)
 	}
@@ -81,27 +72,10 @@ func Memoize[V any](cacheKey string, ttl time.Duration, fn func() (*V, error)) (
 	return value, nil
 }
 
-// Close closes the cache DB. It's crucial to call it to ensure all the pending updates make their way to disk. Calling DB.Close() multiple times would still only close the DB once.
+// Close closes the app global cache backend. It's crucial to call it to ensure all the pending updates make their way to storage.
 func Close() error {
-	return badgerDB.Close()
-}
-
-type l struct {
-	logger *slog.Logger
-}
-
-func (l *l) Errorf(s string, i ...interface{}) {
-	l.logger.Error(fmt.Sprintf(s, i...))
-}
-
-func (l *l) Warningf(s string, i ...interface{}) {
-	l.logger.Warn(fmt.Sprintf(s, i...))
-}
-
-func (l *l) Infof(s string, i ...interface{}) {
-	l.logger.Info(fmt.Sprintf(s, i...))
-}
-
-func (l *l) Debugf(s string, i ...interface{}) {
-	l.logger.Debug(fmt.Sprintf(s, i...))
+	if backend == nil {
+		return nil
+	}
+	return backend.Close()
 }
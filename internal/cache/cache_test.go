@@ -0,0 +1,313 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackends(t *testing.T) {
+	backends := map[string]func(t *testing.T) Backend{
+		"badger": func(t *testing.T) Backend {
+			b, err := NewBadgerBackend(BadgerOptions{
+				Path:           t.TempDir(),
+				MemTableSize:   64 << 20,
+				NumMemtables:   1,
+				GCInterval:     time.Millisecond,
+				GCDiscardRatio: 0.5,
+				MaxSize:        1 << 30,
+			}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			require.NoError(t, err)
+			return b
+		},
+		"memory": func(t *testing.T) Backend {
+			return NewMemoryBackend(1024 * 1024)
+		},
+		"redis": func(t *testing.T) Backend {
+			b, err := NewRedisBackend(miniredis.RunT(t).Addr(), "", 0)
+			require.NoError(t, err)
+			return b
+		},
+	}
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			b := newBackend(t)
+			defer b.Close()
+
+			_, err := b.Get(ctx, "missing")
+			assert.True(t, errors.Is(err, ErrNotFound), "expected ErrNotFound, got %v", err)
+
+			require.NoError(t, b.Set(ctx, "key", []byte("value"), time.Hour))
+			value, err := b.Get(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, "value", string(value))
+
+			require.NoError(t, b.Set(ctx, "key", []byte("updated"), time.Hour))
+			value, err = b.Get(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, "updated", string(value))
+
+			inspector := b.(Inspector)
+			require.NoError(t, b.Set(ctx, "subx.subtitles : a", []byte("a"), time.Hour))
+			require.NoError(t, b.Set(ctx, "subx.subtitles : b", []byte("b"), time.Hour))
+
+			keys, err := inspector.Keys(ctx, "subx.", 0)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"subx.subtitles : a", "subx.subtitles : b"}, keys)
+
+			keys, err = inspector.Keys(ctx, "", 1)
+			require.NoError(t, err)
+			assert.Len(t, keys, 1)
+
+			entry, err := inspector.Entry(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, "updated", string(entry.Value))
+			assert.WithinDuration(t, time.Now().Add(time.Hour), entry.ExpiresAt, 2*time.Second)
+
+			require.NoError(t, inspector.Delete(ctx, "key"))
+			_, err = inspector.Entry(ctx, "key")
+			assert.True(t, errors.Is(err, ErrNotFound), "expected ErrNotFound, got %v", err)
+
+			deleted, err := inspector.DeletePrefix(ctx, "subx.")
+			require.NoError(t, err)
+			assert.Equal(t, 2, deleted)
+			keys, err = inspector.Keys(ctx, "", 0)
+			require.NoError(t, err)
+			assert.Empty(t, keys)
+		})
+	}
+}
+
+func TestBadgerBackendBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	newBackend := func() Backend {
+		b, err := NewBadgerBackend(BadgerOptions{
+			Path:         t.TempDir(),
+			MemTableSize: 64 << 20,
+			NumMemtables: 1,
+		}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		require.NoError(t, err)
+		return b
+	}
+
+	source := newBackend()
+	defer source.Close()
+	require.NoError(t, source.Set(ctx, "key", []byte("value"), time.Hour))
+
+	backup := new(bytes.Buffer)
+	require.NoError(t, source.(Backuper).Backup(ctx, backup))
+
+	target := newBackend()
+	defer target.Close()
+	require.NoError(t, target.Set(ctx, "stale", []byte("value"), time.Hour))
+	require.NoError(t, target.(Backuper).Restore(ctx, backup))
+
+	value, err := target.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(value))
+	_, err = target.Get(ctx, "stale")
+	assert.True(t, errors.Is(err, ErrNotFound), "expected stale to be dropped, got %v", err)
+
+	// Writes keep working after the restore
+	require.NoError(t, target.Set(ctx, "key", []byte("updated"), time.Hour))
+}
+
+func TestBadgerBackendEvictsOldestEntries(t *testing.T) {
+	ctx := context.Background()
+	b, err := NewBadgerBackend(BadgerOptions{
+		Path:         t.TempDir(),
+		MemTableSize: 64 << 20,
+		NumMemtables: 1,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	defer b.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, b.Set(ctx, key, []byte("value"), time.Hour))
+	}
+
+	// Keep room for two of the three entries
+	var entrySize int64
+	err = b.(*badgerBackend).db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("a"))
+		if err != nil {
+			return err
+		}
+		entrySize = item.EstimatedSize()
+		return nil
+	})
+	require.NoError(t, err)
+
+	evicted, err := b.(*badgerBackend).evictOldest(2 * entrySize)
+	require.NoError(t, err)
+	assert.Equal(t, 1, evicted)
+
+	// The deleted entries still take disk space until they're reclaimed, but they aren't evicted again
+	evicted, err = b.(*badgerBackend).evictOldest(2 * entrySize)
+	require.NoError(t, err)
+	assert.Equal(t, 0, evicted)
+
+	_, err = b.Get(ctx, "a")
+	assert.True(t, errors.Is(err, ErrNotFound), "expected a to be evicted, got %v", err)
+	_, err = b.Get(ctx, "b")
+	assert.NoError(t, err)
+	_, err = b.Get(ctx, "c")
+	assert.NoError(t, err)
+}
+
+func TestBadgerBackendKeepsStateEntries(t *testing.T) {
+	ctx := context.Background()
+	b, err := NewBadgerBackend(BadgerOptions{
+		Path:         t.TempDir(),
+		MemTableSize: 64 << 20,
+		NumMemtables: 1,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	defer b.Close()
+
+	// The signed URLs reference, the oldest entry, outlives the memoized ones
+	ref := NewKey("userconfig.ref", 1, "0123456789abcdef").String()
+	require.NoError(t, b.Set(ctx, ref, []byte("token"), time.Hour))
+	require.NoError(t, b.Set(ctx, NewKey("subx.subtitles", 1, "movie", "tt0133093").String(), []byte("value"), time.Hour))
+
+	evicted, err := b.(*badgerBackend).evictOldest(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, evicted)
+
+	value, err := b.Get(ctx, ref)
+	require.NoError(t, err)
+	assert.Equal(t, "token", string(value))
+}
+
+func TestBadgerBackendRejectsInvalidDiscardRatio(t *testing.T) {
+	_, err := NewBadgerBackend(BadgerOptions{
+		Path:           t.TempDir(),
+		GCInterval:     time.Minute,
+		GCDiscardRatio: 1,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	assert.Error(t, err)
+}
+
+func TestMemoryBackendEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend(10)
+
+	require.NoError(t, b.Set(ctx, "a", []byte("aaa"), time.Hour))
+	require.NoError(t, b.Set(ctx, "b", []byte("bbb"), time.Hour))
+	_, err := b.Get(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, b.Set(ctx, "c", []byte("ccc"), time.Hour))
+
+	_, err = b.Get(ctx, "b")
+	assert.True(t, errors.Is(err, ErrNotFound), "expected b to be evicted, got %v", err)
+	_, err = b.Get(ctx, "a")
+	assert.NoError(t, err)
+	_, err = b.Get(ctx, "c")
+	assert.NoError(t, err)
+
+	require.NoError(t, b.Set(ctx, "d", []byte("this value is too large"), time.Hour))
+	_, err = b.Get(ctx, "d")
+	assert.True(t, errors.Is(err, ErrNotFound), "expected oversized value to be skipped, got %v", err)
+}
+
+func TestMemoryBackendExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	b := NewMemoryBackend(1024).(*memoryBackend)
+	b.now = func() time.Time { return now }
+
+	require.NoError(t, b.Set(ctx, "key", []byte("value"), time.Minute))
+	_, err := b.Get(ctx, "key")
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	_, err = b.Get(ctx, "key")
+	assert.True(t, errors.Is(err, ErrNotFound), "expected ErrNotFound, got %v", err)
+	assert.Equal(t, 0, b.size)
+}
+
+func TestMemoizeWith(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend(1024)
+
+	type value struct {
+		Name string
+	}
+
+	calls := 0
+	fn := func() (*value, error) {
+		calls++
+		return &value{Name: "subtitle"}, nil
+	}
+
+	for range 2 {
+		v, err := MemoizeWith(ctx, b, Encoding{}, NewKey("test", 1, "key"), time.Hour, fn)
+		require.NoError(t, err)
+		assert.Equal(t, "subtitle", v.Name)
+	}
+	assert.Equal(t, 1, calls)
+
+	failingKey := NewKey("test", 1, "failing")
+	_, err := MemoizeWith(ctx, b, Encoding{}, failingKey, time.Hour, func() (*value, error) {
+		return nil, errors.New("boom")
+	})
+	assert.EqualError(t, err, "boom")
+	_, err = b.Get(ctx, failingKey.String())
+	assert.True(t, errors.Is(err, ErrNotFound), "expected errors not to be cached, got %v", err)
+}
+
+func TestMemoizeWithInvalidatesOtherSchemaVersions(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend(1024)
+
+	type value struct {
+		Name string
+	}
+
+	key := NewKey("test", 2, "key")
+	calls := 0
+	fn := func() (*value, error) {
+		calls++
+		return &value{Name: "fresh"}, nil
+	}
+
+	oldVersion, err := Encoding{}.encode(&value{Name: "old"}, 1)
+	require.NoError(t, err)
+	wrongType, err := Encoding{}.encode("not a value", 2)
+	require.NoError(t, err)
+
+	for _, stored := range [][]byte{
+		[]byte(`{"Name":"legacy"}`),
+		oldVersion,
+		wrongType,
+	} {
+		require.NoError(t, b.Set(ctx, key.String(), stored, time.Hour))
+
+		v, err := MemoizeWith(ctx, b, Encoding{}, key, time.Hour, fn)
+		require.NoError(t, err)
+		assert.Equal(t, "fresh", v.Name)
+	}
+	assert.Equal(t, 3, calls)
+
+	v, err := MemoizeWith(ctx, b, Encoding{}, key, time.Hour, fn)
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", v.Name)
+	assert.Equal(t, 3, calls)
+}
+
+func TestKeyString(t *testing.T) {
+	key := NewKey("subx.subtitles", 1, "series", "tt0903747", 2, 5)
+	assert.Equal(t, "subx.subtitles:v1:", key.Prefix())
+	assert.Equal(t, "subx.subtitles:v1:series:tt0903747:2:5", key.String())
+}
@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes and decodes the cached values.
+type Codec interface {
+	// ID identifies the codec inside the stored envelopes, it must be unique and never change.
+	ID() byte
+	// Name is the codec name used in the configuration.
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// JSON encodes values with encoding/json.
+	JSON Codec = jsonCodec{}
+	// Gob encodes values with encoding/gob.
+	Gob Codec = gobCodec{}
+	// Msgpack encodes values with MessagePack.
+	Msgpack Codec = msgpackCodec{}
+)
+
+var codecs = []Codec{JSON, Gob, Msgpack}
+
+// CodecByName returns the codec with the given name.
+func CodecByName(name string) (Codec, error) {
+	for _, codec := range codecs {
+		if codec.Name() == name {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown cache codec %q", name)
+}
+
+func codecByID(id byte) (Codec, error) {
+	for _, codec := range codecs {
+		if codec.ID() == id {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown cache codec id %d", id)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte                           { return 1 }
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) ID() byte     { return 2 }
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte                           { return 3 }
+func (msgpackCodec) Name() string                       { return "msgpack" }
+func (msgpackCodec) Marshal(v any) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
+
+const (
+	// envelopeMagic marks the values stored by Memoize, anything else is treated as stale.
+	envelopeMagic = 0xC5
+	// envelopeHeaderSize is the size of magic, codec id, flags, schema version and creation time.
+	envelopeHeaderSize = 1 + 1 + 1 + 4 + 8
+	// envelopeFlagZstd marks a zstd compressed payload.
+	envelopeFlagZstd = 1 << 0
+	// maxDecompressedSize bounds the memory used to decompress a payload.
+	maxDecompressedSize = 64 * 1024 * 1024
+)
+
+var errInvalidEnvelope = errors.New("invalid cache envelope")
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedSize), zstd.WithDecoderConcurrency(0))
+)
+
+// envelope wraps the cached values with the schema version and the codec they were encoded with.
+type envelope struct {
+	Version   int
+	CreatedAt time.Time
+	Data      []byte
+}
+
+// Encoding turns values into stored envelopes and back.
+type Encoding struct {
+	// Codec encodes the values, JSON if nil.
+	Codec Codec
+	// CompressionThreshold is the encoded value size in bytes above which it's zstd compressed, zero disables compression.
+	CompressionThreshold int
+}
+
+// encode encodes v with the encoding codec into an envelope of the given schema version.
+func (e Encoding) encode(v any, version int) ([]byte, error) {
+	codec := e.Codec
+	if codec == nil {
+		codec = JSON
+	}
+
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s.Marshal: %w", codec.Name(), err)
+	}
+
+	var flags byte
+	if e.CompressionThreshold > 0 && len(data) > e.CompressionThreshold {
+		data = zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2))
+		flags |= envelopeFlagZstd
+	}
+
+	b := make([]byte, envelopeHeaderSize, envelopeHeaderSize+len(data))
+	b[0] = envelopeMagic
+	b[1] = codec.ID()
+	b[2] = flags
+	binary.BigEndian.PutUint32(b[3:7], uint32(version))
+	binary.BigEndian.PutUint64(b[7:15], uint64(time.Now().Unix()))
+
+	return append(b, data...), nil
+}
+
+// decode decodes an envelope into v whatever codec it was encoded with, as long as it matches the schema version.
+func (e Encoding) decode(b []byte, v any, version int) error {
+	env, codec, err := openEnvelope(b)
+	if err != nil {
+		return err
+	}
+	if env.Version != version {
+		return fmt.Errorf("%w: schema version %d, expected %d", errInvalidEnvelope, env.Version, version)
+	}
+
+	if err = codec.Unmarshal(env.Data, v); err != nil {
+		return fmt.Errorf("failed to %s.Unmarshal: %w", codec.Name(), err)
+	}
+
+	return nil
+}
+
+// openEnvelope parses the envelope header and decompresses its payload.
+func openEnvelope(b []byte) (*envelope, Codec, error) {
+	if len(b) < envelopeHeaderSize || b[0] != envelopeMagic {
+		return nil, nil, errInvalidEnvelope
+	}
+
+	codec, err := codecByID(b[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", errInvalidEnvelope, err)
+	}
+
+	env := &envelope{
+		Version:   int(binary.BigEndian.Uint32(b[3:7])),
+		CreatedAt: time.Unix(int64(binary.BigEndian.Uint64(b[7:15])), 0),
+		Data:      b[envelopeHeaderSize:],
+	}
+
+	if b[2]&envelopeFlagZstd != 0 {
+		env.Data, err = zstdDecoder.DecodeAll(env.Data, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to zstd.Decoder.DecodeAll: %w", err)
+		}
+	}
+
+	return env, codec, nil
+}
+
+// EnvelopeInfo describes a value stored by Memoize.
+type EnvelopeInfo struct {
+	Version    int
+	CreatedAt  time.Time
+	Codec      string
+	Compressed bool
+	// Value is the decoded value when the codec can decode it without knowing its type, like JSON and Msgpack, nil otherwise.
+	Value any
+}
+
+// InspectEnvelope describes a value stored by Memoize, it's meant for administration purposes.
+func InspectEnvelope(b []byte) (*EnvelopeInfo, error) {
+	env, codec, err := openEnvelope(b)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &EnvelopeInfo{
+		Version:    env.Version,
+		CreatedAt:  env.CreatedAt,
+		Codec:      codec.Name(),
+		Compressed: b[2]&envelopeFlagZstd != 0,
+	}
+	if codec != Gob {
+		if err = codec.Unmarshal(env.Data, &info.Value); err != nil {
+			return nil, fmt.Errorf("failed to %s.Unmarshal: %w", codec.Name(), err)
+		}
+	}
+
+	return info, nil
+}
@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadSubXSubtitles parses the recorded SubX search response the same way the addon does.
+func loadSubXSubtitles(tb testing.TB) *subx.Subtitles {
+	response, err := os.ReadFile("testdata/subx_search.json")
+	require.NoError(tb, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(response)
+	}))
+	defer server.Close()
+
+	client := subx.NewSubX()
+	client.BaseURL = server.URL
+
+	subtitles, err := client.SearchSubtitles(context.Background(), "api-key", subx.SearchParams{IMDBID: "tt0903747"})
+	require.NoError(tb, err)
+
+	return subtitles
+}
+
+// subtitleContents builds an SRT file similar in size and shape to the ones served by SubX.
+func subtitleContents() *subx.SubtitleContents {
+	lines := []string{
+		"¿Qué estás haciendo acá?",
+		"No podés estar en este lugar, Jesse.",
+		"Tenemos que cocinar, el tiempo se acaba.",
+		"Decime la verdad de una vez.",
+		"Yo soy el que golpea la puerta.",
+	}
+
+	srt := new(strings.Builder)
+	for i := 0; i < 700; i++ {
+		start := i * 3
+		fmt.Fprintf(srt, "%d\n00:%02d:%02d,000 --> 00:%02d:%02d,500\n%s\n%s\n\n",
+			i+1, start/60%60, start%60, (start+2)/60%60, (start+2)%60, lines[i%len(lines)], lines[(i+2)%len(lines)])
+	}
+
+	return &subx.SubtitleContents{
+		Name: "Breaking.Bad.S02E05.720p.WEB-DL.srt",
+		Data: []byte(srt.String()),
+	}
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	subtitles := loadSubXSubtitles(t)
+	require.Len(t, subtitles.Subtitles, 50)
+
+	for _, codec := range codecs {
+		for _, threshold := range []int{0, 1024} {
+			t.Run(fmt.Sprintf("%s/threshold=%d", codec.Name(), threshold), func(t *testing.T) {
+				e := Encoding{Codec: codec, CompressionThreshold: threshold}
+
+				b, err := e.encode(subtitles, 3)
+				require.NoError(t, err)
+
+				decoded := new(subx.Subtitles)
+				require.NoError(t, e.decode(b, decoded, 3))
+				assert.Equal(t, subtitles, decoded)
+
+				assert.Error(t, e.decode(b, new(subx.Subtitles), 4))
+
+				info, err := InspectEnvelope(b)
+				require.NoError(t, err)
+				assert.Equal(t, 3, info.Version)
+				assert.Equal(t, codec.Name(), info.Codec)
+				assert.Equal(t, threshold > 0, info.Compressed)
+				assert.Equal(t, codec != Gob, info.Value != nil)
+			})
+		}
+	}
+}
+
+func TestEncodingDecodesAnyCodec(t *testing.T) {
+	b, err := Encoding{Codec: Msgpack, CompressionThreshold: 1}.encode(subtitleContents(), 1)
+	require.NoError(t, err)
+
+	decoded := new(subx.SubtitleContents)
+	require.NoError(t, Encoding{Codec: JSON}.decode(b, decoded, 1))
+	assert.Equal(t, subtitleContents(), decoded)
+}
+
+func TestEncodingCompressesAboveThreshold(t *testing.T) {
+	contents := subtitleContents()
+
+	plain, err := Encoding{Codec: Gob}.encode(contents, 1)
+	require.NoError(t, err)
+	compressed, err := Encoding{Codec: Gob, CompressionThreshold: len(contents.Data) / 2}.encode(contents, 1)
+	require.NoError(t, err)
+	uncompressed, err := Encoding{Codec: Gob, CompressionThreshold: len(contents.Data) * 2}.encode(contents, 1)
+	require.NoError(t, err)
+
+	assert.Less(t, len(compressed), len(plain)/4)
+	assert.Equal(t, len(plain), len(uncompressed))
+}
+
+func BenchmarkEncoding(b *testing.B) {
+	payloads := map[string]any{
+		"subtitles": loadSubXSubtitles(b),
+		"contents":  subtitleContents(),
+	}
+
+	for payloadName, payload := range payloads {
+		for _, codec := range codecs {
+			for _, threshold := range []int{0, 1024} {
+				e := Encoding{Codec: codec, CompressionThreshold: threshold}
+				name := fmt.Sprintf("%s/%s/threshold=%d", payloadName, codec.Name(), threshold)
+
+				encoded, err := e.encode(payload, 1)
+				require.NoError(b, err)
+
+				b.Run(name+"/encode", func(b *testing.B) {
+					b.ReportAllocs()
+					for b.Loop() {
+						if _, err := e.encode(payload, 1); err != nil {
+							b.Fatal(err)
+						}
+					}
+					b.ReportMetric(float64(len(encoded)), "stored-bytes")
+				})
+
+				b.Run(name+"/decode", func(b *testing.B) {
+					b.ReportAllocs()
+					for b.Loop() {
+						var err error
+						switch payload.(type) {
+						case *subx.Subtitles:
+							err = e.decode(encoded, new(subx.Subtitles), 1)
+						case *subx.SubtitleContents:
+							err = e.decode(encoded, new(subx.SubtitleContents), 1)
+						}
+						if err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Key identifies a cache entry. Entries of a namespace share a schema version, which is part of both the key and the stored
+// value envelope, so bumping it invalidates every entry written with a previous schema.
+type Key struct {
+	// Namespace groups the entries holding the same kind of value, like "subx.subtitles".
+	Namespace string
+	// Version is the schema version of the values stored in the namespace.
+	Version int
+	// Parts identify the entry within the namespace.
+	Parts []string
+}
+
+// NewKey builds a Key for the given namespace and schema version. Parts are formatted with fmt's default format.
+func NewKey(namespace string, version int, parts ...any) Key {
+	key := Key{
+		Namespace: namespace,
+		Version:   version,
+		Parts:     make([]string, len(parts)),
+	}
+	for i, part := range parts {
+		key.Parts[i] = fmt.Sprint(part)
+	}
+
+	return key
+}
+
+// Prefix returns the prefix shared by all the keys of the namespace and schema version.
+func (k Key) Prefix() string {
+	return fmt.Sprintf("%s:v%d:", k.Namespace, k.Version)
+}
+
+// String returns the storage representation of the key, like "subx.subtitles:v1:movie:tt0133093:0:0".
+func (k Key) String() string {
+	return k.Prefix() + strings.Join(k.Parts, ":")
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+type memoryBackend struct {
+	mutex    sync.Mutex
+	maxBytes int
+	size     int
+	entries  map[string]*list.Element
+	lru      *list.List
+	now      func() time.Time
+}
+
+// NewMemoryBackend returns an in-memory LRU cache Backend that holds at most maxBytes of keys and values.
+// Least recently used entries are evicted when the bound is exceeded.
+func NewMemoryBackend(maxBytes int) Backend {
+	return &memoryBackend{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// Get returns the value stored under key, or ErrNotFound if it's missing or expired.
+func (m *memoryBackend) Get(_ context.Context, key string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if entry.expired(m.now()) {
+		m.remove(element)
+		return nil, ErrNotFound
+	}
+
+	m.lru.MoveToFront(element)
+
+	return entry.value, nil
+}
+
+// Set stores value under key, expiring it after ttl.
+// Values larger than the backend bound are not stored.
+func (m *memoryBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if element, ok := m.entries[key]; ok {
+		m.remove(element)
+	}
+
+	entrySize := len(key) + len(value)
+	if entrySize > m.maxBytes {
+		return nil
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = m.now().Add(ttl)
+	}
+
+	m.entries[key] = m.lru.PushFront(&memoryEntry{
+		key:       key,
+		value:     append([]byte(nil), value...),
+		expiresAt: expiresAt,
+	})
+	m.size += entrySize
+
+	for m.size > m.maxBytes {
+		m.remove(m.lru.Back())
+	}
+
+	return nil
+}
+
+// Keys returns up to limit keys starting with prefix sorted alphabetically, limit <= 0 means no limit.
+func (m *memoryBackend) Keys(_ context.Context, prefix string, limit int) ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.now()
+	keys := make([]string, 0)
+	for key, element := range m.entries {
+		if strings.HasPrefix(key, prefix) && !element.Value.(*memoryEntry).expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	return keys, nil
+}
+
+// Entry returns the entry stored under key, or ErrNotFound if it's missing or expired.
+func (m *memoryBackend) Entry(_ context.Context, key string) (*Entry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok || element.Value.(*memoryEntry).expired(m.now()) {
+		return nil, ErrNotFound
+	}
+
+	entry := element.Value.(*memoryEntry)
+
+	return &Entry{
+		Key:       key,
+		Value:     entry.value,
+		ExpiresAt: entry.expiresAt,
+	}, nil
+}
+
+// Delete removes the entry stored under key, it's not an error if it's missing.
+func (m *memoryBackend) Delete(_ context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if element, ok := m.entries[key]; ok {
+		m.remove(element)
+	}
+
+	return nil
+}
+
+// DeletePrefix removes all the entries whose key starts with prefix and returns how many were removed.
+func (m *memoryBackend) DeletePrefix(_ context.Context, prefix string) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var deleted int
+	for key, element := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			m.remove(element)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// Close drops all the entries.
+func (m *memoryBackend) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.entries = make(map[string]*list.Element)
+	m.lru.Init()
+	m.size = 0
+
+	return nil
+}
+
+func (m *memoryBackend) remove(element *list.Element) {
+	entry := m.lru.Remove(element).(*memoryEntry)
+	delete(m.entries, entry.key)
+	m.size -= len(entry.key) + len(entry.value)
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/rueidis"
+)
+
+// redisGlobEscaper escapes the glob special characters of a literal SCAN MATCH pattern.
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+type redisBackend struct {
+	client rueidis.Client
+}
+
+// NewRedisBackend connects to a Redis protocol compatible server at addr and returns it as a cache Backend.
+func NewRedisBackend(addr, password string, db int) (Backend, error) {
+	client, err := rueidis.NewClient(rueidis.ClientOption{
+		InitAddress:  []string{addr},
+		Password:     password,
+		SelectDB:     db,
+		DisableCache: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to rueidis.NewClient: %w", err)
+	}
+
+	return &redisBackend{client: client}, nil
+}
+
+// Get returns the value stored under key, or ErrNotFound if it's missing or expired.
+func (r *redisBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Do(ctx, r.client.B().Get().Key(key).Build()).AsBytes()
+	if rueidis.IsRedisNil(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Set stores value under key, expiring it after ttl.
+func (r *redisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return r.client.Do(ctx, r.client.B().Set().Key(key).Value(rueidis.BinaryString(value)).Build()).Error()
+	}
+
+	return r.client.Do(ctx, r.client.B().Set().Key(key).Value(rueidis.BinaryString(value)).Px(ttl).Build()).Error()
+}
+
+// Keys returns up to limit keys starting with prefix, limit <= 0 means no limit.
+func (r *redisBackend) Keys(ctx context.Context, prefix string, limit int) ([]string, error) {
+	keys := make([]string, 0)
+	match := redisGlobEscaper.Replace(prefix) + "*"
+
+	var cursor uint64
+	for {
+		entry, err := r.client.Do(ctx, r.client.B().Scan().Cursor(cursor).Match(match).Count(100).Build()).AsScanEntry()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range entry.Elements {
+			if limit > 0 && len(keys) >= limit {
+				return keys, nil
+			}
+			keys = append(keys, key)
+		}
+
+		cursor = entry.Cursor
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
+
+// Entry returns the entry stored under key, or ErrNotFound if it's missing or expired.
+func (r *redisBackend) Entry(ctx context.Context, key string) (*Entry, error) {
+	results := r.client.DoMulti(ctx,
+		r.client.B().Get().Key(key).Build(),
+		r.client.B().Pttl().Key(key).Build(),
+	)
+
+	value, err := results[0].AsBytes()
+	if rueidis.IsRedisNil(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	ttl, err := results[1].AsInt64()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		Key:   key,
+		Value: value,
+	}
+	if ttl > 0 {
+		entry.ExpiresAt = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+	}
+
+	return entry, nil
+}
+
+// Delete removes the entry stored under key, it's not an error if it's missing.
+func (r *redisBackend) Delete(ctx context.Context, key string) error {
+	return r.client.Do(ctx, r.client.B().Del().Key(key).Build()).Error()
+}
+
+// DeletePrefix removes all the entries whose key starts with prefix and returns how many were removed.
+func (r *redisBackend) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	keys, err := r.Keys(ctx, prefix, 0)
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	// Keys are deleted one per command as a multi key DEL must not span cluster slots
+	commands := make(rueidis.Commands, 0, len(keys))
+	for _, key := range keys {
+		commands = append(commands, r.client.B().Del().Key(key).Build())
+	}
+
+	var deleted int
+	for _, result := range r.client.DoMulti(ctx, commands...) {
+		n, err := result.AsInt64()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += int(n)
+	}
+
+	return deleted, nil
+}
+
+// Close closes the connections to the server.
+func (r *redisBackend) Close() error {
+	r.client.Close()
+	return nil
+}
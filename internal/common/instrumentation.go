@@ -101,6 +101,13 @@ var CacheGetsTotalIncr func(ctx context.Context, keyPrefix, result string)
 // SubtitlesDownloadsTotalIncr increases in 1 a metric for tracking subtitles downloads
 var SubtitlesDownloadsTotalIncr func(ctx context.Context)
 
+// RateLimitRejectionsTotalIncr increases in 1 a metric for tracking requests rejected by the rate limits
+var RateLimitRejectionsTotalIncr func(ctx context.Context, route, scope string)
+
+// RankingFeedbackTotalIncr increases in 1 a metric for tracking the subtitles listings the download feedback applied to,
+// and whether it changed their top result
+var RankingFeedbackTotalIncr func(ctx context.Context, topChanged bool)
+
 func createCustomMeters(serviceName, serviceVersion, serviceEnvironment string) error {
 	meter := otel.Meter(serviceName)
 	var err error
@@ -126,6 +133,29 @@ func createCustomMeters(serviceName, serviceVersion, serviceEnvironment string)
 			attribute.String(string(semconv.ServiceVersionKey), serviceVersion),
 		))
 	}
+	rateLimitRejectionsTotal, err := meter.Int64Counter("rate_limit_rejections_total")
+	if err != nil {
+		return fmt.Errorf("failed to create custom meter: %w", err)
+	}
+	RateLimitRejectionsTotalIncr = func(ctx context.Context, route, scope string) {
+		rateLimitRejectionsTotal.Add(ctx, 1, metric2.WithAttributes(
+			attribute.String(string(semconv.DeploymentEnvironmentNameKey), serviceEnvironment),
+			attribute.String(string(semconv.ServiceVersionKey), serviceVersion),
+			attribute.String("route", route),
+			attribute.String("scope", scope),
+		))
+	}
+	rankingFeedbackTotal, err := meter.Int64Counter("ranking_feedback_total")
+	if err != nil {
+		return fmt.Errorf("failed to create custom meter: %w", err)
+	}
+	RankingFeedbackTotalIncr = func(ctx context.Context, topChanged bool) {
+		rankingFeedbackTotal.Add(ctx, 1, metric2.WithAttributes(
+			attribute.String(string(semconv.DeploymentEnvironmentNameKey), serviceEnvironment),
+			attribute.String(string(semconv.ServiceVersionKey), serviceVersion),
+			attribute.Bool("top_changed", topChanged),
+		))
+	}
 
 	return nil
 }
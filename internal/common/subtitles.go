@@ -0,0 +1,27 @@
+package common
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// srtTimestamp matches the SRT cue timestamps, which use a comma as the decimal separator.
+var srtTimestamp = regexp.MustCompile(`(\d{2}:\d{2}:\d{2}),(\d{3})`)
+
+// SRTToWebVTT converts SRT subtitles to WebVTT, keeping the cue numbers as cue identifiers.
+func SRTToWebVTT(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if bytes.Contains(line, []byte("-->")) {
+			lines[i] = srtTimestamp.ReplaceAll(line, []byte("$1.$2"))
+		}
+	}
+
+	vtt := bytes.NewBufferString("WEBVTT\n\n")
+	vtt.Write(bytes.TrimLeft(bytes.Join(lines, []byte("\n")), "\n"))
+
+	return vtt.Bytes()
+}
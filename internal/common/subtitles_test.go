@@ -0,0 +1,14 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/ogero/stremio-subdivx/internal/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSRTToWebVTT(t *testing.T) {
+	srt := "\xef\xbb\xbf1\r\n00:00:01,500 --> 00:00:03,000\r\n¿Qué hacés, 12:00:00,000?\r\n\r\n2\r\n00:01:00,000 --> 00:01:02,250\r\nChau.\r\n"
+
+	assert.Equal(t, "WEBVTT\n\n1\n00:00:01.500 --> 00:00:03.000\n¿Qué hacés, 12:00:00,000?\n\n2\n00:01:00.000 --> 00:01:02.250\nChau.\n", string(common.SRTToWebVTT([]byte(srt))))
+}
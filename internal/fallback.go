@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+)
+
+// fallbackQuotaCacheVersion is the schema version of the stored fallback quota counters.
+const fallbackQuotaCacheVersion = 1
+
+// FallbackQuotaError is returned when a client or an install used up its daily fallback API key quota.
+type FallbackQuotaError struct {
+	// Scope is the exhausted quota, "ip" or "install".
+	Scope string
+	// RetryAfter is how long until the quota resets.
+	RetryAfter time.Duration
+}
+
+func (e *FallbackQuotaError) Error() string {
+	return fmt.Sprintf("daily fallback api key quota exceeded for %s, resets in %s", e.Scope, e.RetryAfter.Round(time.Second))
+}
+
+// Unwrap returns the error as a local *subx.RateLimitError, so it's answered like the SubX rate limits are.
+func (e *FallbackQuotaError) Unwrap() error {
+	return &subx.RateLimitError{RetryAfter: e.RetryAfter, Local: true}
+}
+
+// errFallbackDisabled is returned by FallbackKey.Take when there's no fallback API key configured.
+var errFallbackDisabled = errors.New("fallback api key disabled")
+
+// FallbackKey is the operator SubX API key used by the installs without their own, limited by daily quotas per client
+// IP and per install. The quotas reset at midnight UTC and their counters are kept in the cache backend.
+type FallbackKey struct {
+	apiKey     string
+	perIP      int
+	perInstall int
+	backend    cache.Backend
+	now        func() time.Time
+
+	// mu serializes the counters read-modify-write, the backends don't support atomic increments.
+	mu sync.Mutex
+}
+
+/*
+NewFallbackKey creates a new instance of the FallbackKey struct.
+
+Parameters:
+  - apiKey: The operator SubX API key, empty disables the fallback and makes NewFallbackKey return nil.
+  - perIP: Daily requests allowed per client IP, zero means unlimited.
+  - perInstall: Daily requests allowed per install, zero means unlimited.
+  - backend: The cache backend the daily counters are kept in.
+
+Returns:
+  - A pointer to the newly created FallbackKey instance, nil if apiKey is empty.
+*/
+func NewFallbackKey(apiKey string, perIP int, perInstall int, backend cache.Backend) *FallbackKey {
+	if apiKey == "" {
+		return nil
+	}
+
+	return &FallbackKey{
+		apiKey:     apiKey,
+		perIP:      perIP,
+		perInstall: perInstall,
+		backend:    backend,
+		now:        time.Now,
+	}
+}
+
+// Take counts a request against the daily quotas of the client IP and the install, an empty installRef only counts
+// against the client IP one. It returns the fallback API key, or a *FallbackQuotaError when a quota is used up.
+func (f *FallbackKey) Take(ctx context.Context, clientIP string, installRef string) (string, error) {
+	if f == nil {
+		return "", errFallbackDisabled
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now().UTC()
+	day := now.Format(time.DateOnly)
+	resetAt := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+
+	type counter struct {
+		scope string
+		id    string
+		limit int
+		key   string
+		count int
+	}
+	counters := []*counter{
+		{scope: "ip", id: clientIP, limit: f.perIP},
+		{scope: "install", id: installRef, limit: f.perInstall},
+	}
+
+	for _, c := range counters {
+		if c.id == "" || c.limit <= 0 {
+			continue
+		}
+
+		c.key = cache.NewKey("fallback.quota", fallbackQuotaCacheVersion, c.scope, c.id, day).String()
+		value, err := f.backend.Get(ctx, c.key)
+		if err != nil && !errors.Is(err, cache.ErrNotFound) {
+			return "", fmt.Errorf("failed to cache.Backend.Get: %w", err)
+		} else if err == nil {
+			c.count, _ = strconv.Atoi(string(value))
+		}
+
+		if c.count >= c.limit {
+			return "", &FallbackQuotaError{Scope: c.scope, RetryAfter: resetAt.Sub(now)}
+		}
+	}
+
+	for _, c := range counters {
+		if c.key == "" {
+			continue
+		}
+
+		// Counters outlive the day by an hour so clock skew between replicas doesn't reset them early
+		err := f.backend.Set(ctx, c.key, []byte(strconv.Itoa(c.count+1)), resetAt.Sub(now)+time.Hour)
+		if err != nil {
+			return "", fmt.Errorf("failed to cache.Backend.Set: %w", err)
+		}
+	}
+
+	return f.apiKey, nil
+}
+
+/*
+Reserve returns the fallback API key and a copy of ctx whose requests to SubX count against the daily quotas of the
+client IP and the install, as Take does. They're counted once per context, on the first request that reaches SubX, so
+the requests served from the cache don't use up the quotas. Those requests fail with a *FallbackQuotaError once a quota
+is used up.
+*/
+func (f *FallbackKey) Reserve(ctx context.Context, clientIP string, installRef string) (context.Context, string, error) {
+	if f == nil {
+		return ctx, "", errFallbackDisabled
+	}
+
+	var once sync.Once
+	var err error
+	ctx = subx.WithReserve(ctx, func(ctx context.Context) error {
+		once.Do(func() {
+			_, err = f.Take(ctx, clientIP, installRef)
+		})
+		return err
+	})
+
+	return ctx, f.apiKey, nil
+}
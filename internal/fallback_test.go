@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFallbackKeyDisabled(t *testing.T) {
+	fallback := NewFallbackKey("", 1, 1, cache.NewMemoryBackend(1024))
+	assert.Nil(t, fallback)
+
+	_, err := fallback.Take(context.Background(), "203.0.113.1", "")
+	assert.ErrorIs(t, err, errFallbackDisabled)
+}
+
+func TestFallbackKeyTake(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+
+	fallback := NewFallbackKey("operator-key", 3, 2, cache.NewMemoryBackend(64*1024))
+	fallback.now = func() time.Time { return now }
+
+	for range 2 {
+		apiKey, err := fallback.Take(ctx, "203.0.113.1", "install-a")
+		require.NoError(t, err)
+		assert.Equal(t, "operator-key", apiKey)
+	}
+
+	_, err := fallback.Take(ctx, "203.0.113.1", "install-a")
+	var quotaErr *FallbackQuotaError
+	require.True(t, errors.As(err, &quotaErr))
+	assert.Equal(t, "install", quotaErr.Scope)
+	assert.Equal(t, 6*time.Hour, quotaErr.RetryAfter)
+
+	// Another install behind the same IP only has the IP quota left
+	_, err = fallback.Take(ctx, "203.0.113.1", "install-b")
+	require.NoError(t, err)
+	_, err = fallback.Take(ctx, "203.0.113.1", "install-b")
+	require.True(t, errors.As(err, &quotaErr))
+	assert.Equal(t, "ip", quotaErr.Scope)
+
+	// The quotas reset the next day
+	now = now.Add(7 * time.Hour)
+	_, err = fallback.Take(ctx, "203.0.113.1", "install-a")
+	assert.NoError(t, err)
+}
+
+func TestFallbackKeyReserve(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"items": [], "total": 0}`))
+	}))
+	defer server.Close()
+
+	client := subx.NewSubX()
+	client.BaseURL = server.URL
+
+	fallback := NewFallbackKey("operator-key", 0, 1, cache.NewMemoryBackend(64*1024))
+	search := func(ctx context.Context, apiKey string) error {
+		_, err := client.SearchSubtitles(ctx, apiKey, subx.SearchParams{IMDBID: "tt0133093"})
+		return err
+	}
+
+	// Requests served without reaching SubX don't count
+	_, _, err := fallback.Reserve(context.Background(), "203.0.113.1", "install-a")
+	require.NoError(t, err)
+
+	// The requests of a context count once
+	ctx, apiKey, err := fallback.Reserve(context.Background(), "203.0.113.1", "install-a")
+	require.NoError(t, err)
+	assert.Equal(t, "operator-key", apiKey)
+	require.NoError(t, search(ctx, apiKey))
+	require.NoError(t, search(ctx, apiKey))
+	assert.Equal(t, 2, requests)
+
+	ctx, apiKey, err = fallback.Reserve(context.Background(), "203.0.113.1", "install-a")
+	require.NoError(t, err)
+	err = search(ctx, apiKey)
+	var quotaErr *FallbackQuotaError
+	assert.True(t, errors.As(err, &quotaErr))
+	var rateLimitErr *subx.RateLimitError
+	assert.True(t, errors.As(err, &rateLimitErr), "expected the quota error to be answered as a rate limit")
+	assert.Equal(t, 2, requests)
+}
@@ -0,0 +1,190 @@
+package feedback
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+)
+
+// storeCacheVersion is the schema version of the stored feedback scores.
+const storeCacheVersion = 1
+
+// maxSubtitlesPerRelease bounds the scores kept per release, the lowest ones are dropped first.
+const maxSubtitlesPerRelease = 20
+
+// videoExtensions are stripped from the filenames before normalizing them.
+var videoExtensions = []string{".mkv", ".mp4", ".avi", ".m4v", ".mov", ".wmv", ".ts", ".webm"}
+
+// NormalizeRelease turns a video filename into a release name that matches across the separators and casing variants,
+// such as "Breaking.Bad.S01E02.720p-DEMAND.mkv" into "breaking bad s01e02 720p demand".
+func NormalizeRelease(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, videoExtension := range videoExtensions {
+		if ext == videoExtension {
+			filename = strings.TrimSuffix(filename, filepath.Ext(filename))
+			break
+		}
+	}
+
+	return strings.Join(strings.FieldsFunc(strings.ToLower(filename), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// TitleKey identifies a movie, or an episode when season and episode are set, as in "tt0903747:1:2".
+func TitleKey(imdbID string, season int, episode int) string {
+	if season > 0 || episode > 0 {
+		return imdbID + ":" + strconv.Itoa(season) + ":" + strconv.Itoa(episode)
+	}
+	return imdbID
+}
+
+type entry struct {
+	UpdatedAt int64              `json:"updatedAt"`
+	Scores    map[string]float64 `json:"scores"`
+}
+
+// Store keeps a score per subtitle for each title and release, raised by the downloads and decaying over time, so the
+// subtitles users actually pick for a release can be boosted. Each install counts once per subtitle. It's anonymized:
+// only the title, a hash of the release, the subtitle scores and hashes of the opaque install references that counted
+// are kept.
+type Store struct {
+	backend  cache.Backend
+	halfLife time.Duration
+	now      func() time.Time
+
+	// mu serializes the scores read-modify-write, the backends don't support atomic updates.
+	mu sync.Mutex
+}
+
+/*
+NewStore creates a new instance of the Store struct.
+
+Parameters:
+  - backend: The cache backend the scores are kept in.
+  - halfLife: The time it takes for a score to halve, scores are dropped after eight half-lives without downloads.
+
+Returns:
+  - A pointer to the newly created Store instance.
+*/
+func NewStore(backend cache.Backend, halfLife time.Duration) *Store {
+	return &Store{
+		backend:  backend,
+		halfLife: halfLife,
+		now:      time.Now,
+	}
+}
+
+// Record raises the score of subtitleID for the title and release by one download of the install referenced by userRef,
+// repeated downloads of the same install don't count. Downloads without a release or an install, or of the anonymous
+// installs sharing userconfig.AnonymousRef, are ignored.
+func (s *Store) Record(ctx context.Context, titleKey string, release string, subtitleID string, userRef string) error {
+	if s == nil || titleKey == "" || release == "" || subtitleID == "" || userRef == "" || userRef == userconfig.AnonymousRef {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seenKey := downloadKey(titleKey, release, subtitleID, userRef)
+	_, err := s.backend.Get(ctx, seenKey)
+	if err == nil {
+		return nil
+	} else if !errors.Is(err, cache.ErrNotFound) {
+		return fmt.Errorf("failed to cache.Backend.Get: %w", err)
+	}
+
+	scores, err := s.scores(ctx, titleKey, release)
+	if err != nil {
+		return err
+	}
+	scores[subtitleID]++
+	for len(scores) > maxSubtitlesPerRelease {
+		delete(scores, lowestScore(scores, subtitleID))
+	}
+
+	b, err := json.Marshal(entry{UpdatedAt: s.now().Unix(), Scores: scores})
+	if err != nil {
+		return fmt.Errorf("failed to json.Marshal: %w", err)
+	}
+	if err = s.backend.Set(ctx, key(titleKey, release), b, 8*s.halfLife); err != nil {
+		return fmt.Errorf("failed to cache.Backend.Set: %w", err)
+	}
+	if err = s.backend.Set(ctx, seenKey, []byte{1}, 8*s.halfLife); err != nil {
+		return fmt.Errorf("failed to cache.Backend.Set: %w", err)
+	}
+
+	return nil
+}
+
+// Scores returns the decayed scores of the subtitles downloaded for the title and release, empty if there are none.
+func (s *Store) Scores(ctx context.Context, titleKey string, release string) (map[string]float64, error) {
+	if s == nil || titleKey == "" || release == "" {
+		return map[string]float64{}, nil
+	}
+
+	return s.scores(ctx, titleKey, release)
+}
+
+func (s *Store) scores(ctx context.Context, titleKey string, release string) (map[string]float64, error) {
+	b, err := s.backend.Get(ctx, key(titleKey, release))
+	if errors.Is(err, cache.ErrNotFound) {
+		return map[string]float64{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to cache.Backend.Get: %w", err)
+	}
+
+	var e entry
+	if err = json.Unmarshal(b, &e); err != nil {
+		return nil, fmt.Errorf("failed to json.Unmarshal: %w", err)
+	}
+	if e.Scores == nil {
+		e.Scores = map[string]float64{}
+	}
+
+	elapsed := s.now().Sub(time.Unix(e.UpdatedAt, 0))
+	if elapsed > 0 && s.halfLife > 0 {
+		decay := math.Pow(0.5, elapsed.Seconds()/s.halfLife.Seconds())
+		for id := range e.Scores {
+			e.Scores[id] *= decay
+		}
+	}
+
+	return e.Scores, nil
+}
+
+// lowestScore returns the subtitle with the lowest score, other than keep.
+func lowestScore(scores map[string]float64, keep string) string {
+	var lowest string
+	for id, score := range scores {
+		if id != keep && (lowest == "" || score < scores[lowest] || (score == scores[lowest] && id > lowest)) {
+			lowest = id
+		}
+	}
+	return lowest
+}
+
+// key hashes the release so the keys stay short whatever the filename length.
+func key(titleKey string, release string) string {
+	sum := sha256.Sum256([]byte(release))
+	return cache.NewKey("feedback.scores", storeCacheVersion, titleKey, base64.RawURLEncoding.EncodeToString(sum[:12])).String()
+}
+
+// downloadKey marks the downloads of an install, hashed so the install can't be told from the key.
+func downloadKey(titleKey string, release string, subtitleID string, userRef string) string {
+	sum := sha256.Sum256([]byte(release + "\x00" + subtitleID + "\x00" + userRef))
+	return cache.NewKey("feedback.download", storeCacheVersion, titleKey, base64.RawURLEncoding.EncodeToString(sum[:12])).String()
+}
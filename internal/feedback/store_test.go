@@ -0,0 +1,66 @@
+package feedback
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeRelease(t *testing.T) {
+	assert.Equal(t, "breaking bad s01e02 720p bluray x264 demand", NormalizeRelease("Breaking.Bad.S01E02.720p.BluRay.x264-DEMAND.mkv"))
+	assert.Equal(t, "breaking bad s01e02 720p bluray x264 demand", NormalizeRelease("breaking_bad_s01e02_720p_bluray_x264_demand"))
+	assert.Equal(t, "", NormalizeRelease(""))
+}
+
+func TestTitleKey(t *testing.T) {
+	assert.Equal(t, "tt0111161", TitleKey("tt0111161", 0, 0))
+	assert.Equal(t, "tt0903747:1:2", TitleKey("tt0903747", 1, 2))
+}
+
+func TestStoreDecays(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	store := NewStore(cache.NewMemoryBackend(64*1024), 24*time.Hour)
+	store.now = func() time.Time { return now }
+
+	for _, userRef := range []string{"user-a", "user-b", "user-b"} {
+		require.NoError(t, store.Record(ctx, "tt0111161", "the shawshank redemption 1080p", "300", userRef))
+	}
+	require.NoError(t, store.Record(ctx, "tt0111161", "the shawshank redemption 1080p", "100", "user-a"))
+
+	scores, err := store.Scores(ctx, "tt0111161", "the shawshank redemption 1080p")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"300": 2, "100": 1}, scores)
+
+	now = now.Add(24 * time.Hour)
+	scores, err = store.Scores(ctx, "tt0111161", "the shawshank redemption 1080p")
+	require.NoError(t, err)
+	assert.InDelta(t, 1, scores["300"], 0.001)
+	assert.InDelta(t, 0.5, scores["100"], 0.001)
+
+	scores, err = store.Scores(ctx, "tt0111161", "another release")
+	require.NoError(t, err)
+	assert.Empty(t, scores)
+}
+
+func TestStoreIgnoresDownloadsWithoutReleaseOrInstall(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(cache.NewMemoryBackend(64*1024), time.Hour)
+
+	require.NoError(t, store.Record(ctx, "tt0111161", "", "100", "user-a"))
+	scores, err := store.Scores(ctx, "tt0111161", "")
+	require.NoError(t, err)
+	assert.Empty(t, scores)
+
+	require.NoError(t, store.Record(ctx, "tt0111161", "the shawshank redemption 1080p", "100", ""))
+	require.NoError(t, store.Record(ctx, "tt0111161", "the shawshank redemption 1080p", "100", userconfig.AnonymousRef))
+	scores, err = store.Scores(ctx, "tt0111161", "the shawshank redemption 1080p")
+	require.NoError(t, err)
+	assert.Empty(t, scores)
+}
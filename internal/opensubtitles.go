@@ -0,0 +1,306 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+	"github.com/ogero/stremio-subdivx/internal/common"
+	"github.com/ogero/stremio-subdivx/internal/feedback"
+	"github.com/ogero/stremio-subdivx/internal/opensubtitles"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/ogero/stremio-subdivx/internal/videohash"
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxOpenSubtitlesDownloadBody bounds the download request bodies, they hold a file ID and a format.
+const maxOpenSubtitlesDownloadBody = 4 << 10
+
+// openSubtitlesUnknownRemaining is the remaining downloads reported when the SubX quota isn't known yet, the clients
+// stop downloading when it's zero.
+const openSubtitlesUnknownRemaining = 100
+
+/*
+OpenSubtitlesSearchHandler serves the subtitles search of the OpenSubtitles compatible facade.
+
+The Api-Key header carries the userConfig token, the fallback SubX API key is used when it's empty. Titles are searched
+by imdb_id, or parent_imdb_id with season_number and episode_number for the episodes, and by the query filename
+otherwise. The moviehash ranks the subtitle proven for the video file first, it comes without the file size so the
+size last downloaded with the hash is used. Only Spanish is available, the searches for other languages get no
+subtitles.
+*/
+func (a *App) OpenSubtitlesSearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "OpenSubtitlesSearchHandler")
+
+	params, err := opensubtitles.ParseSearchParams(r.URL.Query())
+	if err == nil && len(params.Query) > maxSearchFilenameLength {
+		err = fmt.Errorf("%w: query is too long", opensubtitles.ErrInvalidParameter)
+	}
+	if err != nil {
+		common.Log.WarnContext(ctx, "Failed to opensubtitles.ParseSearchParams", "err", err)
+		span.RecordError(err)
+		writeOpenSubtitlesError(w, r, http.StatusBadRequest, strings.TrimPrefix(err.Error(), opensubtitles.ErrInvalidParameter.Error()+": "))
+		return
+	}
+	span.SetAttributes(attribute.String("params.imdb_id", params.IMDBID))
+	span.SetAttributes(attribute.String("params.parent_imdb_id", params.ParentIMDBID))
+	span.SetAttributes(attribute.String("params.query", params.Query))
+
+	response := opensubtitles.SearchResponse{PerPage: opensubtitles.PerPage, Page: params.Page, Data: []opensubtitles.Subtitle{}}
+
+	language, ok := params.Language()
+	if !ok {
+		writeJSON(w, r, response)
+		return
+	}
+
+	installToken := openSubtitlesInstallToken(r)
+	config := userconfig.FromContext(ctx)
+	ctx, apiKey, ok := a.resolveAPIKey(w, r, config, installToken)
+	if !ok {
+		return
+	}
+
+	video := videohash.Video{Hash: params.MovieHash}
+	options := SubtitlesOptions{Video: video, Dialect: config.Dialect}
+
+	// The episodes are searched by the IMDB ID of their series, SubX doesn't index the IMDB IDs of the episodes
+	imdbID, season, episode := params.ParentIMDBID, params.SeasonNumber, params.EpisodeNumber
+	if imdbID == "" {
+		imdbID = params.IMDBID
+	}
+
+	var subtitles []RankedSubtitle
+	var titleKey string
+	if imdbID != "" {
+		titleType := "movie"
+		if params.ParentIMDBID != "" || params.Type == "episode" || season > 0 || episode > 0 {
+			titleType = "series"
+		}
+		subtitles, err = a.StremioService.SearchSubtitles(ctx, apiKey, titleType, imdbID, season, episode, params.Query, options)
+		titleKey = feedback.TitleKey(imdbID, season, episode)
+	} else {
+		var result *FilenameSubtitles
+		result, err = a.StremioService.SearchSubtitlesByFilename(ctx, apiKey, params.Query, options)
+		if err == nil {
+			subtitles, titleKey = result.Subtitles, result.TitleKey
+			season, episode = result.Release.Season, result.Release.Episode
+		}
+	}
+	var rateLimitErr *subx.RateLimitError
+	if errors.Is(err, ErrUnparsableFilename) {
+		common.Log.InfoContext(ctx, "Failed to StremioService.SearchSubtitlesByFilename", "err", err)
+		writeJSON(w, r, response)
+		return
+	} else if errors.As(err, &rateLimitErr) {
+		common.Log.WarnContext(ctx, "Failed to StremioService.SearchSubtitles", "err", err)
+		span.RecordError(err)
+		writeRetryAfter(w, rateLimitErr.RetryAfter)
+		writeOpenSubtitlesError(w, r, http.StatusTooManyRequests, "SubX rate limit exceeded, try again later")
+		return
+	} else if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to StremioService.SearchSubtitles", "err", err)
+		span.RecordError(err)
+		writeOpenSubtitlesError(w, r, http.StatusInternalServerError, "Failed to search the subtitles")
+		return
+	}
+
+	query := SubtitlesQuery{Sort: SortRelevance, Descending: !params.OrderAscending, Page: params.Page, PageSize: opensubtitles.PerPage}
+	switch params.OrderBy {
+	case "download_count":
+		query.Sort = SortDownloads
+	case "upload_date":
+		query.Sort = SortPostedAt
+	}
+	switch params.HearingImpaired {
+	case opensubtitles.HearingImpairedExclude:
+		query.HearingImpaired = new(bool)
+	case opensubtitles.HearingImpairedOnly:
+		hearingImpaired := true
+		query.HearingImpaired = &hearingImpaired
+	}
+
+	subtitles, response.TotalCount = query.apply(subtitles)
+	response.TotalPages = (response.TotalCount + opensubtitles.PerPage - 1) / opensubtitles.PerPage
+
+	release := feedback.NormalizeRelease(params.Query)
+	for _, subtitle := range subtitles {
+		subtitleTitleKey := titleKey
+		if imdbID == "" && common.ValidateIMDBTitleID(subtitle.IMDBID) == nil {
+			subtitleTitleKey = feedback.TitleKey(subtitle.IMDBID, season, episode)
+		}
+
+		fileID, err := a.OpenSubtitlesFiles.Put(ctx, opensubtitles.File{
+			SubtitleID:    subtitle.ID,
+			DownloadQuery: subtitleDownloadQuery(video, subtitleTitleKey, release),
+		})
+		if err != nil {
+			common.Log.ErrorContext(ctx, "Failed to opensubtitles.Store.Put", "err", err)
+			span.RecordError(err)
+			writeOpenSubtitlesError(w, r, http.StatusInternalServerError, "Failed to search the subtitles")
+			return
+		}
+
+		response.Data = append(response.Data, openSubtitlesSubtitle(subtitle, language, fileID, imdbID, season, episode))
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age=600")
+
+	writeJSON(w, r, response)
+}
+
+// openSubtitlesSubtitle returns the OpenSubtitles representation of the subtitle, downloaded as fileID.
+func openSubtitlesSubtitle(subtitle RankedSubtitle, language string, fileID int64, imdbID string, season int, episode int) opensubtitles.Subtitle {
+	details := opensubtitles.FeatureDetails{
+		FeatureType: "Movie",
+		Title:       subtitle.Title,
+		MovieName:   subtitle.Title,
+		IMDBID:      opensubtitles.IMDBNumber(subtitle.IMDBID),
+	}
+	if details.IMDBID == 0 {
+		details.IMDBID = opensubtitles.IMDBNumber(imdbID)
+	}
+	if season > 0 || episode > 0 || subtitle.Season > 0 || len(subtitle.Episodes) > 0 {
+		details.FeatureType = "Episode"
+		details.ParentIMDBID = details.IMDBID
+		details.ParentTitle = subtitle.Title
+		details.SeasonNumber, details.EpisodeNumber = season, episode
+		if details.SeasonNumber == 0 {
+			details.SeasonNumber = subtitle.Season
+		}
+		if details.EpisodeNumber == 0 && len(subtitle.Episodes) > 0 {
+			details.EpisodeNumber = subtitle.Episodes[0]
+		}
+	}
+
+	return opensubtitles.Subtitle{
+		ID:   subtitle.ID,
+		Type: "subtitle",
+		Attributes: opensubtitles.SubtitleAttributes{
+			SubtitleID:      subtitle.ID,
+			Language:        language,
+			DownloadCount:   subtitle.Downloads,
+			HearingImpaired: subtitle.HearingImpaired(),
+			UploadDate:      subtitle.PostedAt,
+			MovieHashMatch:  subtitle.Proven,
+			// SubX descriptions list the releases the subtitle is synced to
+			Release:        subtitle.Description,
+			Uploader:       opensubtitles.Uploader{Name: subtitle.UploaderName},
+			FeatureDetails: details,
+			RelatedLinks:   []opensubtitles.RelatedLink{},
+			Files: []opensubtitles.FileEntry{{
+				FileID:   fileID,
+				CDNumber: 1,
+				FileName: subtitle.Title + ".srt",
+			}},
+		},
+	}
+}
+
+/*
+OpenSubtitlesDownloadHandler serves the download of the OpenSubtitles compatible facade.
+
+It takes the file_id listed by OpenSubtitlesSearchHandler and answers with a short-lived signed link to the subtitle,
+converted to the format and charset of the userConfig token in the Api-Key header. Unknown or expired file IDs are
+answered with 404, the clients search again.
+*/
+func (a *App) OpenSubtitlesDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "OpenSubtitlesDownloadHandler")
+
+	var request opensubtitles.DownloadRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOpenSubtitlesDownloadBody)).Decode(&request); err != nil {
+		common.Log.WarnContext(ctx, "Failed to json.Decode", "err", err)
+		span.RecordError(err)
+		writeOpenSubtitlesError(w, r, http.StatusBadRequest, "file_id must be a positive integer")
+		return
+	}
+	span.SetAttributes(attribute.Int64("params.file_id", request.FileID))
+
+	installToken := openSubtitlesInstallToken(r)
+	config := userconfig.FromContext(ctx)
+
+	file, err := a.OpenSubtitlesFiles.Get(ctx, request.FileID)
+	if errors.Is(err, cache.ErrNotFound) {
+		common.Log.InfoContext(ctx, "Failed to opensubtitles.Store.Get", "err", err)
+		writeOpenSubtitlesError(w, r, http.StatusNotFound, "Unknown or expired file_id, search again")
+		return
+	} else if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to opensubtitles.Store.Get", "err", err)
+		span.RecordError(err)
+		writeOpenSubtitlesError(w, r, http.StatusInternalServerError, "Failed to get the file")
+		return
+	}
+
+	userRef, err := a.UserConfigStore.Put(ctx, installToken)
+	if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to userconfig.Store.Put", "err", err)
+		span.RecordError(err)
+		writeOpenSubtitlesError(w, r, http.StatusInternalServerError, "Failed to sign the download link")
+		return
+	}
+
+	response := opensubtitles.DownloadResponse{
+		Link:      a.signedSubtitleURL(file.SubtitleID, userRef, file.DownloadQuery),
+		FileName:  file.SubtitleID + "." + config.Format,
+		Remaining: openSubtitlesUnknownRemaining,
+		Message:   "The link is short-lived, download it right away",
+	}
+	if config.APIKey != "" {
+		if quota, known := a.StremioService.Quota(config.APIKey); known {
+			response.Requests = quota.Limit - quota.Remaining
+			response.Remaining = quota.Remaining
+			response.ResetTime = time.Until(quota.ResetAt).Round(time.Second).String()
+			response.ResetTimeUTC = quota.ResetAt.UTC().Format(time.RFC3339)
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	writeJSON(w, r, response)
+}
+
+/*
+OpenSubtitlesAPIKeyMiddleware parses the userConfig token the OpenSubtitles compatible facade takes in the Api-Key
+header, and stores its config in the request context like UserConfigMiddleware does. An empty header is an empty
+config, so the fallback SubX API key is used.
+*/
+func (a *App) OpenSubtitlesAPIKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		config, err := userconfig.Parse(a.UserConfigSealer, openSubtitlesInstallToken(r))
+		if err != nil {
+			common.Log.WarnContext(ctx, "Failed to userconfig.Parse", "err", err)
+			trace.SpanFromContext(ctx).RecordError(err)
+			writeOpenSubtitlesError(w, r, userConfigErrorStatus(err), "Api-Key must be a userConfig token")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(userconfig.NewContext(ctx, config)))
+	})
+}
+
+// openSubtitlesInstallToken returns the userConfig token of the Api-Key header.
+func openSubtitlesInstallToken(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get("Api-Key"))
+}
+
+// writeOpenSubtitlesError writes an OpenSubtitles error response with the status code.
+func writeOpenSubtitlesError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	writeJSON(w, r, opensubtitles.ErrorResponse{Message: message, Status: status})
+}
@@ -0,0 +1,295 @@
+/*
+Package opensubtitles holds the request and response shapes of the search and download subset of the OpenSubtitles
+REST API, so the tools speaking it can search and download the SubX subtitles without custom plugins.
+*/
+package opensubtitles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PerPage is the number of subtitles per search results page, as in the OpenSubtitles API.
+const PerPage = 60
+
+// Languages are the OpenSubtitles codes of the Spanish variants: generic, Latin American and European.
+var Languages = []string{"es", "ea", "sp"}
+
+// ErrInvalidParameter is returned for the malformed search and download parameters.
+var ErrInvalidParameter = errors.New("invalid parameter")
+
+var (
+	imdbIDRegexp    = regexp.MustCompile(`^(?:tt)?(\d{1,10})$`)
+	movieHashRegexp = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+// Hearing impaired filters of SearchParams.
+const (
+	HearingImpairedInclude = "include"
+	HearingImpairedExclude = "exclude"
+	HearingImpairedOnly    = "only"
+)
+
+// SearchParams are the supported parameters of the subtitles search.
+type SearchParams struct {
+	// IMDBID is the IMDB ID of the movie or the episode, as in "tt0133093".
+	IMDBID string
+	// ParentIMDBID is the IMDB ID of the series of the episode searched by season and episode numbers.
+	ParentIMDBID string
+	// Type is "movie", "episode" or "all".
+	Type          string
+	SeasonNumber  int
+	EpisodeNumber int
+	// MovieHash is the OpenSubtitles hash of the video file.
+	MovieHash string
+	// Query is the title or the filename to search for when there's no IMDB ID.
+	Query string
+	// Languages are the requested language codes, nil for any.
+	Languages []string
+	// HearingImpaired is HearingImpairedInclude, HearingImpairedExclude or HearingImpairedOnly.
+	HearingImpaired string
+	// OrderBy is empty for the relevance, "download_count" or "upload_date".
+	OrderBy        string
+	OrderAscending bool
+	Page           int
+}
+
+// ParseSearchParams parses the query parameters of the subtitles search, wrapping ErrInvalidParameter for the malformed ones.
+func ParseSearchParams(values url.Values) (SearchParams, error) {
+	p := SearchParams{
+		Type:            strings.ToLower(values.Get("type")),
+		MovieHash:       strings.ToLower(values.Get("moviehash")),
+		Query:           strings.TrimSpace(values.Get("query")),
+		HearingImpaired: strings.ToLower(values.Get("hearing_impaired")),
+		OrderBy:         values.Get("order_by"),
+		Page:            1,
+	}
+
+	var err error
+	if p.IMDBID, err = parseIMDBID(values.Get("imdb_id")); err != nil {
+		return p, fmt.Errorf("%w: imdb_id: %w", ErrInvalidParameter, err)
+	}
+	if p.ParentIMDBID, err = parseIMDBID(values.Get("parent_imdb_id")); err != nil {
+		return p, fmt.Errorf("%w: parent_imdb_id: %w", ErrInvalidParameter, err)
+	}
+	if p.IMDBID == "" && p.ParentIMDBID == "" && p.Query == "" {
+		return p, fmt.Errorf("%w: imdb_id, parent_imdb_id or query is required", ErrInvalidParameter)
+	}
+
+	switch p.Type {
+	case "":
+		p.Type = "all"
+	case "movie", "episode", "all":
+	default:
+		return p, fmt.Errorf("%w: type must be movie, episode or all", ErrInvalidParameter)
+	}
+	if p.MovieHash != "" && !movieHashRegexp.MatchString(p.MovieHash) {
+		return p, fmt.Errorf("%w: moviehash must be 16 hexadecimal characters", ErrInvalidParameter)
+	}
+	switch p.HearingImpaired {
+	case "":
+		p.HearingImpaired = HearingImpairedInclude
+	case HearingImpairedInclude, HearingImpairedExclude, HearingImpairedOnly:
+	default:
+		return p, fmt.Errorf("%w: hearing_impaired must be include, exclude or only", ErrInvalidParameter)
+	}
+	// The other orders of the OpenSubtitles API have no SubX counterpart, they fall back to the relevance
+	if p.OrderBy != "download_count" && p.OrderBy != "upload_date" {
+		p.OrderBy = ""
+	}
+	p.OrderAscending = strings.EqualFold(values.Get("order_direction"), "asc")
+
+	intParams := []struct {
+		name  string
+		value *int
+		min   int
+	}{
+		{name: "season_number", value: &p.SeasonNumber, min: 0},
+		{name: "episode_number", value: &p.EpisodeNumber, min: 0},
+		{name: "page", value: &p.Page, min: 1},
+	}
+	for _, param := range intParams {
+		s := values.Get(param.name)
+		if s == "" {
+			continue
+		}
+		value, err := strconv.Atoi(s)
+		if err != nil || value < param.min {
+			return p, fmt.Errorf("%w: %s must be an integer of at least %d", ErrInvalidParameter, param.name, param.min)
+		}
+		*param.value = value
+	}
+
+	if s := values.Get("languages"); s != "" {
+		for _, language := range strings.Split(s, ",") {
+			p.Languages = append(p.Languages, strings.ToLower(strings.TrimSpace(language)))
+		}
+	}
+
+	return p, nil
+}
+
+// Language returns the first requested Spanish language code, "es" if any language is accepted, or false when no
+// Spanish variant is requested.
+func (p SearchParams) Language() (string, bool) {
+	if len(p.Languages) == 0 {
+		return Languages[0], true
+	}
+	for _, language := range p.Languages {
+		for _, spanish := range Languages {
+			if language == spanish {
+				return language, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseIMDBID parses the IMDB IDs, given as integers or with the "tt" prefix, into the "tt" form padded to 7 digits.
+func parseIMDBID(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	m := imdbIDRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return "", fmt.Errorf("malformed imdb id %q", s)
+	}
+	id, _ := strconv.Atoi(m[1])
+	if id == 0 {
+		return "", fmt.Errorf("malformed imdb id %q", s)
+	}
+
+	return fmt.Sprintf("tt%07d", id), nil
+}
+
+// IMDBNumber returns the number of an IMDB ID in the "tt" form, as the OpenSubtitles API lists them, zero if it's malformed.
+func IMDBNumber(imdbID string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(imdbID, "tt"))
+	return n
+}
+
+// SearchResponse is the subtitles search response.
+type SearchResponse struct {
+	TotalPages int        `json:"total_pages"`
+	TotalCount int        `json:"total_count"`
+	PerPage    int        `json:"per_page"`
+	Page       int        `json:"page"`
+	Data       []Subtitle `json:"data"`
+}
+
+// Subtitle is a subtitle of the search response.
+type Subtitle struct {
+	ID         string             `json:"id"`
+	Type       string             `json:"type"`
+	Attributes SubtitleAttributes `json:"attributes"`
+}
+
+// SubtitleAttributes are the attributes of a subtitle of the search response, the ones SubX has no counterpart for
+// keep their zero values.
+type SubtitleAttributes struct {
+	SubtitleID        string         `json:"subtitle_id"`
+	Language          string         `json:"language"`
+	DownloadCount     int            `json:"download_count"`
+	NewDownloadCount  int            `json:"new_download_count"`
+	HearingImpaired   bool           `json:"hearing_impaired"`
+	HD                bool           `json:"hd"`
+	FPS               float64        `json:"fps"`
+	Votes             int            `json:"votes"`
+	Ratings           float64        `json:"ratings"`
+	FromTrusted       bool           `json:"from_trusted"`
+	ForeignPartsOnly  bool           `json:"foreign_parts_only"`
+	UploadDate        string         `json:"upload_date"`
+	AITranslated      bool           `json:"ai_translated"`
+	MachineTranslated bool           `json:"machine_translated"`
+	MovieHashMatch    bool           `json:"moviehash_match"`
+	Release           string         `json:"release"`
+	Comments          string         `json:"comments"`
+	URL               string         `json:"url"`
+	Uploader          Uploader       `json:"uploader"`
+	FeatureDetails    FeatureDetails `json:"feature_details"`
+	RelatedLinks      []RelatedLink  `json:"related_links"`
+	Files             []FileEntry    `json:"files"`
+}
+
+// Uploader is the uploader of a subtitle.
+type Uploader struct {
+	UploaderID *int   `json:"uploader_id"`
+	Name       string `json:"name"`
+	Rank       string `json:"rank"`
+}
+
+// FeatureDetails describes the movie or episode a subtitle is for.
+type FeatureDetails struct {
+	FeatureID     int    `json:"feature_id"`
+	FeatureType   string `json:"feature_type"`
+	Year          int    `json:"year"`
+	Title         string `json:"title"`
+	MovieName     string `json:"movie_name"`
+	IMDBID        int    `json:"imdb_id"`
+	SeasonNumber  int    `json:"season_number,omitempty"`
+	EpisodeNumber int    `json:"episode_number,omitempty"`
+	ParentIMDBID  int    `json:"parent_imdb_id,omitempty"`
+	ParentTitle   string `json:"parent_title,omitempty"`
+}
+
+// RelatedLink is a link related to a subtitle.
+type RelatedLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// FileEntry is a downloadable file of a subtitle, its FileID is what the download takes.
+type FileEntry struct {
+	FileID   int64  `json:"file_id"`
+	CDNumber int    `json:"cd_number"`
+	FileName string `json:"file_name"`
+}
+
+// DownloadRequest is the download request body.
+type DownloadRequest struct {
+	FileID    int64  `json:"file_id"`
+	SubFormat string `json:"sub_format,omitempty"`
+}
+
+// UnmarshalJSON accepts the file IDs as numbers or strings, as the clients send both.
+func (d *DownloadRequest) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		FileID    json.Number `json:"file_id"`
+		SubFormat string      `json:"sub_format"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	fileID, err := strconv.ParseInt(raw.FileID.String(), 10, 64)
+	if err != nil || fileID <= 0 {
+		return fmt.Errorf("%w: file_id must be a positive integer", ErrInvalidParameter)
+	}
+
+	d.FileID, d.SubFormat = fileID, raw.SubFormat
+	return nil
+}
+
+// DownloadResponse is the download response, with the link to the subtitle file.
+type DownloadResponse struct {
+	Link         string `json:"link"`
+	FileName     string `json:"file_name"`
+	Requests     int    `json:"requests"`
+	Remaining    int    `json:"remaining"`
+	Message      string `json:"message"`
+	ResetTime    string `json:"reset_time"`
+	ResetTimeUTC string `json:"reset_time_utc"`
+}
+
+// ErrorResponse is the error response.
+type ErrorResponse struct {
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+}
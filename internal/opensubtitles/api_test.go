@@ -0,0 +1,80 @@
+package opensubtitles_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/ogero/stremio-subdivx/internal/opensubtitles"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSearchParams(t *testing.T) {
+	p, err := opensubtitles.ParseSearchParams(url.Values{
+		"parent_imdb_id":   {"903747"},
+		"season_number":    {"1"},
+		"episode_number":   {"2"},
+		"moviehash":        {"8E245D9679D31E12"},
+		"languages":        {"en,es"},
+		"hearing_impaired": {"exclude"},
+		"order_by":         {"download_count"},
+		"order_direction":  {"asc"},
+		"page":             {"2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, opensubtitles.SearchParams{
+		ParentIMDBID:    "tt0903747",
+		Type:            "all",
+		SeasonNumber:    1,
+		EpisodeNumber:   2,
+		MovieHash:       "8e245d9679d31e12",
+		Languages:       []string{"en", "es"},
+		HearingImpaired: opensubtitles.HearingImpairedExclude,
+		OrderBy:         "download_count",
+		OrderAscending:  true,
+		Page:            2,
+	}, p)
+
+	language, ok := p.Language()
+	assert.True(t, ok)
+	assert.Equal(t, "es", language)
+
+	p, err = opensubtitles.ParseSearchParams(url.Values{"imdb_id": {"tt0133093"}, "languages": {"en"}, "order_by": {"ratings"}})
+	require.NoError(t, err)
+	assert.Equal(t, "tt0133093", p.IMDBID)
+	assert.Empty(t, p.OrderBy)
+	_, ok = p.Language()
+	assert.False(t, ok)
+}
+
+func TestParseSearchParamsErrors(t *testing.T) {
+	tests := map[string]url.Values{
+		"missing title":    {"moviehash": {"8e245d9679d31e12"}},
+		"malformed imdb":   {"imdb_id": {"nm0000206"}},
+		"zero imdb":        {"imdb_id": {"0"}},
+		"malformed type":   {"query": {"matrix"}, "type": {"tvshow"}},
+		"malformed hash":   {"query": {"matrix"}, "moviehash": {"8e245d"}},
+		"malformed filter": {"query": {"matrix"}, "hearing_impaired": {"yes"}},
+		"malformed page":   {"query": {"matrix"}, "page": {"0"}},
+		"negative season":  {"query": {"matrix"}, "season_number": {"-1"}},
+	}
+	for name, values := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := opensubtitles.ParseSearchParams(values)
+			assert.ErrorIs(t, err, opensubtitles.ErrInvalidParameter)
+		})
+	}
+}
+
+func TestDownloadRequestUnmarshal(t *testing.T) {
+	var request opensubtitles.DownloadRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"file_id":1234,"sub_format":"srt"}`), &request))
+	assert.Equal(t, opensubtitles.DownloadRequest{FileID: 1234, SubFormat: "srt"}, request)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"file_id":"5678"}`), &request))
+	assert.Equal(t, opensubtitles.DownloadRequest{FileID: 5678}, request)
+
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"file_id":0}`), &request), opensubtitles.ErrInvalidParameter)
+	assert.Error(t, json.Unmarshal([]byte(`{"file_id":"abc"}`), &request))
+}
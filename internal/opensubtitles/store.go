@@ -0,0 +1,87 @@
+package opensubtitles
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+)
+
+// storeCacheVersion is the schema version of the stored files.
+const storeCacheVersion = 1
+
+// File is a subtitle as listed by the facade search, what its download needs to know.
+type File struct {
+	// SubtitleID is the subtitle ID, qualified with its provider namespace.
+	SubtitleID string `json:"subtitleId"`
+	// DownloadQuery is the query string of the subtitle download URL, carrying the episode and release it was listed for.
+	DownloadQuery string `json:"downloadQuery,omitempty"`
+}
+
+// Store maps the integer file IDs of the OpenSubtitles API to the subtitles, whose IDs are UUIDs or qualified strings.
+type Store struct {
+	backend cache.Backend
+	ttl     time.Duration
+}
+
+/*
+NewStore creates a new instance of the Store struct.
+
+Parameters:
+  - backend: The cache backend the files are stored in.
+  - ttl: How long a file can be downloaded after it was last listed.
+
+Returns:
+  - A pointer to the newly created Store instance.
+*/
+func NewStore(backend cache.Backend, ttl time.Duration) *Store {
+	return &Store{
+		backend: backend,
+		ttl:     ttl,
+	}
+}
+
+// FileID returns the file ID of a file, the same file always gets the same ID. It fits in 52 bits, so the JavaScript
+// clients don't lose precision.
+func FileID(file File) int64 {
+	sum := sha256.Sum256([]byte(file.SubtitleID + "\x00" + file.DownloadQuery))
+	return int64(binary.BigEndian.Uint64(sum[:8])>>12) | 1
+}
+
+// Put stores the file and returns its ID as returned by FileID.
+func (s *Store) Put(ctx context.Context, file File) (int64, error) {
+	b, err := json.Marshal(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to json.Marshal: %w", err)
+	}
+
+	fileID := FileID(file)
+	if err = s.backend.Set(ctx, storeKey(fileID), b, s.ttl); err != nil {
+		return 0, fmt.Errorf("failed to cache.Backend.Set: %w", err)
+	}
+
+	return fileID, nil
+}
+
+// Get returns the file stored under fileID, or cache.ErrNotFound if it's unknown or expired.
+func (s *Store) Get(ctx context.Context, fileID int64) (File, error) {
+	b, err := s.backend.Get(ctx, storeKey(fileID))
+	if err != nil {
+		return File{}, fmt.Errorf("failed to cache.Backend.Get: %w", err)
+	}
+
+	var file File
+	if err = json.Unmarshal(b, &file); err != nil {
+		return File{}, fmt.Errorf("failed to json.Unmarshal: %w", err)
+	}
+
+	return file, nil
+}
+
+func storeKey(fileID int64) string {
+	return cache.NewKey("opensubtitles.file", storeCacheVersion, fileID).String()
+}
@@ -0,0 +1,34 @@
+package opensubtitles_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+	"github.com/ogero/stremio-subdivx/internal/opensubtitles"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := opensubtitles.NewStore(cache.NewMemoryBackend(64*1024), time.Hour)
+	file := opensubtitles.File{SubtitleID: "0b5bd3a4-0c39-4a53-a3f6-0e0d1c2a7a51", DownloadQuery: "?title=tt0133093"}
+
+	fileID, err := store.Put(ctx, file)
+	require.NoError(t, err)
+	assert.Equal(t, opensubtitles.FileID(file), fileID)
+	assert.Positive(t, fileID)
+	assert.Less(t, fileID, int64(1)<<53)
+
+	got, err := store.Get(ctx, fileID)
+	require.NoError(t, err)
+	assert.Equal(t, file, got)
+
+	// The same subtitle listed for another title is another file
+	assert.NotEqual(t, fileID, opensubtitles.FileID(opensubtitles.File{SubtitleID: file.SubtitleID}))
+
+	_, err = store.Get(ctx, fileID+2)
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+}
@@ -0,0 +1,258 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// idSeparator separates the namespace from the provider ID in the qualified subtitle IDs, as in "local:abc".
+const idSeparator = ":"
+
+// SearchFunc searches the subtitles of a single provider, the Aggregator calls it for every provider concurrently.
+type SearchFunc func(ctx context.Context, p Provider) ([]*subx.Subtitle, error)
+
+type registration struct {
+	provider Provider
+	timeout  time.Duration
+}
+
+// Aggregator searches several providers concurrently and merges their results. The subtitle IDs of the first
+// registered provider, the primary one, are left unqualified, so its IDs keep working as they did before the other
+// providers were added. The IDs of the others are prefixed with their namespace.
+type Aggregator struct {
+	registrations []registration
+}
+
+/*
+NewAggregator creates a new instance of the Aggregator struct.
+
+Returns:
+  - A pointer to the newly created Aggregator instance, without providers.
+*/
+func NewAggregator() *Aggregator {
+	return &Aggregator{}
+}
+
+// Register adds a provider, whose searches and downloads are canceled after timeout. Zero doesn't time them out.
+// The preferred providers win the duplicates, then the ones registered first, and the first one is the primary provider.
+func (a *Aggregator) Register(p Provider, timeout time.Duration) error {
+	namespace := p.Namespace()
+	if namespace == "" || strings.Contains(namespace, idSeparator) {
+		return fmt.Errorf("invalid provider namespace %q", namespace)
+	}
+	for _, r := range a.registrations {
+		if r.provider.Namespace() == namespace {
+			return fmt.Errorf("provider %q already registered", namespace)
+		}
+	}
+
+	a.registrations = append(a.registrations, registration{provider: p, timeout: timeout})
+	return nil
+}
+
+// Len returns the number of providers registered, nil-safe.
+func (a *Aggregator) Len() int {
+	if a == nil {
+		return 0
+	}
+	return len(a.registrations)
+}
+
+// QualifyID returns the ID of a subtitle of the provider, prefixed with its namespace unless it's the primary provider.
+func (a *Aggregator) QualifyID(p Provider, id string) string {
+	if len(a.registrations) > 0 && a.registrations[0].provider == p {
+		return id
+	}
+	return p.Namespace() + idSeparator + id
+}
+
+// Resolve returns the provider of a qualified subtitle ID and the ID the provider knows it by. Unqualified IDs belong
+// to the primary provider.
+func (a *Aggregator) Resolve(qualifiedID string) (Provider, string, error) {
+	r, id, err := a.resolve(qualifiedID)
+	if err != nil {
+		return nil, "", err
+	}
+	return r.provider, id, nil
+}
+
+func (a *Aggregator) resolve(qualifiedID string) (registration, string, error) {
+	if len(a.registrations) == 0 {
+		return registration{}, "", ErrUnknownProvider
+	}
+
+	r, id := a.registrations[0], qualifiedID
+	if namespace, rest, ok := strings.Cut(qualifiedID, idSeparator); ok {
+		found := false
+		for _, candidate := range a.registrations {
+			if candidate.provider.Namespace() == namespace {
+				r, id, found = candidate, rest, true
+				break
+			}
+		}
+		if !found {
+			return registration{}, "", fmt.Errorf("%w: %q", ErrUnknownProvider, namespace)
+		}
+	}
+
+	if err := r.provider.ValidateID(id); err != nil {
+		return registration{}, "", err
+	}
+
+	return r, id, nil
+}
+
+/*
+Search runs the search of every provider whose capabilities are accepted by capable, concurrently and each with its
+timeout, and merges their subtitles with their IDs qualified, the preferred providers ones first and then in
+registration order. The subtitles listed by several providers are kept once, as the first of them lists them.
+
+The providers that fail are skipped, their errors are recorded on their spans and only returned when no provider found
+any subtitle, the one of the first provider that failed.
+*/
+func (a *Aggregator) Search(ctx context.Context, capable func(Capabilities) bool, search SearchFunc) ([]*subx.Subtitle, error) {
+	span := trace.SpanFromContext(ctx)
+
+	type result struct {
+		subtitles []*subx.Subtitle
+		err       error
+	}
+
+	results := make([]*result, len(a.registrations))
+	var wg sync.WaitGroup
+	for i, r := range a.registrations {
+		if capable != nil && !capable(r.provider.Capabilities()) {
+			continue
+		}
+
+		results[i] = &result{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("").Start(ctx, "provider.Aggregator.Search")
+			defer span.End()
+			span.SetAttributes(attribute.String("provider.namespace", r.provider.Namespace()))
+
+			if r.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, r.timeout)
+				defer cancel()
+			}
+
+			subtitles, err := search(ctx, r.provider)
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.SetAttributes(attribute.Int("provider.subtitles-count", len(subtitles)))
+			results[i].subtitles, results[i].err = subtitles, err
+		}()
+	}
+	wg.Wait()
+
+	var merged []*subx.Subtitle
+	var firstErr error
+	// The providers list their subtitles once, only the ones listed by other providers are duplicates
+	seenIDs := make(map[string]struct{})
+	seenBy := make(map[string]int)
+	for _, i := range a.mergeOrder() {
+		res := results[i]
+		if res == nil {
+			continue
+		}
+
+		p := a.registrations[i].provider
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to search %s: %w", p.Namespace(), res.err)
+			}
+			continue
+		}
+
+		for _, subtitle := range res.subtitles {
+			qualified := *subtitle
+			qualified.ID = a.QualifyID(p, subtitle.ID)
+
+			if _, ok := seenIDs[qualified.ID]; ok {
+				continue
+			}
+			seenIDs[qualified.ID] = struct{}{}
+			if key := duplicateKey(subtitle); key != "" {
+				if provider, ok := seenBy[key]; ok && provider != i {
+					continue
+				}
+				seenBy[key] = i
+			}
+
+			merged = append(merged, &qualified)
+		}
+	}
+	span.SetAttributes(attribute.Int("provider.merged-count", len(merged)))
+
+	if len(merged) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+
+	return merged, nil
+}
+
+// mergeOrder returns the indexes of the registrations, the preferred providers first.
+func (a *Aggregator) mergeOrder() []int {
+	order := make([]int, 0, len(a.registrations))
+	for _, preferred := range []bool{true, false} {
+		for i, r := range a.registrations {
+			if r.provider.Capabilities().Preferred == preferred {
+				order = append(order, i)
+			}
+		}
+	}
+	return order
+}
+
+// Preferred reports whether the subtitle of a qualified ID comes from a preferred provider, nil-safe.
+func (a *Aggregator) Preferred(qualifiedID string) bool {
+	if a == nil {
+		return false
+	}
+	r, _, err := a.resolve(qualifiedID)
+	return err == nil && r.provider.Capabilities().Preferred
+}
+
+// Download downloads a subtitle from the provider of its qualified ID, within the provider timeout.
+func (a *Aggregator) Download(ctx context.Context, apiKey string, qualifiedID string, season int, episode int) (*subx.SubtitleContents, error) {
+	r, id, err := a.resolve(qualifiedID)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	subtitle, err := r.provider.Download(ctx, apiKey, id, season, episode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from %s: %w", r.provider.Namespace(), err)
+	}
+
+	return subtitle, nil
+}
+
+// duplicateKey identifies the subtitles several providers list, the same title, season and description. It's empty
+// for the subtitles without a description, they can't be told apart.
+func duplicateKey(subtitle *subx.Subtitle) string {
+	description := strings.Join(strings.Fields(strings.ToLower(subtitle.Description)), " ")
+	if description == "" {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(subtitle.Title)) + "\x00" + strconv.Itoa(subtitle.Season) + "\x00" + description
+}
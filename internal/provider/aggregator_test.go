@@ -0,0 +1,141 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/provider"
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	namespace    string
+	capabilities provider.Capabilities
+	subtitles    []*subx.Subtitle
+	err          error
+	delay        time.Duration
+}
+
+func (p *fakeProvider) Namespace() string                   { return p.namespace }
+func (p *fakeProvider) Capabilities() provider.Capabilities { return p.capabilities }
+func (p *fakeProvider) ValidateID(id string) error {
+	if id == "" {
+		return provider.ErrInvalidID
+	}
+	return nil
+}
+
+func (p *fakeProvider) Search(ctx context.Context, _ provider.Query) ([]*subx.Subtitle, error) {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return p.subtitles, p.err
+}
+
+func (p *fakeProvider) Download(_ context.Context, _ string, id string, _ int, _ int) (*subx.SubtitleContents, error) {
+	return &subx.SubtitleContents{Name: p.namespace + "/" + id}, nil
+}
+
+func search(ctx context.Context, p provider.Provider) ([]*subx.Subtitle, error) {
+	return p.Search(ctx, provider.Query{IMDBID: "tt0133093"})
+}
+
+func TestAggregatorSearchMerges(t *testing.T) {
+	primary := &fakeProvider{namespace: "subx", subtitles: []*subx.Subtitle{
+		{ID: "a", Title: "The Matrix (1999)", Description: "BluRay 1080p"},
+		{ID: "b", Title: "The Matrix (1999)", Description: "BluRay 1080p"},
+	}}
+	local := &fakeProvider{namespace: "local", subtitles: []*subx.Subtitle{
+		{ID: "a", Title: "The Matrix (1999)", Description: "WEB-DL 720p"},
+		{ID: "c", Title: "the matrix (1999)", Description: "bluray  1080P"},
+	}}
+
+	aggregator := provider.NewAggregator()
+	require.NoError(t, aggregator.Register(primary, time.Second))
+	require.NoError(t, aggregator.Register(local, time.Second))
+	assert.Error(t, aggregator.Register(&fakeProvider{namespace: "local"}, time.Second))
+	assert.Error(t, aggregator.Register(&fakeProvider{namespace: "a:b"}, time.Second))
+
+	subtitles, err := aggregator.Search(context.Background(), nil, search)
+	require.NoError(t, err)
+
+	// The duplicates of a provider are kept, the ones of another provider aren't
+	ids := make([]string, len(subtitles))
+	for i, subtitle := range subtitles {
+		ids[i] = subtitle.ID
+	}
+	assert.Equal(t, []string{"a", "b", "local:a"}, ids)
+
+	// The provider results aren't modified
+	assert.Equal(t, "a", local.subtitles[0].ID)
+}
+
+func TestAggregatorSearchErrors(t *testing.T) {
+	failing := &fakeProvider{namespace: "subx", err: errors.New("unavailable")}
+	slow := &fakeProvider{namespace: "slow", delay: time.Minute, subtitles: []*subx.Subtitle{{ID: "a"}}}
+	local := &fakeProvider{namespace: "local", subtitles: []*subx.Subtitle{{ID: "a"}}}
+
+	aggregator := provider.NewAggregator()
+	require.NoError(t, aggregator.Register(failing, time.Second))
+	require.NoError(t, aggregator.Register(slow, 10*time.Millisecond))
+	require.NoError(t, aggregator.Register(local, time.Second))
+
+	subtitles, err := aggregator.Search(context.Background(), nil, search)
+	require.NoError(t, err)
+	require.Len(t, subtitles, 1)
+	assert.Equal(t, "local:a", subtitles[0].ID)
+
+	// The errors are only returned when no provider found subtitles
+	subtitles, err = aggregator.Search(context.Background(), func(c provider.Capabilities) bool { return !c.Cached }, func(ctx context.Context, p provider.Provider) ([]*subx.Subtitle, error) {
+		if p == local {
+			return nil, nil
+		}
+		return p.Search(ctx, provider.Query{})
+	})
+	assert.Empty(t, subtitles)
+	assert.ErrorContains(t, err, "unavailable")
+}
+
+func TestAggregatorResolve(t *testing.T) {
+	primary := &fakeProvider{namespace: "subx"}
+	local := &fakeProvider{namespace: "local"}
+
+	aggregator := provider.NewAggregator()
+	require.NoError(t, aggregator.Register(primary, 0))
+	require.NoError(t, aggregator.Register(local, 0))
+
+	tests := []struct {
+		id       string
+		provider provider.Provider
+		localID  string
+		wantErr  error
+	}{
+		{id: "abc", provider: primary, localID: "abc"},
+		{id: "subx:abc", provider: primary, localID: "abc"},
+		{id: "local:movies/abc.srt", provider: local, localID: "movies/abc.srt"},
+		{id: "other:abc", wantErr: provider.ErrUnknownProvider},
+		{id: "local:", wantErr: provider.ErrInvalidID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			p, id, err := aggregator.Resolve(tt.id)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Same(t, tt.provider, p)
+			assert.Equal(t, tt.localID, id)
+		})
+	}
+
+	contents, err := aggregator.Download(context.Background(), "", "local:abc", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "local/abc", contents.Name)
+}
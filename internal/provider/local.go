@@ -0,0 +1,395 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/common"
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+)
+
+const (
+	// localMaxFileSize bounds the subtitle files served, as SubX bounds its downloads.
+	localMaxFileSize = 500 * 1024
+	// localMaxSidecarSize bounds the sidecar JSON files.
+	localMaxSidecarSize = 64 * 1024
+	// localUploader is the uploader of the subtitles whose sidecar names none.
+	localUploader = "local"
+)
+
+var (
+	localIDRegexp     = regexp.MustCompile(`^[0-9a-f]{16}$`)
+	localIMDBIDRegexp = regexp.MustCompile(`(?:^|[^0-9A-Za-z])(tt\d{7,10})(?:$|[^0-9A-Za-z])`)
+	localSeasonRegexp = regexp.MustCompile(`(?i)^(?:season|temporada|s)[ ._-]*(\d{1,2})$`)
+)
+
+// LocalSidecar is the optional JSON file next to a subtitle, named after it with the .json extension, as in
+// "The.Matrix.1999.1080p.json" for "The.Matrix.1999.1080p.srt". Its fields override what the folder conventions say.
+type LocalSidecar struct {
+	// IMDBID is the IMDB ID of the movie or the series, such as "tt0133093".
+	IMDBID string `json:"imdbId"`
+	// Title is the title listed, the release name by default.
+	Title string `json:"title"`
+	// Season and Episode are the episode the subtitle is for, a season alone is a season pack.
+	Season  int `json:"season"`
+	Episode int `json:"episode"`
+	// Release is the release the subtitle is synced to, the filename without its extension by default.
+	Release string `json:"release"`
+	// Uploader is who made or synced the subtitle.
+	Uploader string `json:"uploader"`
+}
+
+type localIndex struct {
+	// subtitles are the subtitles of every IMDB ID.
+	subtitles map[string][]*subx.Subtitle
+	// paths are the file paths of every subtitle ID.
+	paths map[string]string
+	// fingerprint changes whenever a subtitle or sidecar file is added, removed or modified.
+	fingerprint string
+	// skipped are the errors of the files and directories that couldn't be indexed.
+	skipped []error
+}
+
+/*
+Local is the provider of the subtitles of a local directory tree, such as the curated and hand-synced ones of a NAS.
+Its subtitles are preferred over the ones of the other providers.
+
+Only the .srt files are indexed, by the IMDB ID, season, episode and release their sidecar says or else their path says:
+  - The IMDB ID is the one of the deepest directory whose name holds it, as in "The Matrix (1999) [tt0133093]".
+  - The season and episode are parsed from the filename, as in "Breaking.Bad.S01E02.720p.srt", or else from the
+    directories, as in "S01E02" or "Season 1".
+  - The release is the filename without its extension.
+
+The files that can't be indexed, such as the ones with a malformed sidecar, are skipped, see Skipped.
+*/
+type Local struct {
+	root  string
+	index atomic.Pointer[localIndex]
+}
+
+/*
+NewLocal creates a new instance of the Local provider and indexes the directory tree.
+
+Parameters:
+  - root: The directory tree holding the subtitles.
+
+Returns:
+  - A pointer to the newly created Local instance.
+  - An error if the root directory can't be read, the files that can't be indexed are skipped.
+*/
+func NewLocal(root string) (*Local, error) {
+	p := &Local{
+		root: root,
+	}
+	if err := p.Index(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Namespace returns "local".
+func (p *Local) Namespace() string {
+	return "local"
+}
+
+// Capabilities returns the Local capabilities, it searches by IMDB ID from memory and its subtitles are preferred.
+func (p *Local) Capabilities() Capabilities {
+	return Capabilities{IMDBSearch: true, Preferred: true}
+}
+
+// ValidateID checks the ID is a local subtitle ID, the hash of its path.
+func (p *Local) ValidateID(id string) error {
+	if !localIDRegexp.MatchString(id) {
+		return fmt.Errorf("%w: invalid local subtitle id %q", ErrInvalidID, id)
+	}
+	return nil
+}
+
+// Search returns the subtitles indexed for the IMDB ID of the query, it doesn't search by title.
+func (p *Local) Search(_ context.Context, query Query) ([]*subx.Subtitle, error) {
+	if query.IMDBID == "" {
+		return nil, nil
+	}
+
+	indexed := p.index.Load().subtitles[query.IMDBID]
+	subtitles := make([]*subx.Subtitle, len(indexed))
+	for i, subtitle := range indexed {
+		copied := *subtitle
+		subtitles[i] = &copied
+	}
+	return subtitles, nil
+}
+
+// Download reads the subtitle file, the season and episode are ignored as the archives aren't indexed.
+func (p *Local) Download(_ context.Context, _ string, id string, _ int, _ int) (*subx.SubtitleContents, error) {
+	filePath, ok := p.index.Load().paths[id]
+	if !ok {
+		return nil, fmt.Errorf("local subtitle %q not found", id)
+	}
+
+	data, err := readFileLimited(filePath, localMaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("local subtitle is empty")
+	}
+
+	return &subx.SubtitleContents{Name: filepath.Base(filePath), Data: data}, nil
+}
+
+// Len returns the number of subtitles indexed.
+func (p *Local) Len() int {
+	return len(p.index.Load().paths)
+}
+
+// Skipped returns the errors of the files and directories the current index skipped.
+func (p *Local) Skipped() []error {
+	return p.index.Load().skipped
+}
+
+// Index indexes the directory tree and replaces the current index with it, the current one is kept on error. Only the
+// root directory failing is an error, the files that can't be indexed are skipped.
+func (p *Local) Index() error {
+	index, err := p.scan()
+	if err != nil {
+		return err
+	}
+	p.index.Store(index)
+	return nil
+}
+
+// Watch indexes the directory tree every interval when a subtitle or sidecar file was added, removed or modified, until
+// ctx is done. It blocks so it's meant to run in a goroutine. Failed indexings are reported to onError and keep the
+// current index, the files skipped by a new index are reported to onError once.
+func (p *Local) Watch(ctx context.Context, interval time.Duration, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		files, fingerprint, skipped, err := p.walk()
+		if err != nil {
+			onError(err)
+			continue
+		}
+		if fingerprint == p.index.Load().fingerprint {
+			continue
+		}
+
+		index := p.build(files, fingerprint, skipped)
+		p.index.Store(index)
+		for _, err = range index.skipped {
+			onError(err)
+		}
+	}
+}
+
+type localFile struct {
+	path    string
+	modTime time.Time
+}
+
+func (p *Local) scan() (*localIndex, error) {
+	files, fingerprint, skipped, err := p.walk()
+	if err != nil {
+		return nil, err
+	}
+	return p.build(files, fingerprint, skipped), nil
+}
+
+// walk lists the subtitle files of the directory tree, and fingerprints them along with their sidecars. The entries
+// that can't be read are skipped and returned along, only the root failing is an error.
+func (p *Local) walk() ([]localFile, string, []error, error) {
+	var files []localFile
+	var skipped []error
+	hash := sha256.New()
+	err := filepath.WalkDir(p.root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil && filePath == p.root {
+			return err
+		} else if err != nil {
+			skipped = append(skipped, fmt.Errorf("skipped %s: %w", filePath, err))
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		extension := strings.ToLower(filepath.Ext(filePath))
+		if extension != ".srt" && extension != ".json" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("skipped %s: %w", filePath, err))
+			return nil
+		}
+		_, _ = fmt.Fprintf(hash, "%s\x00%d\x00%d\x00", filePath, info.Size(), info.ModTime().UnixNano())
+
+		if extension == ".srt" {
+			files = append(files, localFile{path: filePath, modTime: info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to filepath.WalkDir: %w", err)
+	}
+
+	return files, hex.EncodeToString(hash.Sum(nil)), skipped, nil
+}
+
+// build indexes the subtitle files, the ones without an IMDB ID are left out and the ones whose sidecar can't be read
+// are skipped, adding their errors to the skipped ones of walk.
+func (p *Local) build(files []localFile, fingerprint string, skipped []error) *localIndex {
+	index := &localIndex{
+		subtitles:   make(map[string][]*subx.Subtitle),
+		paths:       make(map[string]string, len(files)),
+		fingerprint: fingerprint,
+		skipped:     skipped,
+	}
+
+	for _, file := range files {
+		relPath, err := filepath.Rel(p.root, file.path)
+		if err != nil {
+			index.skipped = append(index.skipped, fmt.Errorf("skipped %s: failed to filepath.Rel: %w", file.path, err))
+			continue
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		sidecar, err := readSidecar(strings.TrimSuffix(file.path, filepath.Ext(file.path)) + ".json")
+		if err != nil {
+			index.skipped = append(index.skipped, fmt.Errorf("skipped %s: failed to read its sidecar: %w", relPath, err))
+			continue
+		}
+
+		subtitle := localSubtitle(relPath, sidecar)
+		if subtitle.IMDBID == "" {
+			continue
+		}
+		subtitle.PostedAt = file.modTime.UTC().Format(time.RFC3339)
+
+		index.subtitles[subtitle.IMDBID] = append(index.subtitles[subtitle.IMDBID], subx.NewSubtitle(subtitle))
+		index.paths[subtitle.ID] = file.path
+	}
+
+	for _, subtitles := range index.subtitles {
+		sort.Slice(subtitles, func(i, j int) bool { return subtitles[i].Description < subtitles[j].Description })
+	}
+
+	return index
+}
+
+// localSubtitle returns the subtitle at the slash separated path relative to the root, from the folder conventions and
+// the sidecar. Its ID is the hash of the path, so it's stable across indexings.
+func localSubtitle(relPath string, sidecar LocalSidecar) *subx.Subtitle {
+	sum := sha256.Sum256([]byte(relPath))
+	dirs := strings.Split(path.Dir(relPath), "/")
+	release := strings.TrimSuffix(path.Base(relPath), path.Ext(relPath))
+
+	subtitle := &subx.Subtitle{
+		ID:           hex.EncodeToString(sum[:8]),
+		Title:        release,
+		Description:  release,
+		UploaderName: localUploader,
+	}
+
+	for i := len(dirs) - 1; i >= 0 && subtitle.IMDBID == ""; i-- {
+		if m := localIMDBIDRegexp.FindStringSubmatch(dirs[i]); m != nil {
+			subtitle.IMDBID = m[1]
+		}
+	}
+
+	// The episodes in the filename are parsed by subx.NewSubtitle, the directories are the fallback
+	if _, ok := subx.ParseEpisodes(release); !ok {
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if info, ok := subx.ParseEpisodes(dirs[i]); ok && len(info.Episodes) > 0 {
+				subtitle.Season, subtitle.Episode = info.Season, info.Episodes[0]
+				break
+			}
+			if m := localSeasonRegexp.FindStringSubmatch(dirs[i]); m != nil {
+				subtitle.Season, _ = strconv.Atoi(m[1])
+				break
+			}
+		}
+	}
+
+	if sidecar.IMDBID != "" {
+		subtitle.IMDBID = sidecar.IMDBID
+	}
+	if sidecar.Release != "" {
+		subtitle.Title, subtitle.Description = sidecar.Release, sidecar.Release
+	}
+	if sidecar.Title != "" {
+		subtitle.Title = sidecar.Title
+	}
+	if sidecar.Season > 0 || sidecar.Episode > 0 {
+		subtitle.Season, subtitle.Episode = sidecar.Season, sidecar.Episode
+	}
+	if sidecar.Uploader != "" {
+		subtitle.UploaderName = sidecar.Uploader
+	}
+	// The folders and sidecars of whole seasons are season packs, the filenames ones are parsed by subx.NewSubtitle
+	subtitle.SeasonPack = subtitle.Season > 0 && subtitle.Episode == 0
+
+	return subtitle
+}
+
+// readSidecar reads the sidecar JSON file at filePath, a missing one is empty.
+func readSidecar(filePath string) (LocalSidecar, error) {
+	var sidecar LocalSidecar
+
+	data, err := readFileLimited(filePath, localMaxSidecarSize)
+	if errors.Is(err, fs.ErrNotExist) {
+		return sidecar, nil
+	} else if err != nil {
+		return sidecar, err
+	}
+
+	if err = json.Unmarshal(data, &sidecar); err != nil {
+		return sidecar, fmt.Errorf("failed to json.Unmarshal: %w", err)
+	}
+	if sidecar.IMDBID != "" {
+		if err = common.ValidateIMDBTitleID(sidecar.IMDBID); err != nil {
+			return sidecar, fmt.Errorf("failed to common.ValidateIMDBTitleID: %w", err)
+		}
+	}
+
+	return sidecar, nil
+}
+
+// readFileLimited reads the file at filePath, failing with subx.ErrReadBeyondLimit when it's bigger than limit.
+func readFileLimited(filePath string, limit int64) ([]byte, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to os.Open: %w", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(subx.LimitReader(f, limit, subx.ErrReadBeyondLimit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to io.ReadAll: %w", err)
+	}
+	return data, nil
+}
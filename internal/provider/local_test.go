@@ -0,0 +1,143 @@
+package provider_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/provider"
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, root string, name string, data string) {
+	t.Helper()
+	filePath := filepath.Join(root, filepath.FromSlash(name))
+	require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0o755))
+	require.NoError(t, os.WriteFile(filePath, []byte(data), 0o644))
+}
+
+func TestLocalIndexesFolderConventions(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "The Matrix (1999) [tt0133093]/The.Matrix.1999.1080p.BluRay.srt", "1\n00:00:01,000 --> 00:00:02,000\nHola\n")
+	writeFile(t, root, "Breaking Bad tt0903747/Season 1/Breaking.Bad.S01E02.720p.srt", "1")
+	writeFile(t, root, "Breaking Bad tt0903747/S02E03/Breaking.Bad.720p.srt", "1")
+	writeFile(t, root, "Breaking Bad tt0903747/Season 3/Breaking.Bad.Complete.srt", "1")
+	writeFile(t, root, "Unknown/Some.Movie.srt", "1")
+	writeFile(t, root, "The Matrix (1999) [tt0133093]/notes.txt", "1")
+
+	local, err := provider.NewLocal(root)
+	require.NoError(t, err)
+	assert.Equal(t, 4, local.Len())
+
+	subtitles, err := local.Search(context.Background(), provider.Query{IMDBID: "tt0133093"})
+	require.NoError(t, err)
+	require.Len(t, subtitles, 1)
+	assert.Equal(t, "The.Matrix.1999.1080p.BluRay", subtitles[0].Description)
+	assert.Equal(t, "local", subtitles[0].UploaderName)
+	assert.NoError(t, local.ValidateID(subtitles[0].ID))
+
+	contents, err := local.Download(context.Background(), "", subtitles[0].ID, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "The.Matrix.1999.1080p.BluRay.srt", contents.Name)
+	assert.Contains(t, string(contents.Data), "Hola")
+
+	subtitles, err = local.Search(context.Background(), provider.Query{IMDBID: "tt0903747"})
+	require.NoError(t, err)
+	// Sorted by release
+	require.Len(t, subtitles, 3)
+	assert.Equal(t, "Breaking.Bad.720p", subtitles[0].Description)
+	assert.True(t, subtitles[0].MatchesEpisode(subx.EpisodeQuery{Season: 2, Episode: 3}))
+	assert.True(t, subtitles[1].SeasonPack)
+	assert.True(t, subtitles[1].MatchesEpisode(subx.EpisodeQuery{Season: 3, Episode: 7}))
+	assert.True(t, subtitles[2].MatchesEpisode(subx.EpisodeQuery{Season: 1, Episode: 2}))
+	assert.False(t, subtitles[2].MatchesEpisode(subx.EpisodeQuery{Season: 1, Episode: 3}))
+
+	// Titles aren't searched
+	subtitles, err = local.Search(context.Background(), provider.Query{Title: "The Matrix"})
+	require.NoError(t, err)
+	assert.Empty(t, subtitles)
+}
+
+func TestLocalSidecar(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "misc/bb.srt", "1")
+	writeFile(t, root, "misc/bb.json", `{"imdbId": "tt0903747", "title": "Breaking Bad", "season": 1, "episode": 5, "release": "Breaking.Bad.S01E05.WEB-DL", "uploader": "team"}`)
+
+	local, err := provider.NewLocal(root)
+	require.NoError(t, err)
+
+	subtitles, err := local.Search(context.Background(), provider.Query{IMDBID: "tt0903747"})
+	require.NoError(t, err)
+	require.Len(t, subtitles, 1)
+	assert.Equal(t, "Breaking Bad", subtitles[0].Title)
+	assert.Equal(t, "Breaking.Bad.S01E05.WEB-DL", subtitles[0].Description)
+	assert.Equal(t, "team", subtitles[0].UploaderName)
+	assert.Equal(t, []int{5}, subtitles[0].Episodes)
+
+	// Malformed sidecars skip their subtitle, the others are still indexed
+	writeFile(t, root, "misc/bb.json", `{"imdbId": "0903747"}`)
+	writeFile(t, root, "tt0133093/The.Matrix.1999.srt", "1")
+	require.NoError(t, local.Index())
+	assert.Equal(t, 1, local.Len())
+	require.Len(t, local.Skipped(), 1)
+	assert.ErrorContains(t, local.Skipped()[0], "misc/bb.srt")
+
+	subtitles, err = local.Search(context.Background(), provider.Query{IMDBID: "tt0133093"})
+	require.NoError(t, err)
+	assert.Len(t, subtitles, 1)
+}
+
+func TestLocalWatch(t *testing.T) {
+	root := t.TempDir()
+
+	local, err := provider.NewLocal(root)
+	require.NoError(t, err)
+	assert.Equal(t, 0, local.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		local.Watch(ctx, 10*time.Millisecond, func(err error) { t.Error(err) })
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	writeFile(t, root, "tt0133093/The.Matrix.1999.srt", "1")
+	assert.Eventually(t, func() bool { return local.Len() == 1 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.Remove(filepath.Join(root, "tt0133093", "The.Matrix.1999.srt")))
+	assert.Eventually(t, func() bool { return local.Len() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestAggregatorPrefersLocal(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "tt0133093/The.Matrix.1999.1080p.BluRay.srt", "1")
+
+	local, err := provider.NewLocal(root)
+	require.NoError(t, err)
+	remote := &fakeProvider{namespace: "subx", subtitles: []*subx.Subtitle{
+		{ID: "a", Title: "The.Matrix.1999.1080p.BluRay", Description: "The.Matrix.1999.1080p.BluRay"},
+		{ID: "b", Title: "The Matrix (1999)", Description: "WEB-DL"},
+	}}
+
+	aggregator := provider.NewAggregator()
+	require.NoError(t, aggregator.Register(remote, time.Second))
+	require.NoError(t, aggregator.Register(local, time.Second))
+
+	subtitles, err := aggregator.Search(context.Background(), nil, search)
+	require.NoError(t, err)
+
+	// The local copy of the SubX subtitle wins the duplicate
+	require.Len(t, subtitles, 2)
+	assert.Regexp(t, `^local:[0-9a-f]{16}$`, subtitles[0].ID)
+	assert.True(t, aggregator.Preferred(subtitles[0].ID))
+	assert.Equal(t, "b", subtitles[1].ID)
+	assert.False(t, aggregator.Preferred(subtitles[1].ID))
+}
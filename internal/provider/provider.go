@@ -0,0 +1,59 @@
+/*
+Package provider abstracts the subtitle sources, so the addon can merge the SubX subtitles with the ones of other
+sources. Every provider owns an ID namespace, and the Aggregator searches them concurrently and merges their results.
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+)
+
+var (
+	// ErrUnknownProvider is returned for the subtitle IDs qualified with a namespace no provider owns.
+	ErrUnknownProvider = errors.New("unknown provider")
+	// ErrInvalidID is returned for the subtitle IDs their provider doesn't accept.
+	ErrInvalidID = errors.New("invalid subtitle id")
+)
+
+// Capabilities describes what a provider supports.
+type Capabilities struct {
+	// IMDBSearch reports whether the provider searches the subtitles by IMDB ID.
+	IMDBSearch bool
+	// TitleSearch reports whether the provider searches the subtitles by title name, as the title search fallback and
+	// the filename search do.
+	TitleSearch bool
+	// Cached reports whether the search results are worth caching, as the remote providers ones are.
+	Cached bool
+	// Preferred reports whether the subtitles of the provider are listed ahead of the others, as the curated ones are.
+	Preferred bool
+}
+
+// Query is what a provider search looks for, either IMDBID or Title.
+type Query struct {
+	// APIKey is the SubX API key of the user, the providers that need none ignore it.
+	APIKey string
+	// Type is the Stremio type of the title searched, "movie" or "series", empty if unknown.
+	Type string
+	// IMDBID searches the subtitles of an IMDB title, such as "tt0133093".
+	IMDBID string
+	// Title searches the subtitles by title name when IMDBID is empty.
+	Title string
+}
+
+// Provider is a source of subtitles. The subtitles it returns carry its own IDs, the Aggregator qualifies them with its
+// namespace.
+type Provider interface {
+	// Namespace is the unique name of the provider, such as "subx", prefixed to the IDs of its subtitles.
+	Namespace() string
+	// Capabilities returns what the provider supports.
+	Capabilities() Capabilities
+	// ValidateID checks a subtitle ID of the provider, without its namespace.
+	ValidateID(id string) error
+	// Search returns the subtitles matching the query.
+	Search(ctx context.Context, query Query) ([]*subx.Subtitle, error)
+	// Download returns the file of a subtitle, the one of the season and episode for the archives holding several.
+	Download(ctx context.Context, apiKey string, id string, season int, episode int) (*subx.SubtitleContents, error)
+}
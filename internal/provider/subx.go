@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ogero/stremio-subdivx/internal/common"
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+)
+
+// subxSearchLimit is the number of subtitles requested per SubX search.
+const subxSearchLimit = 50
+
+// SubX is the provider of the SubX subtitles.
+type SubX struct {
+	client *subx.SubX
+}
+
+/*
+NewSubX creates a new instance of the SubX provider.
+
+Parameters:
+  - client: The SubX client the subtitles are searched and downloaded with.
+
+Returns:
+  - A pointer to the newly created SubX instance.
+*/
+func NewSubX(client *subx.SubX) *SubX {
+	return &SubX{
+		client: client,
+	}
+}
+
+// Namespace returns "subx".
+func (p *SubX) Namespace() string {
+	return "subx"
+}
+
+// Capabilities returns the SubX capabilities, it searches by IMDB ID and title and its results are cached.
+func (p *SubX) Capabilities() Capabilities {
+	return Capabilities{IMDBSearch: true, TitleSearch: true, Cached: true}
+}
+
+// ValidateID checks the ID is a SubX UUID.
+func (p *SubX) ValidateID(id string) error {
+	if err := common.ValidateSubXSubtitleID(id); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidID, err)
+	}
+	return nil
+}
+
+// Search searches the SubX subtitles by IMDB ID or title.
+func (p *SubX) Search(ctx context.Context, query Query) ([]*subx.Subtitle, error) {
+	subtitles, err := p.client.SearchSubtitles(ctx, query.APIKey, subx.SearchParams{
+		IMDBID: query.IMDBID,
+		Title:  query.Title,
+		Limit:  subxSearchLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to subx.SubX.SearchSubtitles: %w", err)
+	}
+	return subtitles.Subtitles, nil
+}
+
+// Download downloads a SubX subtitle with the API key of the user.
+func (p *SubX) Download(ctx context.Context, apiKey string, id string, season int, episode int) (*subx.SubtitleContents, error) {
+	subtitle, err := p.client.DownloadEpisodeSubtitle(ctx, apiKey, id, season, episode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subx.SubX.DownloadEpisodeSubtitle: %w", err)
+	}
+	return subtitle, nil
+}
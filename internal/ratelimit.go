@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+)
+
+// UserRateLimitKey keys the rate limits by the install, using the userConfig path segment or the user reference of a
+// signed subtitle URL. The signed URLs are verified first, the forged ones would spend the budget of another install.
+func (a *App) UserRateLimitKey(r *http.Request) string {
+	if token := chi.URLParam(r, "userConfig"); token != "" {
+		return userconfig.Ref(token)
+	}
+
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		return ""
+	}
+	claims, err := a.SubtitleURLSigner.Verify(token, chi.URLParam(r, "id"), subtitleDownloadBound(r.URL.Query())...)
+	if err != nil {
+		return ""
+	}
+	// Signed subtitle URLs of the installs without a userConfig share the reference of the empty token
+	if claims.UserRef != userconfig.AnonymousRef {
+		return claims.UserRef
+	}
+	return ""
+}
+
+// APIKeyRateLimitKey keys the rate limits by a hash of the SubX API key of the user config stored by UserConfigMiddleware.
+func APIKeyRateLimitKey(r *http.Request) string {
+	config := userconfig.FromContext(r.Context())
+	if config == nil || config.APIKey == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(config.APIKey))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		xForwardedFor string
+		want          string
+	}{
+		{"direct client", "203.0.113.7:1234", "", "203.0.113.7"},
+		{"spoofed header from untrusted client", "203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:1234", "198.51.100.1", "198.51.100.1"},
+		{"chained trusted proxies", "10.1.2.3:1234", "198.51.100.9, 198.51.100.1, 192.168.1.1", "198.51.100.1"},
+		{"only trusted proxies", "10.1.2.3:1234", "10.4.5.6", "10.4.5.6"},
+		{"garbage header", "10.1.2.3:1234", "not-an-ip", "10.1.2.3"},
+		{"ipv4 mapped ipv6", "[::ffff:203.0.113.7]:1234", "", "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xForwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.xForwardedFor)
+			}
+			assert.Equal(t, tt.want, proxies.ClientIP(r))
+		})
+	}
+
+	_, err = ParseTrustedProxies("10.0.0.0/33")
+	assert.Error(t, err)
+}
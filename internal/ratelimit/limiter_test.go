@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Limit
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{"30/m", Limit{Requests: 30, Per: time.Minute}, assert.NoError},
+		{"100/1h", Limit{Requests: 100, Per: time.Hour}, assert.NoError},
+		{" 5 / 10s ", Limit{Requests: 5, Per: 10 * time.Second}, assert.NoError},
+		{"", Limit{}, assert.NoError},
+		{"0/m", Limit{}, assert.NoError},
+		{"30", Limit{}, assert.Error},
+		{"x/m", Limit{}, assert.Error},
+		{"30/fortnight", Limit{}, assert.Error},
+		{"-1/m", Limit{}, assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseLimit(tt.s)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	limiter := NewLimiter(Limit{Requests: 2, Per: time.Minute})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		allowed, _ := limiter.Allow("a")
+		require.True(t, allowed)
+	}
+
+	allowed, retryAfter := limiter.Allow("a")
+	assert.False(t, allowed)
+	assert.Equal(t, 30*time.Second, retryAfter)
+
+	allowed, _ = limiter.Allow("b")
+	assert.True(t, allowed, "keys must have independent buckets")
+
+	now = now.Add(30 * time.Second)
+	allowed, _ = limiter.Allow("a")
+	assert.True(t, allowed, "a token must be refilled after Per/Requests")
+
+	now = now.Add(2 * time.Minute)
+	limiter.Allow("c")
+	assert.Len(t, limiter.buckets, 1, "idle buckets must be swept")
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	limiter := NewLimiter(Limit{})
+	for i := 0; i < 100; i++ {
+		allowed, _ := limiter.Allow("a")
+		require.True(t, allowed)
+	}
+}
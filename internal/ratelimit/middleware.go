@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// KeyFunc returns the key a request is limited by, an empty key skips the rule.
+type KeyFunc func(r *http.Request) string
+
+// Rule limits the requests by the key returned by Key, scope names the rule in the metrics.
+type Rule struct {
+	Scope   string
+	Key     KeyFunc
+	Limiter *Limiter
+}
+
+// RejectFunc is called for every rejected request, with the route budget and the scope of the rule that rejected it.
+type RejectFunc func(ctx context.Context, route string, scope string)
+
+/*
+Middleware rejects the requests exceeding any of the rules with 429 and a Retry-After header.
+
+Parameters:
+  - route: The name of the budget the rules belong to, like "search" or "download".
+  - onReject: Called for every rejected request, meant to increment a metric. Can be nil.
+  - rules: The rules every request is checked against.
+
+Returns:
+  - The middleware.
+*/
+func Middleware(route string, onReject RejectFunc, rules ...Rule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, rule := range rules {
+				key := rule.Key(r)
+				if key == "" {
+					continue
+				}
+
+				allowed, retryAfter := rule.Limiter.Allow(key)
+				if allowed {
+					continue
+				}
+
+				if onReject != nil {
+					onReject(r.Context(), route, rule.Scope)
+				}
+
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(max(retryAfter, time.Second).Seconds()))))
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
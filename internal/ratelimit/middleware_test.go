@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	var rejections []string
+	handler := Middleware("search", func(ctx context.Context, route string, scope string) {
+		rejections = append(rejections, route+"/"+scope)
+	},
+		Rule{Scope: "ip", Key: TrustedProxies(nil).ClientIP, Limiter: NewLimiter(Limit{Requests: 1, Per: time.Minute})},
+		Rule{Scope: "user", Key: func(r *http.Request) string { return "" }, Limiter: NewLimiter(Limit{Requests: 1, Per: time.Hour})},
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Equal(t, []string{"search/ip"}, rejections)
+}
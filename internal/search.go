@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ogero/stremio-subdivx/internal/common"
+	"github.com/ogero/stremio-subdivx/internal/feedback"
+	"github.com/ogero/stremio-subdivx/internal/titlemeta"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/ogero/stremio-subdivx/internal/videohash"
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxSearchFilenameLength bounds the filenames accepted by the search endpoint.
+const maxSearchFilenameLength = 512
+
+// SearchResponse is the JSON response of the filename search endpoint.
+type SearchResponse struct {
+	// Release is what was parsed from the filename.
+	Release titlemeta.Release `json:"release"`
+	// Subtitles are the subtitles found, the most relevant first.
+	Subtitles []APISubtitle `json:"subtitles"`
+}
+
+/*
+SearchHandler searches subtitles for a raw video filename, for the players that don't know the IMDB ID of what they play.
+
+The filename query parameter is required, videoHash and videoSize are optional and rank the subtitle proven for the
+video file first. It answers with what was parsed from the filename and the ranked subtitles with their download URLs,
+or 400 when the filename has no title.
+*/
+func (a *App) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	common.Log.DebugContext(ctx, "SearchHandler")
+
+	query := r.URL.Query()
+	filename := strings.TrimSpace(query.Get("filename"))
+	if filename == "" || len(filename) > maxSearchFilenameLength {
+		common.Log.WarnContext(ctx, "Failed to get filename", "err", fmt.Errorf("invalid filename"))
+		span.RecordError(fmt.Errorf("invalid filename"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.String("params.filename", filename))
+
+	installToken := chi.URLParam(r, "userConfig")
+	config := userconfig.FromContext(ctx)
+	ctx, apiKey, ok := a.resolveAPIKey(w, r, config, installToken)
+	if !ok {
+		return
+	}
+
+	videoSize, _ := strconv.ParseInt(query.Get("videoSize"), 10, 64)
+	video := videohash.Video{Hash: query.Get("videoHash"), Size: videoSize}
+
+	result, err := a.StremioService.SearchSubtitlesByFilename(ctx, apiKey, filename, SubtitlesOptions{
+		Video:               video,
+		MaxResults:          config.MaxResults,
+		Dialect:             config.Dialect,
+		HideHearingImpaired: config.HideHearingImpaired,
+	})
+	var rateLimitErr *subx.RateLimitError
+	if errors.Is(err, ErrUnparsableFilename) {
+		common.Log.WarnContext(ctx, "Failed to StremioService.SearchSubtitlesByFilename", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	} else if errors.As(err, &rateLimitErr) {
+		common.Log.WarnContext(ctx, "Failed to StremioService.SearchSubtitlesByFilename", "err", err)
+		span.RecordError(err)
+		writeRetryAfter(w, rateLimitErr.RetryAfter)
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to StremioService.SearchSubtitlesByFilename", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	userRef, err := a.UserConfigStore.Put(ctx, installToken)
+	if err != nil {
+		common.Log.ErrorContext(ctx, "Failed to userconfig.Store.Put", "err", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := SearchResponse{
+		Release:   result.Release,
+		Subtitles: make([]APISubtitle, 0, len(result.Subtitles)),
+	}
+	release := feedback.NormalizeRelease(filename)
+	for _, subtitle := range result.Subtitles {
+		titleKey := result.TitleKey
+		if common.ValidateIMDBTitleID(subtitle.IMDBID) == nil {
+			titleKey = feedback.TitleKey(subtitle.IMDBID, result.Release.Season, result.Release.Episode)
+		}
+		response.Subtitles = append(response.Subtitles, a.apiSubtitle(subtitle, userRef, subtitleDownloadQuery(video, titleKey, release)))
+	}
+
+	w.Header().Set("CDN-Cache-Control", "public, max-age=600")
+	w.Header().Set("Cache-Control", "public, max-age=600")
+
+	writeJSON(w, r, response)
+}
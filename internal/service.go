@@ -7,16 +7,23 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/centrifugal/centrifuge"
 	"github.com/ogero/stremio-subdivx/internal/cache"
 	"github.com/ogero/stremio-subdivx/internal/common"
+	"github.com/ogero/stremio-subdivx/internal/feedback"
 	"github.com/ogero/stremio-subdivx/internal/loki"
+	"github.com/ogero/stremio-subdivx/internal/provider"
+	"github.com/ogero/stremio-subdivx/internal/titlemeta"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/ogero/stremio-subdivx/internal/videohash"
 	"github.com/ogero/stremio-subdivx/pkg/subx"
 	"github.com/wlynxg/chardet"
 	"github.com/wlynxg/chardet/consts"
@@ -27,6 +34,9 @@ import (
 	"golang.org/x/text/transform"
 )
 
+// subxSubtitlesCacheVersion is the schema version of the cached subx.Subtitles, bump it when subx.Subtitle changes.
+const subxSubtitlesCacheVersion = 3
+
 // Subtitles struct holds information about subtitles, including their IDs, language, and the year of the content they are associated with.
 type Subtitles struct {
 	// IDs is a list of subtitle IDs.
@@ -45,7 +55,52 @@ type Stats struct {
 	TitleInstant string `json:"titleInstant"`
 }
 
+// SubtitlesOptions holds the user preferences applied when listing subtitles.
+type SubtitlesOptions struct {
+	// Video is the video file being watched, the subtitle most downloaded for it is listed first.
+	Video videohash.Video
+	// MaxResults limits the number of subtitles listed, zero means no limit.
+	MaxResults int
+	// Dialect is the preferred Spanish dialect, its subtitles are listed first.
+	Dialect string
+	// HideHearingImpaired hides the subtitles meant for the hearing impaired.
+	HideHearingImpaired bool
+	// PublicStats shares the title being watched in the public stats.
+	PublicStats bool
+}
+
+// SubtitleOptions holds the user preferences applied when serving a subtitle.
+type SubtitleOptions struct {
+	// Format is the subtitle file format, userconfig.FormatSRT or userconfig.FormatVTT.
+	Format string
+	// Charset is the charset handling, userconfig.CharsetUTF8 or userconfig.CharsetOriginal.
+	Charset string
+	// Video is the video file the subtitle was listed for, the download is recorded for it when valid.
+	Video videohash.Video
+	// UserRef is the reference of the install downloading the subtitle, each install counts once per subtitle.
+	UserRef string
+	// Season and Episode are the episode the subtitle was listed for, to pick its file from the multi-episode archives.
+	Season  int
+	Episode int
+	// TitleKey is the feedback.TitleKey of the title the subtitle was listed for.
+	TitleKey string
+	// Release is the feedback.NormalizeRelease of the video filename the subtitle was listed for.
+	Release string
+}
+
 type StremioService struct {
+	// VideoHashes remembers the subtitles downloaded per video file, nil disables the video hash ranking.
+	VideoHashes *videohash.Store
+	// Feedback keeps the download feedback per title and release, nil disables recording it.
+	Feedback *feedback.Store
+	// FeedbackRanking is the kill switch of the download feedback boost, the feedback is still recorded when off.
+	FeedbackRanking bool
+	// Titles resolves the name and year of the titles SubX knows no subtitles for by IMDB ID, to search them by title.
+	// Nil disables the title search fallback.
+	Titles *titlemeta.Resolver
+	// Providers are the subtitle sources searched and downloaded from.
+	Providers *provider.Aggregator
+
 	statsWebsocketChannel string
 	subx                  *subx.SubX
 	loki                  loki.Loki
@@ -56,9 +111,11 @@ type StremioService struct {
 	stats            Stats
 }
 
-// NewStremioService creates a new instance of StremioService with the provided SubX client.
-func NewStremioService(statsWebsocketChannel string, subxClient *subx.SubX, loki loki.Loki) *StremioService {
+// NewStremioService creates a new instance of StremioService with the provided subtitle providers, and the SubX client
+// the SubX API keys are validated and their quotas tracked with.
+func NewStremioService(statsWebsocketChannel string, subxClient *subx.SubX, providers *provider.Aggregator, loki loki.Loki) *StremioService {
 	svc := &StremioService{
+		Providers:             providers,
 		statsWebsocketChannel: statsWebsocketChannel,
 		subx:                  subxClient,
 		loki:                  loki,
@@ -114,145 +171,506 @@ func NewStremioService(statsWebsocketChannel string, subxClient *subx.SubX, loki
 }
 
 // GetSubtitles retrieves subtitles for a given title type, IMDb ID, season, and episode; filename is used to sort results by relevance
-// It uses caching to improve performance and reduce API calls.
-func (s *StremioService) GetSubtitles(ctx context.Context, subxAPIKey string, titleType string, imdbID string, season int, episode int, filename string) (*Subtitles, error) {
+// It uses caching to improve performance and reduce API calls, the options are applied to the cached results.
+func (s *StremioService) GetSubtitles(ctx context.Context, subxAPIKey string, titleType string, imdbID string, season int, episode int, filename string, options SubtitlesOptions) (*Subtitles, error) {
 
 	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("").Start(ctx, "internal.StremioService.GetSubtitles")
 	defer span.End()
 
-	span.SetAttributes(attribute.String("imdb.id", imdbID))
-	span.SetAttributes(attribute.Int("imdb.season", season))
-	span.SetAttributes(attribute.Int("imdb.episode", episode))
-
 	searchLabel := imdbID
 	if titleType == "movie" {
 		searchLabel = fmt.Sprintf("%s movie", imdbID)
-	} else if season > 0 && episode > 0 {
+	} else if episode > 0 {
 		searchLabel = fmt.Sprintf("%s S%02dE%02d", imdbID, season, episode)
 	}
 
-	cacheResult := "hit"
-	cacheKey := fmt.Sprintf("subx.subtitles : %s : %s : %d : %d", titleType, imdbID, season, episode)
-	cacheTTL := 24 * time.Hour
-	subxSubtitles, err := cache.Memoize[subx.Subtitles](cacheKey, cacheTTL, func() (*subx.Subtitles, error) {
+	rankedSubtitles, err := s.SearchSubtitles(ctx, subxAPIKey, titleType, imdbID, season, episode, filename, options)
+	if err != nil {
+		return nil, err
+	}
 
-		cacheResult = "miss"
+	ids := make([]string, len(rankedSubtitles))
+	scores := make([]int, len(rankedSubtitles))
+	for i, item := range rankedSubtitles {
+		ids[i] = item.ID
+		scores[i] = item.Score
+	}
+	common.Log.InfoContext(ctx, "Found subtitles", "title", searchLabel, "ids", ids, "scores", scores)
 
-		common.Log.InfoContext(ctx, "Searching SubX subtitles", "imdb_id", imdbID, "type", titleType, "season", season, "episode", episode)
+	if !options.PublicStats {
+		return &Subtitles{
+			IDs:  ids,
+			Lang: "spa",
+		}, nil
+	}
 
-		subtitles, err := s.subx.SearchSubtitles(ctx, subxAPIKey, subx.SearchParams{
-			IMDBID: imdbID,
-			Limit:  50,
+	go func() {
+		titleInstant := searchLabel
+		if len(rankedSubtitles) > 0 && rankedSubtitles[0].Title != "" {
+			titleInstant = rankedSubtitles[0].Title
+			if titleType == "series" && episode > 0 {
+				titleInstant = fmt.Sprintf("%s S%02dE%02d", titleInstant, season, episode)
+			}
+		}
+
+		err := s.BroadcastStats(func(data *Stats) error {
+			data.TitleInstant = titleInstant
+			return nil
 		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to subx.SubX.SearchSubtitles: %w", err)
+			common.Log.WarnContext(ctx, "Failed to internal.StremioService.BroadcastStats", "err", err)
 		}
+	}()
 
-		if titleType == "series" && season > 0 && episode > 0 {
-			filteredSubtitles := make([]*subx.Subtitle, 0, len(subtitles.Subtitles))
-			for _, subtitle := range subtitles.Subtitles {
-				if subtitle.Season == season && subtitle.Episode == episode {
-					filteredSubtitles = append(filteredSubtitles, subtitle)
-				}
+	return &Subtitles{
+		IDs:  ids,
+		Lang: "spa",
+	}, nil
+
+}
+
+// SearchSubtitles searches the subtitles of a title in every provider and ranks them, see GetSubtitles. The remote
+// provider searches are cached, the episode filtering and the ranking are applied to the cached results.
+func (s *StremioService) SearchSubtitles(ctx context.Context, subxAPIKey string, titleType string, imdbID string, season int, episode int, filename string, options SubtitlesOptions) ([]RankedSubtitle, error) {
+
+	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("").Start(ctx, "internal.StremioService.SearchSubtitles")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("imdb.id", imdbID))
+	span.SetAttributes(attribute.Int("imdb.season", season))
+	span.SetAttributes(attribute.Int("imdb.episode", episode))
+
+	subtitles, err := s.Providers.Search(ctx, func(c provider.Capabilities) bool { return c.IMDBSearch }, func(ctx context.Context, p provider.Provider) ([]*subx.Subtitle, error) {
+		return s.searchProvider(ctx, p, subxAPIKey, titleType, imdbID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("subtitles.count", len(subtitles)))
+
+	// The daily shows are matched by the air date in the filename, the catalogs episode numbers rarely line up with SubX
+	episodeQuery := subx.EpisodeQuery{Season: season, Episode: episode}
+	if info, ok := subx.ParseEpisodes(filename); ok {
+		episodeQuery.AirDate = info.AirDate
+	}
+	filterEpisodes := titleType == "series" && (episode > 0 || episodeQuery.AirDate != "")
+
+	rankedSubtitles := s.rankSubtitles(ctx, subtitles, rankingParams{
+		TitleKey:       feedback.TitleKey(imdbID, season, episode),
+		Filename:       filename,
+		FilterEpisodes: filterEpisodes,
+		EpisodeQuery:   episodeQuery,
+	}, options)
+
+	return rankedSubtitles, nil
+}
+
+// searchProvider searches the subtitles of a title in a provider, by IMDB ID and then by title name when it finds none
+// and supports it. The searches of the providers whose results are worth it are cached.
+func (s *StremioService) searchProvider(ctx context.Context, p provider.Provider, subxAPIKey string, titleType string, imdbID string) ([]*subx.Subtitle, error) {
+	span := trace.SpanFromContext(ctx)
+
+	search := func() (*subx.Subtitles, error) {
+		common.Log.InfoContext(ctx, "Searching subtitles", "provider", p.Namespace(), "imdb_id", imdbID, "type", titleType)
+
+		subtitles, err := p.Search(ctx, provider.Query{APIKey: subxAPIKey, Type: titleType, IMDBID: imdbID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to provider.Provider.Search: %w", err)
+		}
+
+		// Older uploads were indexed without the IMDB ID
+		if len(subtitles) == 0 && p.Capabilities().TitleSearch && s.Titles.Len() > 0 {
+			span.SetAttributes(attribute.Bool("provider.title-fallback", true))
+			subtitles, err = s.searchSubtitlesByTitle(ctx, p, subxAPIKey, titleType, imdbID)
+			if err != nil {
+				return nil, err
 			}
-			subtitles.TotalRecords = len(filteredSubtitles)
-			subtitles.Subtitles = filteredSubtitles
 		}
 
-		return subtitles, nil
+		return &subx.Subtitles{TotalRecords: len(subtitles), Subtitles: subtitles}, nil
+	}
+	var subtitles *subx.Subtitles
+	var err error
+	if !p.Capabilities().Cached {
+		subtitles, err = search()
+	} else {
+		cacheResult := "hit"
+		cacheKey := cache.NewKey(p.Namespace()+".subtitles", subxSubtitlesCacheVersion, titleType, imdbID)
+		cacheTTL := 24 * time.Hour
+		subtitles, err = cache.Memoize[subx.Subtitles](ctx, cacheKey, cacheTTL, func() (*subx.Subtitles, error) {
+			cacheResult = "miss"
+			return search()
+		})
+		span.SetAttributes(attribute.String("cache."+cacheKey.Namespace+".result", cacheResult))
+		common.CacheGetsTotalIncr(ctx, cacheKey.Namespace, cacheResult)
+	}
+	if errors.Is(err, titlemeta.ErrUnavailable) {
+		// The IMDB search found nothing and the title sources failed, the empty result is returned without caching it
+		// so the next request retries the title search
+		common.Log.WarnContext(ctx, "Failed to titlemeta.Resolver.Resolve", "err", err)
+		span.RecordError(err)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return subtitles.Subtitles, nil
+}
+
+// ErrUnparsableFilename is returned when a video filename has no title to search.
+var ErrUnparsableFilename = errors.New("filename has no title")
+
+// FilenameSubtitles holds the subtitles found for a video filename, and what was parsed from it.
+type FilenameSubtitles struct {
+	// Release is what the filename says about the title.
+	Release titlemeta.Release
+	// Subtitles are the ranked subtitles of the titles matching the release.
+	Subtitles []RankedSubtitle
+	// TitleKey is the feedback.TitleKey of the IMDB title the subtitles agree on, empty if they don't.
+	TitleKey string
+}
+
+/*
+SearchSubtitlesByFilename searches subtitles for a raw video filename, without any IMDB ID.
+
+The title, year and episode are parsed from the filename and the subtitles are searched by title, keeping the ones
+whose title and year match. They're ranked like GetSubtitles ranks them, and the title searches are cached alike.
+
+Parameters:
+  - ctx: The request context.
+  - subxAPIKey: The SubX API key to search with.
+  - filename: The video filename, such as "The.Matrix.1999.1080p.BluRay.x264.mkv".
+  - options: The user preferences applied to the results.
+
+Returns:
+  - The ranked subtitles, or ErrUnparsableFilename when the filename has no title.
+*/
+func (s *StremioService) SearchSubtitlesByFilename(ctx context.Context, subxAPIKey string, filename string, options SubtitlesOptions) (*FilenameSubtitles, error) {
+	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("").Start(ctx, "internal.StremioService.SearchSubtitlesByFilename")
+	defer span.End()
+
+	release := titlemeta.ParseRelease(filename)
+	if release.Name == "" {
+		return nil, fmt.Errorf("%w: %q", ErrUnparsableFilename, filename)
+	}
+	span.SetAttributes(attribute.String("release.name", release.Name))
+	span.SetAttributes(attribute.Int("release.year", release.Year))
+
+	subtitles, err := s.Providers.Search(ctx, func(c provider.Capabilities) bool { return c.TitleSearch }, func(ctx context.Context, p provider.Provider) ([]*subx.Subtitle, error) {
+		return s.searchProviderByTitle(ctx, p, subxAPIKey, release.Name)
 	})
-	span.SetAttributes(attribute.String("cache.subx.subtitles.result", cacheResult))
-	common.CacheGetsTotalIncr(ctx, "subx.subtitles", cacheResult)
 	if err != nil {
 		return nil, err
 	}
-	span.SetAttributes(attribute.Int("subx.total-records", subxSubtitles.TotalRecords))
-	span.SetAttributes(attribute.Int("subx.ids-count", len(subxSubtitles.Subtitles)))
+
+	// The year is part of the cross-check, not of the cached search
+	title := release.Title()
+	matchingSubtitles := make([]*subx.Subtitle, 0, len(subtitles))
+	imdbIDs := make(map[string]struct{})
+	for _, subtitle := range subtitles {
+		if title.Matches(subtitle.Title) {
+			matchingSubtitles = append(matchingSubtitles, subtitle)
+			imdbIDs[subtitle.IMDBID] = struct{}{}
+		}
+	}
+	span.SetAttributes(attribute.Int("provider.title-matches", len(matchingSubtitles)))
+
+	result := &FilenameSubtitles{Release: release}
+	if len(imdbIDs) == 1 {
+		for imdbID := range imdbIDs {
+			if common.ValidateIMDBTitleID(imdbID) == nil {
+				result.TitleKey = feedback.TitleKey(imdbID, release.Season, release.Episode)
+			}
+		}
+	}
+
+	result.Subtitles = s.rankSubtitles(ctx, matchingSubtitles, rankingParams{
+		TitleKey:       result.TitleKey,
+		Filename:       filename,
+		FilterEpisodes: release.Type == "series",
+		EpisodeQuery:   release.Query(),
+	}, options)
+
+	return result, nil
+}
+
+// searchProviderByTitle searches the subtitles of a title name in a provider, the searches of the providers whose
+// results are worth it are cached.
+func (s *StremioService) searchProviderByTitle(ctx context.Context, p provider.Provider, subxAPIKey string, name string) ([]*subx.Subtitle, error) {
+	span := trace.SpanFromContext(ctx)
+
+	search := func() (*subx.Subtitles, error) {
+		common.Log.InfoContext(ctx, "Searching subtitles by title", "provider", p.Namespace(), "title", name)
+
+		subtitles, err := p.Search(ctx, provider.Query{APIKey: subxAPIKey, Title: name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to provider.Provider.Search: %w", err)
+		}
+
+		return &subx.Subtitles{TotalRecords: len(subtitles), Subtitles: subtitles}, nil
+	}
+	if !p.Capabilities().Cached {
+		subtitles, err := search()
+		if err != nil {
+			return nil, err
+		}
+		return subtitles.Subtitles, nil
+	}
+
+	cacheResult := "hit"
+	cacheKey := cache.NewKey(p.Namespace()+".title-subtitles", subxSubtitlesCacheVersion, strings.ToLower(name))
+	cacheTTL := 24 * time.Hour
+	subtitles, err := cache.Memoize[subx.Subtitles](ctx, cacheKey, cacheTTL, func() (*subx.Subtitles, error) {
+		cacheResult = "miss"
+		return search()
+	})
+	span.SetAttributes(attribute.String("cache."+cacheKey.Namespace+".result", cacheResult))
+	common.CacheGetsTotalIncr(ctx, cacheKey.Namespace, cacheResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return subtitles.Subtitles, nil
+}
+
+// RankedSubtitle is a SubX subtitle with its score against the video filename.
+type RankedSubtitle struct {
+	*subx.Subtitle
+	Score int
+	// Proven reports whether the subtitle is the one most downloaded for the video file.
+	Proven bool
+}
+
+// rankingParams are what the subtitles are ranked against.
+type rankingParams struct {
+	// TitleKey is the feedback.TitleKey of the title, the download feedback is ignored when empty.
+	TitleKey string
+	// Filename is the video filename the subtitles are scored against.
+	Filename string
+	// FilterEpisodes drops the subtitles not covering EpisodeQuery.
+	FilterEpisodes bool
+	EpisodeQuery   subx.EpisodeQuery
+}
+
+// rankSubtitles filters and sorts the subtitles by relevance: the ones of the preferred providers first, then the one
+// proven for the video file, the preferred dialect ones, the ones made for the episode before the season packs, and the
+// best filename matches boosted by the download feedback. The options are applied to the result.
+func (s *StremioService) rankSubtitles(ctx context.Context, subtitles []*subx.Subtitle, params rankingParams, options SubtitlesOptions) []RankedSubtitle {
+	span := trace.SpanFromContext(ctx)
+
+	provenID, err := s.VideoHashes.Best(ctx, options.Video)
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		common.Log.WarnContext(ctx, "Failed to videohash.Store.Best", "err", err)
+		span.RecordError(err)
+	}
+	span.SetAttributes(attribute.String("videohash.proven-id", provenID))
+
+	var feedbackScores map[string]float64
+	if s.FeedbackRanking {
+		feedbackScores, err = s.Feedback.Scores(ctx, params.TitleKey, feedback.NormalizeRelease(params.Filename))
+		if err != nil {
+			common.Log.WarnContext(ctx, "Failed to feedback.Store.Scores", "err", err)
+			span.RecordError(err)
+		}
+	}
 
 	type ScoredSubtitle struct {
-		ID    string
-		Score int
+		Subtitle       *subx.Subtitle
+		Score          int
+		FeedbackBoost  int
+		DialectMatches bool
+		Proven         bool
+		Preferred      bool
+		SeasonPack     bool
 	}
 
-	subxScoredSubtitles := make([]ScoredSubtitle, 0, len(subxSubtitles.Subtitles))
-	for _, subxSubtitle := range subxSubtitles.Subtitles {
+	subxScoredSubtitles := make([]ScoredSubtitle, 0, len(subtitles))
+	for _, subxSubtitle := range subtitles {
+		if params.FilterEpisodes && !subxSubtitle.MatchesEpisode(params.EpisodeQuery) {
+			continue
+		}
+		if options.HideHearingImpaired && subxSubtitle.HearingImpaired() {
+			continue
+		}
 		subxScoredSubtitle := ScoredSubtitle{
-			ID:    subxSubtitle.ID,
-			Score: subxSubtitle.Score(filename),
+			Subtitle:       subxSubtitle,
+			Score:          subxSubtitle.Score(params.Filename),
+			FeedbackBoost:  feedbackBoost(feedbackScores[subxSubtitle.ID]),
+			DialectMatches: options.Dialect != "" && subxSubtitle.Dialect() == options.Dialect,
+			Proven:         provenID != "" && subxSubtitle.ID == provenID,
+			Preferred:      s.Providers.Preferred(subxSubtitle.ID),
+			SeasonPack:     subxSubtitle.SeasonPack,
 		}
 		subxScoredSubtitles = append(subxScoredSubtitles, subxScoredSubtitle)
 	}
-	sort.Slice(subxScoredSubtitles, func(i, j int) bool {
-		return subxScoredSubtitles[i].Score > subxScoredSubtitles[j].Score
+	less := func(a, b ScoredSubtitle, withFeedback bool) bool {
+		if a.Preferred != b.Preferred {
+			return a.Preferred
+		}
+		if a.Proven != b.Proven {
+			return a.Proven
+		}
+		if a.DialectMatches != b.DialectMatches {
+			return a.DialectMatches
+		}
+		// Season packs are candidates for every episode, but the subtitles made for the episode come first
+		if a.SeasonPack != b.SeasonPack {
+			return !a.SeasonPack
+		}
+		if withFeedback {
+			return a.Score+a.FeedbackBoost > b.Score+b.FeedbackBoost
+		}
+		return a.Score > b.Score
+	}
+
+	// The top result without the feedback is the first of the stable sort without it
+	var topWithoutFeedback string
+	if len(subxScoredSubtitles) > 0 {
+		top := 0
+		for i := range subxScoredSubtitles {
+			if less(subxScoredSubtitles[i], subxScoredSubtitles[top], false) {
+				top = i
+			}
+		}
+		topWithoutFeedback = subxScoredSubtitles[top].Subtitle.ID
+	}
+	sort.SliceStable(subxScoredSubtitles, func(i, j int) bool {
+		return less(subxScoredSubtitles[i], subxScoredSubtitles[j], true)
 	})
+	if len(feedbackScores) > 0 && len(subxScoredSubtitles) > 0 {
+		topChanged := subxScoredSubtitles[0].Subtitle.ID != topWithoutFeedback
+		span.SetAttributes(attribute.Bool("feedback.top-changed", topChanged))
+		common.RankingFeedbackTotalIncr(ctx, topChanged)
+	}
+	if options.MaxResults > 0 && len(subxScoredSubtitles) > options.MaxResults {
+		subxScoredSubtitles = subxScoredSubtitles[:options.MaxResults]
+	}
 
-	ids := make([]string, len(subxScoredSubtitles))
-	scores := make([]int, len(subxScoredSubtitles))
+	rankedSubtitles := make([]RankedSubtitle, len(subxScoredSubtitles))
 	for i, item := range subxScoredSubtitles {
-		ids[i] = item.ID
-		scores[i] = item.Score
+		rankedSubtitles[i] = RankedSubtitle{Subtitle: item.Subtitle, Score: item.Score, Proven: item.Proven}
 	}
-	common.Log.InfoContext(ctx, "Found subtitles", "title", searchLabel, "ids", ids, "scores", scores)
 
-	go func() {
-		titleInstant := searchLabel
-		if len(subxSubtitles.Subtitles) > 0 && subxSubtitles.Subtitles[0].Title != "" {
-			titleInstant = subxSubtitles.Subtitles[0].Title
-			if titleType == "series" && season > 0 && episode > 0 {
-				titleInstant = fmt.Sprintf("%s S%02dE%02d", titleInstant, season, episode)
-			}
-		}
+	return rankedSubtitles
+}
 
-		err := s.BroadcastStats(func(data *Stats) error {
-			data.TitleInstant = titleInstant
-			return nil
-		})
-		if err != nil {
-			common.Log.WarnContext(ctx, "Failed to internal.StremioService.BroadcastStats", "err", err)
+// searchSubtitlesByTitle searches the subtitles of a provider by the title name, keeping the ones whose title and year
+// match the IMDB title ones, and that aren't indexed for another IMDB title.
+func (s *StremioService) searchSubtitlesByTitle(ctx context.Context, p provider.Provider, subxAPIKey string, titleType string, imdbID string) ([]*subx.Subtitle, error) {
+	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("").Start(ctx, "internal.StremioService.searchSubtitlesByTitle")
+	defer span.End()
+
+	title, err := s.Titles.Resolve(ctx, titleType, imdbID)
+	if errors.Is(err, titlemeta.ErrNotFound) && !errors.Is(err, titlemeta.ErrUnavailable) {
+		// Unknown titles have nothing to search by, the failing sources are told apart by searchProvider
+		common.Log.WarnContext(ctx, "Failed to titlemeta.Resolver.Resolve", "err", err)
+		span.RecordError(err)
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to titlemeta.Resolver.Resolve: %w", err)
+	}
+	span.SetAttributes(attribute.String("title.name", title.Name))
+	span.SetAttributes(attribute.Int("title.year", title.Year))
+
+	common.Log.InfoContext(ctx, "Searching subtitles by title", "provider", p.Namespace(), "imdb_id", imdbID, "title", title.Name, "year", title.Year)
+
+	subtitles, err := p.Search(ctx, provider.Query{APIKey: subxAPIKey, Type: titleType, Title: title.Name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to provider.Provider.Search: %w", err)
+	}
+
+	matchingSubtitles := make([]*subx.Subtitle, 0, len(subtitles))
+	for _, subtitle := range subtitles {
+		if subtitle.IMDBID != "" && subtitle.IMDBID != imdbID {
+			continue
 		}
-	}()
+		if !title.Matches(subtitle.Title) {
+			continue
+		}
+		matchingSubtitles = append(matchingSubtitles, subtitle)
+	}
+	span.SetAttributes(attribute.Int("provider.title-matches", len(matchingSubtitles)))
 
-	return &Subtitles{
-		IDs:  ids,
-		Lang: "spa",
-	}, nil
+	return matchingSubtitles, nil
+}
 
+// feedbackBoost turns a download feedback score into a ranking score boost, growing slower than the downloads so a
+// handful of them can overtake a better filename match but a popular subtitle can't bury every other one.
+func feedbackBoost(score float64) int {
+	if score <= 0 {
+		return 0
+	}
+	return int(math.Round(2 * math.Log2(1+score)))
 }
 
-// GetSubtitle retrieves a specific subtitle by its SubX ID.
-func (s *StremioService) GetSubtitle(ctx context.Context, subxAPIKey string, subxID string) ([]byte, error) {
+// GetSubtitle retrieves a specific subtitle by its provider qualified ID, converted to the charset and format in options.
+func (s *StremioService) GetSubtitle(ctx context.Context, subxAPIKey string, subtitleID string, options SubtitleOptions) ([]byte, error) {
 
 	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("").Start(ctx, "internal.StremioService.GetSubtitle")
 	defer span.End()
 
 	common.SubtitlesDownloadsTotalIncr(ctx)
 
-	subtitle, err := s.subx.DownloadSubtitle(ctx, subxAPIKey, subxID)
+	subtitle, err := s.Providers.Download(ctx, subxAPIKey, subtitleID, options.Season, options.Episode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to subx.SubX.DownloadSubtitle: %w", err)
+		return nil, fmt.Errorf("failed to provider.Aggregator.Download: %w", err)
 	}
 
 	fileEncoding := chardet.Detect(subtitle.Data).Encoding
 	common.Log.WithGroup("file").InfoContext(ctx, "Got SRT", "name", subtitle.Name, "encoding", fileEncoding, "size", len(subtitle.Data))
 
-	var decoder *encoding.Decoder
-	switch fileEncoding {
-	case consts.Windows1252:
-		decoder = charmap.Windows1252.NewDecoder()
-	case consts.ISO88591:
-		decoder = charmap.ISO8859_1.NewDecoder()
-	}
+	// WebVTT is always UTF-8, the original charset is only kept for the SRT subtitles
+	data := subtitle.Data
+	if options.Charset != userconfig.CharsetOriginal || options.Format == userconfig.FormatVTT {
+		var decoder *encoding.Decoder
+		switch fileEncoding {
+		case consts.Windows1252:
+			decoder = charmap.Windows1252.NewDecoder()
+		case consts.ISO88591:
+			decoder = charmap.ISO8859_1.NewDecoder()
+		}
 
-	if decoder != nil {
-		tr := transform.NewReader(bytes.NewReader(subtitle.Data), decoder)
-		data, err := io.ReadAll(tr)
-		if err != nil {
-			return nil, fmt.Errorf("failed to io.ReadAll when transforming subtitle encoding: %w", err)
+		if decoder != nil {
+			tr := transform.NewReader(bytes.NewReader(subtitle.Data), decoder)
+			data, err = io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to io.ReadAll when transforming subtitle encoding: %w", err)
+			}
 		}
-		return data, nil
 	}
 
-	return subtitle.Data, nil
+	if options.Format == userconfig.FormatVTT {
+		data = common.SRTToWebVTT(data)
+	}
+
+	if err = s.VideoHashes.RecordDownload(ctx, options.Video, subtitleID, options.UserRef); err != nil {
+		common.Log.WarnContext(ctx, "Failed to videohash.Store.RecordDownload", "err", err)
+		span.RecordError(err)
+	}
+	if err = s.Feedback.Record(ctx, options.TitleKey, options.Release, subtitleID, options.UserRef); err != nil {
+		common.Log.WarnContext(ctx, "Failed to feedback.Store.Record", "err", err)
+		span.RecordError(err)
+	}
+
+	return data, nil
+}
+
+// ValidateAPIKey checks the SubX API key is accepted by SubX.
+func (s *StremioService) ValidateAPIKey(ctx context.Context, subxAPIKey string) error {
+
+	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("").Start(ctx, "internal.StremioService.ValidateAPIKey")
+	defer span.End()
+
+	if err := s.subx.ValidateAPIKey(ctx, subxAPIKey); err != nil {
+		return fmt.Errorf("failed to subx.SubX.ValidateAPIKey: %w", err)
+	}
+
+	return nil
+}
+
+// Quota returns the known SubX quota of the API key.
+func (s *StremioService) Quota(subxAPIKey string) (subx.Quota, bool) {
+	return s.subx.Quota(subxAPIKey)
 }
 
 // BroadcastStats updates and publishes statistical data to a websocket channel.
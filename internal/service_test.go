@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+	"github.com/ogero/stremio-subdivx/internal/common"
+	"github.com/ogero/stremio-subdivx/internal/feedback"
+	"github.com/ogero/stremio-subdivx/internal/provider"
+	"github.com/ogero/stremio-subdivx/internal/titlemeta"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/ogero/stremio-subdivx/internal/videohash"
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankSubtitles(t *testing.T) {
+	const (
+		titleKey  = "tt0133093"
+		filename  = "The.Matrix.1999.1080p.BluRay.x264-GROUP.mkv"
+		localID   = "local:0123456789abcdef"
+		videoHash = "8e245d9679d31e12"
+	)
+	video := videohash.Video{Hash: videoHash, Size: 1052791437}
+
+	newSubtitles := func() []*subx.Subtitle {
+		return []*subx.Subtitle{
+			subx.NewSubtitle(&subx.Subtitle{ID: "a", Title: "The Matrix (1999)", Description: "1080p BluRay x264 GROUP"}),
+			subx.NewSubtitle(&subx.Subtitle{ID: "b", Title: "The Matrix (1999)", Description: "720p WEB-DL"}),
+			subx.NewSubtitle(&subx.Subtitle{ID: "c", Title: "The Matrix (1999)", Description: "1080p BluRay latino"}),
+			subx.NewSubtitle(&subx.Subtitle{ID: "d", Title: "The Matrix (1999)", Description: "BluRay"}),
+		}
+	}
+
+	recordFeedback := func(subtitleID string, installs ...string) func(t *testing.T, s *StremioService) {
+		return func(t *testing.T, s *StremioService) {
+			for _, userRef := range installs {
+				require.NoError(t, s.Feedback.Record(context.Background(), titleKey, feedback.NormalizeRelease(filename), subtitleID, userRef))
+			}
+		}
+	}
+	topChanged := func(changed bool) *bool { return &changed }
+
+	tests := []struct {
+		name            string
+		setup           func(t *testing.T, s *StremioService)
+		local           bool
+		feedbackRanking bool
+		options         SubtitlesOptions
+		expected        []string
+		// topChanged is the feedback metric recorded, nil when none is
+		topChanged *bool
+	}{
+		{
+			name:     "filename match",
+			expected: []string{"a", "c", "d", "b"},
+		},
+		{
+			name:     "dialect",
+			options:  SubtitlesOptions{Dialect: subx.DialectLatam},
+			expected: []string{"c", "a", "d", "b"},
+		},
+		{
+			name: "proven for the video file",
+			setup: func(t *testing.T, s *StremioService) {
+				require.NoError(t, s.VideoHashes.RecordDownload(context.Background(), video, "b", "user-a"))
+			},
+			options:  SubtitlesOptions{Video: video, Dialect: subx.DialectLatam},
+			expected: []string{"b", "c", "a", "d"},
+		},
+		{
+			name:            "feedback overtakes a better filename match",
+			setup:           recordFeedback("d", "user-a", "user-b", "user-c"),
+			feedbackRanking: true,
+			expected:        []string{"d", "a", "c", "b"},
+			topChanged:      topChanged(true),
+		},
+		{
+			name:            "feedback counts each install once",
+			setup:           recordFeedback("d", "user-a", "user-a", "user-a"),
+			feedbackRanking: true,
+			expected:        []string{"a", "d", "c", "b"},
+			topChanged:      topChanged(false),
+		},
+		{
+			name:     "feedback disabled",
+			setup:    recordFeedback("d", "user-a", "user-b", "user-c"),
+			expected: []string{"a", "c", "d", "b"},
+		},
+		{
+			name:  "preferred provider before the proven subtitle",
+			local: true,
+			setup: func(t *testing.T, s *StremioService) {
+				require.NoError(t, s.VideoHashes.RecordDownload(context.Background(), video, "a", "user-a"))
+			},
+			options:  SubtitlesOptions{Video: video},
+			expected: []string{localID, "a", "c", "d", "b"},
+		},
+		{
+			name:     "max results",
+			options:  SubtitlesOptions{MaxResults: 2},
+			expected: []string{"a", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := cache.NewMemoryBackend(64 * 1024)
+			s := &StremioService{
+				VideoHashes:     videohash.NewStore(backend, time.Hour),
+				Feedback:        feedback.NewStore(backend, time.Hour),
+				FeedbackRanking: tt.feedbackRanking,
+				Providers:       provider.NewAggregator(),
+			}
+
+			subtitles := newSubtitles()
+			if tt.local {
+				local, err := provider.NewLocal(t.TempDir())
+				require.NoError(t, err)
+				require.NoError(t, s.Providers.Register(provider.NewSubX(subx.NewSubX()), 0))
+				require.NoError(t, s.Providers.Register(local, 0))
+				subtitles = append(subtitles, subx.NewSubtitle(&subx.Subtitle{ID: localID, Title: "The Matrix (1999)", Description: "720p"}))
+			}
+			if tt.setup != nil {
+				tt.setup(t, s)
+			}
+
+			var recorded *bool
+			common.RankingFeedbackTotalIncr = func(_ context.Context, changed bool) { recorded = &changed }
+			defer func() { common.RankingFeedbackTotalIncr = nil }()
+
+			ranked := s.rankSubtitles(context.Background(), subtitles, rankingParams{TitleKey: titleKey, Filename: filename}, tt.options)
+
+			ids := make([]string, len(ranked))
+			for i, subtitle := range ranked {
+				ids[i] = subtitle.ID
+			}
+			assert.Equal(t, tt.expected, ids)
+			assert.Equal(t, tt.topChanged, recorded)
+		})
+	}
+}
+
+func TestFeedbackBoost(t *testing.T) {
+	tests := []struct {
+		score    float64
+		expected int
+	}{
+		{score: -1, expected: 0},
+		{score: 0, expected: 0},
+		{score: 0.4, expected: 1},
+		{score: 1, expected: 2},
+		{score: 3, expected: 4},
+		{score: 100, expected: 13},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, feedbackBoost(tt.score), "score %v", tt.score)
+	}
+}
+
+// titleSearchProvider is a cached provider indexing its subtitles without the IMDB ID, found by the title only.
+type titleSearchProvider struct {
+	subtitles []*subx.Subtitle
+}
+
+func (p *titleSearchProvider) Namespace() string { return "titles" }
+
+func (p *titleSearchProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{IMDBSearch: true, TitleSearch: true, Cached: true}
+}
+
+func (p *titleSearchProvider) ValidateID(string) error { return nil }
+
+func (p *titleSearchProvider) Search(_ context.Context, query provider.Query) ([]*subx.Subtitle, error) {
+	if query.Title == "" {
+		return nil, nil
+	}
+	return p.subtitles, nil
+}
+
+func (p *titleSearchProvider) Download(context.Context, string, string, int, int) (*subx.SubtitleContents, error) {
+	return nil, errors.New("not implemented")
+}
+
+// failingSource is a title source that can't be reached until it's fixed.
+type failingSource struct {
+	fixed bool
+}
+
+func (s *failingSource) Lookup(_ context.Context, titleType string, imdbID string) (titlemeta.Title, error) {
+	if !s.fixed {
+		return titlemeta.Title{}, errors.New("connection refused")
+	}
+	return titlemeta.Title{IMDBID: imdbID, Type: titleType, Name: "The Matrix", Year: 1999}, nil
+}
+
+func TestSearchProviderTitleSourceErrors(t *testing.T) {
+	codec, err := cache.CodecByName("json")
+	require.NoError(t, err)
+	cache.InitCache(cache.NewMemoryBackend(64*1024), cache.Encoding{Codec: codec})
+	var cacheResults []string
+	common.CacheGetsTotalIncr = func(_ context.Context, _, result string) { cacheResults = append(cacheResults, result) }
+	defer func() { common.CacheGetsTotalIncr = nil }()
+
+	p := &titleSearchProvider{subtitles: []*subx.Subtitle{subx.NewSubtitle(&subx.Subtitle{ID: "100", Title: "The Matrix (1999)"})}}
+	source := &failingSource{}
+	s := &StremioService{Titles: titlemeta.NewResolver(source)}
+
+	// The failing sources get no subtitles instead of an error, and the empty result isn't cached
+	subtitles, err := s.searchProvider(context.Background(), p, "", "movie", "tt0133093")
+	require.NoError(t, err)
+	assert.Empty(t, subtitles)
+
+	source.fixed = true
+	subtitles, err = s.searchProvider(context.Background(), p, "", "movie", "tt0133093")
+	require.NoError(t, err)
+	require.Len(t, subtitles, 1)
+	assert.Equal(t, "100", subtitles[0].ID)
+	assert.Equal(t, []string{"miss", "miss"}, cacheResults)
+
+	// The unknown titles have no subtitles either
+	s.Titles = titlemeta.NewResolver()
+	subtitles, err = s.searchSubtitlesByTitle(context.Background(), p, "", "movie", "tt0000404")
+	require.NoError(t, err)
+	assert.Empty(t, subtitles)
+}
+
+func TestGetSubtitleVTTIsUTF8(t *testing.T) {
+	common.SubtitlesDownloadsTotalIncr = func(context.Context) {}
+	defer func() { common.SubtitlesDownloadsTotalIncr = nil }()
+
+	// "Año, señor, acción" in Windows-1252
+	srt := "1\r\n00:00:01,000 --> 00:00:02,000\r\nA\xf1o, se\xf1or, acci\xf3n. \xbfQu\xe9 pas\xf3? \xa1Vamos!\r\n"
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "tt0133093"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "tt0133093", "The.Matrix.1999.srt"), []byte(srt), 0o644))
+
+	local, err := provider.NewLocal(root)
+	require.NoError(t, err)
+	s := &StremioService{Providers: provider.NewAggregator()}
+	require.NoError(t, s.Providers.Register(local, 0))
+	subtitles, err := local.Search(context.Background(), provider.Query{IMDBID: "tt0133093"})
+	require.NoError(t, err)
+	require.Len(t, subtitles, 1)
+	id := "local:" + subtitles[0].ID
+
+	data, err := s.GetSubtitle(context.Background(), "", id, SubtitleOptions{Format: userconfig.FormatSRT, Charset: userconfig.CharsetOriginal})
+	require.NoError(t, err)
+	assert.Equal(t, srt, string(data), "the original charset keeps the SRT untouched")
+
+	data, err = s.GetSubtitle(context.Background(), "", id, SubtitleOptions{Format: userconfig.FormatVTT, Charset: userconfig.CharsetOriginal})
+	require.NoError(t, err)
+	assert.True(t, utf8.Valid(data))
+	assert.Contains(t, string(data), "Año, señor, acción")
+}
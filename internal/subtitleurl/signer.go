@@ -0,0 +1,132 @@
+package subtitleurl
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMalformed is returned when a token is not a token issued by Signer.Sign.
+	ErrMalformed = errors.New("malformed subtitle url token")
+	// ErrInvalidSignature is returned when a token was tampered with, signed with another key or for another subtitle or
+	// bound values.
+	ErrInvalidSignature = errors.New("invalid subtitle url token signature")
+	// ErrExpired is returned when a token is past its expiry.
+	ErrExpired = errors.New("subtitle url token expired")
+)
+
+const (
+	// tokenVersion prefixes the signed data so the token format can evolve.
+	tokenVersion = "v1"
+	// minKeySize is the minimum size of the HMAC keys.
+	minKeySize = 32
+	// minTTL keeps the tokens valid longer than the subtitles listings that carry them are cached for.
+	minTTL = 15 * time.Minute
+	// signatureSize is the size of the truncated HMAC-SHA256 signatures.
+	signatureSize = 16
+)
+
+// Claims holds what a token binds together.
+type Claims struct {
+	// SubtitleID is the subtitle the token grants access to.
+	SubtitleID string
+	// UserRef is the opaque reference the user config is stored under.
+	UserRef string
+	// ExpiresAt is the time after which the token is rejected.
+	ExpiresAt time.Time
+}
+
+// Signer issues and verifies HMAC signed tokens that grant access to a subtitle for a limited time, without carrying the user config.
+type Signer struct {
+	key []byte
+	ttl time.Duration
+	now func() time.Time
+}
+
+/*
+NewSigner creates a new instance of the Signer struct.
+
+Parameters:
+  - key: The HMAC-SHA256 key, at least 32 bytes long. A random key is generated when empty, so tokens don't survive restarts.
+  - ttl: How long the issued tokens are at least valid for, expiries are rounded up to a quarter of it so the URLs are stable enough to be cached.
+
+Returns:
+  - A pointer to the newly created Signer instance.
+*/
+func NewSigner(key []byte, ttl time.Duration) (*Signer, error) {
+	if len(key) == 0 {
+		key = make([]byte, minKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to rand.Read: %w", err)
+		}
+	}
+	if len(key) < minKeySize {
+		return nil, fmt.Errorf("subtitle url key must be at least %d bytes long, got %d", minKeySize, len(key))
+	}
+	if ttl < minTTL {
+		return nil, fmt.Errorf("subtitle url ttl must be at least %s, got %s", minTTL, ttl)
+	}
+
+	return &Signer{
+		key: key,
+		ttl: ttl,
+		now: time.Now,
+	}, nil
+}
+
+// Sign returns a URL safe token granting access to subtitleID for the user config stored under userRef. The bound
+// values, such as the ones the URL carries besides the token, are covered by the signature without being part of it.
+// The expiry is rounded up so the tokens issued within the same quarter of the ttl are identical.
+func (s *Signer) Sign(subtitleID string, userRef string, bound ...string) string {
+	bucket := s.ttl / 4
+	expiresAt := s.now().Add(s.ttl).Truncate(bucket).Add(bucket)
+	expiry := strconv.FormatInt(expiresAt.Unix(), 36)
+
+	return userRef + "." + expiry + "." + s.signature(subtitleID, userRef, expiry, bound)
+}
+
+// Verify checks the token was issued by Sign for subtitleID and the bound values and is not expired, and returns its claims.
+func (s *Signer) Verify(token string, subtitleID string, bound ...string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return nil, ErrMalformed
+	}
+	userRef, expiry, signature := parts[0], parts[1], parts[2]
+
+	expiresAtUnix, err := strconv.ParseInt(expiry, 36, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(s.signature(subtitleID, userRef, expiry, bound))) {
+		return nil, ErrInvalidSignature
+	}
+
+	expiresAt := time.Unix(expiresAtUnix, 0)
+	if !s.now().Before(expiresAt) {
+		return nil, ErrExpired
+	}
+
+	return &Claims{
+		SubtitleID: subtitleID,
+		UserRef:    userRef,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+func (s *Signer) signature(subtitleID string, userRef string, expiry string, bound []string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(tokenVersion + "\x00" + subtitleID + "\x00" + userRef + "\x00" + expiry))
+	// Length prefixed so the values can't be shifted across each other
+	for _, value := range bound {
+		mac.Write([]byte("\x00" + strconv.Itoa(len(value)) + ":" + value))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureSize])
+}
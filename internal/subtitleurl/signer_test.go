@@ -0,0 +1,79 @@
+package subtitleurl
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSigner(t *testing.T, key byte, now time.Time) *Signer {
+	signer, err := NewSigner(bytes.Repeat([]byte{key}, 32), time.Hour)
+	require.NoError(t, err)
+	signer.now = func() time.Time { return now }
+	return signer
+}
+
+func TestSignerRoundTrip(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	signer := newTestSigner(t, 1, now)
+
+	token := signer.Sign("subtitle-id", "user-ref")
+	assert.NotContains(t, token, "/")
+
+	claims, err := signer.Verify(token, "subtitle-id")
+	require.NoError(t, err)
+	assert.Equal(t, "subtitle-id", claims.SubtitleID)
+	assert.Equal(t, "user-ref", claims.UserRef)
+	assert.True(t, claims.ExpiresAt.After(now.Add(time.Hour)))
+	assert.False(t, claims.ExpiresAt.After(now.Add(time.Hour+15*time.Minute)))
+
+	signer.now = func() time.Time { return now.Add(10 * time.Minute) }
+	assert.Equal(t, token, signer.Sign("subtitle-id", "user-ref"), "tokens must be stable within a quarter of the ttl")
+}
+
+func TestSignerRejectsInvalidTokens(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	signer := newTestSigner(t, 1, now)
+	token := signer.Sign("subtitle-id", "user-ref")
+
+	_, err := signer.Verify(token, "other-subtitle-id")
+	assert.True(t, errors.Is(err, ErrInvalidSignature), "expected ErrInvalidSignature, got %v", err)
+
+	_, err = newTestSigner(t, 2, now).Verify(token, "subtitle-id")
+	assert.True(t, errors.Is(err, ErrInvalidSignature), "expected ErrInvalidSignature, got %v", err)
+
+	_, err = signer.Verify("other-ref"+token[strings.Index(token, "."):], "subtitle-id")
+	assert.True(t, errors.Is(err, ErrInvalidSignature), "expected ErrInvalidSignature, got %v", err)
+
+	bound := signer.Sign("subtitle-id", "user-ref", "tt0111161", "the shawshank redemption 1080p")
+	_, err = signer.Verify(bound, "subtitle-id", "tt0111161", "the shawshank redemption 1080p")
+	assert.NoError(t, err)
+	_, err = signer.Verify(bound, "subtitle-id", "tt0111161", "another release")
+	assert.True(t, errors.Is(err, ErrInvalidSignature), "expected ErrInvalidSignature, got %v", err)
+	_, err = signer.Verify(bound, "subtitle-id")
+	assert.True(t, errors.Is(err, ErrInvalidSignature), "expected ErrInvalidSignature, got %v", err)
+
+	_, err = signer.Verify("garbage", "subtitle-id")
+	assert.True(t, errors.Is(err, ErrMalformed), "expected ErrMalformed, got %v", err)
+
+	signer.now = func() time.Time { return now.Add(2 * time.Hour) }
+	_, err = signer.Verify(token, "subtitle-id")
+	assert.True(t, errors.Is(err, ErrExpired), "expected ErrExpired, got %v", err)
+}
+
+func TestNewSignerValidatesSettings(t *testing.T) {
+	_, err := NewSigner([]byte("short"), time.Hour)
+	assert.Error(t, err)
+
+	_, err = NewSigner(nil, time.Minute)
+	assert.Error(t, err)
+
+	signer, err := NewSigner(nil, time.Hour)
+	require.NoError(t, err)
+	assert.Len(t, signer.key, minKeySize)
+}
@@ -0,0 +1,95 @@
+package titlemeta
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// minSimilarity is the lowest similarity between a title and a release name to consider them the same title.
+const minSimilarity = 0.75
+
+// releaseSuffixRegexp matches where the title may end in a release name, at a year, an episode marker or a quality tag.
+var releaseSuffixRegexp = regexp.MustCompile(`(?i)[\s._(\[-]*(?:\b(?:19|20)\d{2}\b|\bs\d{1,2}(?:e\d{1,3})?\b|\b\d{1,2}x\d{2,3}\b|\b(?:temporada|season|temp)\b|\b(?:480|576|720|1080|2160)[pi]\b|\b(?:web-?dl|webrip|bluray|brrip|bdrip|dvdrip|hdtv|x26[45]|h\.?26[45])\b)`)
+
+// Matches cross-checks a release name, such as a SubX subtitle title "Dark (2017) S01E01", against the title: their
+// names must be similar, and the release year, when it has one, must fall within the title years.
+func (t Title) Matches(release string) bool {
+	if Similarity(t.Name, release) < minSimilarity {
+		return false
+	}
+
+	year := releaseYear(release)
+	if year == 0 || t.Year == 0 {
+		return true
+	}
+	// One year of tolerance, the release dates differ across countries
+	if year < t.Year-1 {
+		return false
+	}
+	if t.Type == "series" {
+		return t.EndYear == 0 || year <= t.EndYear+1
+	}
+	return year <= t.Year+1
+}
+
+// Similarity returns how similar the title name of two release names is, from 0 to 1, as the Sørensen–Dice
+// coefficient of their words. The years, episode markers and anything after them are ignored.
+func Similarity(a string, b string) float64 {
+	wordsA, wordsB := titleWords(a), titleWords(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+
+	common := 0
+	for word := range wordsA {
+		if _, ok := wordsB[word]; ok {
+			common++
+		}
+	}
+
+	return 2 * float64(common) / float64(len(wordsA)+len(wordsB))
+}
+
+// titleWords returns the distinct words of the title in a release name, lowercased and without diacritics.
+func titleWords(release string) map[string]struct{} {
+	release = releaseTitle(release)
+
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	release, _, _ = transform.String(t, release)
+
+	words := make(map[string]struct{})
+	for _, word := range strings.FieldsFunc(strings.ToLower(release), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[word] = struct{}{}
+	}
+	return words
+}
+
+// releaseTitle cuts a release name at the first year or episode marker following the title, titles such as "1917" or
+// "2012" start with what looks like a year.
+func releaseTitle(release string) string {
+	for _, loc := range releaseSuffixRegexp.FindAllStringIndex(release, -1) {
+		if loc[0] > 0 {
+			return release[:loc[0]]
+		}
+	}
+	return release
+}
+
+// releaseYear returns the last year following the title in a release name, zero if it has none.
+func releaseYear(release string) int {
+	year := 0
+	for _, loc := range yearRegexp.FindAllStringIndex(release, -1) {
+		if loc[0] > 0 {
+			year, _ = strconv.Atoi(release[loc[0]:loc[1]])
+		}
+	}
+	return year
+}
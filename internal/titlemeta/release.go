@@ -0,0 +1,82 @@
+package titlemeta
+
+import (
+	"path"
+	"strings"
+
+	"github.com/ogero/stremio-subdivx/pkg/subx"
+)
+
+// videoExtensions are stripped from the filenames before parsing them.
+var videoExtensions = []string{".mkv", ".mp4", ".avi", ".m4v", ".mov", ".wmv", ".ts", ".webm"}
+
+// Release is what a video filename says about the title it holds.
+type Release struct {
+	// Type is "series" when the filename names an episode, "movie" otherwise.
+	Type string `json:"type"`
+	// Name is the title name, with the separators turned into spaces.
+	Name string `json:"name"`
+	// Year is the release year, zero if the filename has none.
+	Year int `json:"year,omitempty"`
+	// Season and Episode are the episode the filename names, zero for the movies.
+	Season  int `json:"season,omitempty"`
+	Episode int `json:"episode,omitempty"`
+	// AirDate is the air date of the daily shows episodes, as YYYY-MM-DD.
+	AirDate string `json:"airDate,omitempty"`
+}
+
+// Title returns the title the release is cross-checked against.
+func (r Release) Title() Title {
+	return Title{Type: r.Type, Name: r.Name, Year: r.Year}
+}
+
+// Query returns the episode the release names.
+func (r Release) Query() subx.EpisodeQuery {
+	return subx.EpisodeQuery{Season: r.Season, Episode: r.Episode, AirDate: r.AirDate}
+}
+
+/*
+ParseRelease parses the title, year and episode of a video filename, such as "The.Matrix.1999.1080p.BluRay.x264.mkv" or
+"/media/Dark/Dark.S01E02.720p.WEB.mkv".
+
+Parameters:
+  - filename: The video filename, the directories and the video extension are ignored.
+
+Returns:
+  - The parsed release, its Name is empty when the filename has no title.
+*/
+func ParseRelease(filename string) Release {
+	filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	ext := strings.ToLower(path.Ext(filename))
+	for _, videoExtension := range videoExtensions {
+		if ext == videoExtension {
+			filename = strings.TrimSuffix(filename, path.Ext(filename))
+			break
+		}
+	}
+	// Underscores are word characters to the regexps, they wouldn't find the markers between them
+	filename = strings.ReplaceAll(filename, "_", " ")
+
+	release := Release{
+		Type: "movie",
+		Name: strings.Join(strings.FieldsFunc(releaseTitle(filename), func(r rune) bool {
+			return r == '.' || r == ' ' || r == '(' || r == ')' || r == '[' || r == ']'
+		}), " "),
+		Year: releaseYear(filename),
+	}
+	release.Name = strings.Trim(release.Name, " -")
+
+	if info, ok := subx.ParseEpisodes(filename); ok && !info.SeasonPack {
+		release.Type = "series"
+		release.Season, release.AirDate = info.Season, info.AirDate
+		if len(info.Episodes) > 0 {
+			release.Episode = info.Episodes[0]
+		}
+		// The air date year isn't the release year of the series
+		if info.AirDate != "" {
+			release.Year = 0
+		}
+	}
+
+	return release
+}
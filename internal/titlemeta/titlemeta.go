@@ -0,0 +1,220 @@
+package titlemeta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/pkg/stremio"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrNotFound is returned when no source knows the title.
+var ErrNotFound = errors.New("title not found")
+
+// ErrUnavailable is joined to ErrNotFound when a source failed, so the title may exist.
+var ErrUnavailable = errors.New("title source unavailable")
+
+var (
+	imdbIDRegexp = regexp.MustCompile(`^tt\d+$`)
+	yearRegexp   = regexp.MustCompile(`\b(?:19|20)\d{2}\b`)
+)
+
+// Title is the name and release years of an IMDB title.
+type Title struct {
+	IMDBID string `json:"imdb"`
+	// Type is the Stremio type of the title, "movie" or "series".
+	Type string `json:"type"`
+	Name string `json:"name"`
+	// Year is the release year, or the first air year of the series, zero if unknown.
+	Year int `json:"year,omitempty"`
+	// EndYear is the last air year of the ended series, zero if unknown or still airing.
+	EndYear int `json:"endYear,omitempty"`
+}
+
+// Source looks up the name and year of the IMDB titles, returning ErrNotFound for the unknown ones.
+type Source interface {
+	Lookup(ctx context.Context, titleType string, imdbID string) (Title, error)
+}
+
+// Resolver looks up the titles in a chain of sources, the first one knowing a title wins.
+type Resolver struct {
+	sources []Source
+}
+
+// NewResolver creates a Resolver querying the sources in order.
+func NewResolver(sources ...Source) *Resolver {
+	return &Resolver{sources: sources}
+}
+
+// Len returns the number of sources of the resolver.
+func (r *Resolver) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.sources)
+}
+
+// Resolve returns the title of the IMDB ID from the first source knowing it. The errors of the failing sources are
+// wrapped in ErrUnavailable and joined to ErrNotFound when no source knows it.
+func (r *Resolver) Resolve(ctx context.Context, titleType string, imdbID string) (Title, error) {
+	if r == nil {
+		return Title{}, ErrNotFound
+	}
+
+	var errs []error
+	for _, source := range r.sources {
+		title, err := source.Lookup(ctx, titleType, imdbID)
+		if err == nil {
+			return title, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			errs = append(errs, fmt.Errorf("%w: %w", ErrUnavailable, err))
+		}
+	}
+
+	return Title{}, errors.Join(append([]error{fmt.Errorf("%w: %q", ErrNotFound, imdbID)}, errs...)...)
+}
+
+// FileSource looks up the titles in a dataset loaded from a JSON file, an array of Title.
+type FileSource struct {
+	titles map[string]Title
+}
+
+// LoadFileSource loads the JSON dataset file at path.
+func LoadFileSource(path string) (*FileSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to os.ReadFile: %w", err)
+	}
+
+	return NewFileSource(data)
+}
+
+// NewFileSource creates a FileSource from a JSON dataset, an array of Title.
+func NewFileSource(data []byte) (*FileSource, error) {
+	var titles []Title
+	if err := json.Unmarshal(data, &titles); err != nil {
+		return nil, fmt.Errorf("failed to json.Unmarshal: %w", err)
+	}
+
+	s := &FileSource{titles: make(map[string]Title, len(titles))}
+	for i, title := range titles {
+		if !imdbIDRegexp.MatchString(title.IMDBID) {
+			return nil, fmt.Errorf("title %d has an invalid imdb id %q", i, title.IMDBID)
+		}
+		if strings.TrimSpace(title.Name) == "" {
+			return nil, fmt.Errorf("title %d has no name", i)
+		}
+		s.titles[title.IMDBID] = title
+	}
+
+	return s, nil
+}
+
+// Lookup returns the title of the IMDB ID, the type is ignored as the IDs are unique across types.
+func (s *FileSource) Lookup(_ context.Context, _ string, imdbID string) (Title, error) {
+	title, ok := s.titles[imdbID]
+	if !ok {
+		return Title{}, fmt.Errorf("%w: %q", ErrNotFound, imdbID)
+	}
+	return title, nil
+}
+
+// Len returns the number of titles in the dataset.
+func (s *FileSource) Len() int {
+	return len(s.titles)
+}
+
+// CinemetaSource looks up the titles in a Cinemeta compatible addon, any addon serving the meta resource of the IMDB IDs.
+type CinemetaSource struct {
+	HttpClient *http.Client
+	BaseURL    string
+}
+
+/*
+NewCinemetaSource creates a new instance of the CinemetaSource struct.
+
+Parameters:
+  - baseURL: The URL the addon manifest.json is served under, such as "https://v3-cinemeta.strem.io".
+
+Returns:
+  - A pointer to the newly created CinemetaSource instance.
+*/
+func NewCinemetaSource(baseURL string) *CinemetaSource {
+	return &CinemetaSource{
+		HttpClient: &http.Client{
+			Timeout: time.Second * 5,
+		},
+		BaseURL: baseURL,
+	}
+}
+
+// Lookup fetches the meta of the IMDB ID and parses its release years.
+func (s *CinemetaSource) Lookup(ctx context.Context, titleType string, imdbID string) (Title, error) {
+	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("").Start(ctx, "titlemeta.CinemetaSource.Lookup")
+	defer span.End()
+
+	if titleType != "movie" && titleType != "series" {
+		return Title{}, fmt.Errorf("%w: unsupported type %q", ErrNotFound, titleType)
+	}
+
+	endpoint := fmt.Sprintf("%s/meta/%s/%s.json", strings.TrimRight(s.BaseURL, "/"), url.PathEscape(titleType), url.PathEscape(imdbID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return Title{}, fmt.Errorf("failed to http.NewRequestWithContext: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := s.HttpClient.Do(req)
+	if err != nil {
+		return Title{}, fmt.Errorf("failed to http.Client.Do: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return Title{}, fmt.Errorf("%w: %q", ErrNotFound, imdbID)
+	}
+	if res.StatusCode != http.StatusOK {
+		return Title{}, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	var meta stremio.MetaResponse
+	if err = json.NewDecoder(res.Body).Decode(&meta); err != nil {
+		return Title{}, fmt.Errorf("failed to json.NewDecoder.Decode: %w", err)
+	}
+	if strings.TrimSpace(meta.Meta.Name) == "" {
+		return Title{}, fmt.Errorf("%w: %q", ErrNotFound, imdbID)
+	}
+
+	title := Title{IMDBID: imdbID, Type: titleType, Name: meta.Meta.Name}
+	title.Year, title.EndYear = parseReleaseInfo(meta.Meta.ReleaseInfo)
+	if title.Year == 0 {
+		title.Year, _ = parseReleaseInfo(meta.Meta.Released)
+	}
+
+	return title, nil
+}
+
+// parseReleaseInfo parses the Cinemeta release info, such as "1999", "2008–2013" or "2017–".
+func parseReleaseInfo(releaseInfo string) (int, int) {
+	years := yearRegexp.FindAllString(releaseInfo, 2)
+	if len(years) == 0 {
+		return 0, 0
+	}
+
+	year, _ := strconv.Atoi(years[0])
+	if len(years) == 1 {
+		return year, 0
+	}
+	endYear, _ := strconv.Atoi(years[1])
+	return year, endYear
+}
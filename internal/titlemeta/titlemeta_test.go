@@ -0,0 +1,109 @@
+package titlemeta_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ogero/stremio-subdivx/internal/titlemeta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolverChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/meta/series/tt5753856.json":
+			_, _ = w.Write([]byte(`{"meta": {"id": "tt5753856", "type": "series", "name": "Dark", "releaseInfo": "2017–2020"}}`))
+		case "/meta/movie/tt0000500.json":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	fileSource, err := titlemeta.NewFileSource([]byte(`[{"imdb": "tt0133093", "type": "movie", "name": "The Matrix", "year": 1999}]`))
+	require.NoError(t, err)
+	resolver := titlemeta.NewResolver(fileSource, titlemeta.NewCinemetaSource(server.URL))
+
+	title, err := resolver.Resolve(context.Background(), "movie", "tt0133093")
+	require.NoError(t, err)
+	assert.Equal(t, titlemeta.Title{IMDBID: "tt0133093", Type: "movie", Name: "The Matrix", Year: 1999}, title)
+
+	title, err = resolver.Resolve(context.Background(), "series", "tt5753856")
+	require.NoError(t, err)
+	assert.Equal(t, titlemeta.Title{IMDBID: "tt5753856", Type: "series", Name: "Dark", Year: 2017, EndYear: 2020}, title)
+
+	_, err = resolver.Resolve(context.Background(), "movie", "tt0000404")
+	assert.ErrorIs(t, err, titlemeta.ErrNotFound)
+	assert.NotErrorIs(t, err, titlemeta.ErrUnavailable)
+
+	_, err = resolver.Resolve(context.Background(), "movie", "tt0000500")
+	assert.ErrorIs(t, err, titlemeta.ErrNotFound)
+	assert.ErrorIs(t, err, titlemeta.ErrUnavailable)
+	assert.ErrorContains(t, err, "unexpected status code 500")
+
+	_, err = titlemeta.NewResolver().Resolve(context.Background(), "movie", "tt0133093")
+	assert.ErrorIs(t, err, titlemeta.ErrNotFound)
+}
+
+func TestNewFileSourceRejectsInvalidTitles(t *testing.T) {
+	_, err := titlemeta.NewFileSource([]byte(`[{"imdb": "0133093", "name": "The Matrix"}]`))
+	assert.Error(t, err)
+	_, err = titlemeta.NewFileSource([]byte(`[{"imdb": "tt0133093"}]`))
+	assert.Error(t, err)
+}
+
+func TestTitleMatches(t *testing.T) {
+	matrix := titlemeta.Title{Type: "movie", Name: "The Matrix", Year: 1999}
+	dark := titlemeta.Title{Type: "series", Name: "Dark", Year: 2017, EndYear: 2020}
+	amelie := titlemeta.Title{Type: "movie", Name: "Amélie", Year: 2001}
+	war := titlemeta.Title{Type: "movie", Name: "1917", Year: 2019}
+
+	tests := []struct {
+		title    titlemeta.Title
+		release  string
+		expected bool
+	}{
+		{title: matrix, release: "The Matrix (1999)", expected: true},
+		{title: matrix, release: "The.Matrix.1999.1080p.BluRay.x264", expected: true},
+		{title: matrix, release: "The Matrix", expected: true},
+		{title: matrix, release: "The Matrix Reloaded (2003)", expected: false},
+		{title: matrix, release: "The Matrix (2021)", expected: false},
+		{title: dark, release: "Dark (2017) S01E01", expected: true},
+		{title: dark, release: "Dark S03E08 (2020)", expected: true},
+		{title: dark, release: "Dark Waters (2019)", expected: false},
+		{title: dark, release: "Dark (1979)", expected: false},
+		{title: amelie, release: "Amelie (2001)", expected: true},
+		{title: war, release: "1917 (2019)", expected: true},
+		{title: war, release: "1917 (1970)", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.release, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.title.Matches(tt.release))
+		})
+	}
+}
+
+func TestParseRelease(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected titlemeta.Release
+	}{
+		{filename: "The.Matrix.1999.1080p.BluRay.x264.mkv", expected: titlemeta.Release{Type: "movie", Name: "The Matrix", Year: 1999}},
+		{filename: "/media/movies/Amélie (2001) [720p].mp4", expected: titlemeta.Release{Type: "movie", Name: "Amélie", Year: 2001}},
+		{filename: `C:\Videos\1917.2019.2160p.WEB-DL.mkv`, expected: titlemeta.Release{Type: "movie", Name: "1917", Year: 2019}},
+		{filename: "Some_Movie_720p_WEBRip.avi", expected: titlemeta.Release{Type: "movie", Name: "Some Movie"}},
+		{filename: "Dark.S01E02.720p.WEB.mkv", expected: titlemeta.Release{Type: "series", Name: "Dark", Season: 1, Episode: 2}},
+		{filename: "Doctor.Who.2005.S00E01.mkv", expected: titlemeta.Release{Type: "series", Name: "Doctor Who", Year: 2005, Episode: 1}},
+		{filename: "Lost - 2x05 - ...And Found.avi", expected: titlemeta.Release{Type: "series", Name: "Lost", Season: 2, Episode: 5}},
+		{filename: "The.Daily.Show.2024.05.13.720p.WEB.h264-EDITH.mkv", expected: titlemeta.Release{Type: "series", Name: "The Daily Show", AirDate: "2024-05-13"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			assert.Equal(t, tt.expected, titlemeta.ParseRelease(tt.filename))
+		})
+	}
+}
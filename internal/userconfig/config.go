@@ -0,0 +1,162 @@
+package userconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CurrentVersion is the version of the Config schema issued by the server.
+const CurrentVersion = 1
+
+// MaxResults is the maximum number of subtitles a Config can ask for.
+const MaxResults = 50
+
+// Dialects supported by Config.Dialect.
+const (
+	DialectAny   = ""
+	DialectLatam = "latam"
+	DialectSpain = "spain"
+)
+
+// Formats supported by Config.Format.
+const (
+	FormatSRT = "srt"
+	FormatVTT = "vtt"
+)
+
+// Charsets supported by Config.Charset.
+const (
+	// CharsetUTF8 converts the subtitles detected as Windows-1252 or ISO-8859-1 to UTF-8.
+	CharsetUTF8 = "utf-8"
+	// CharsetOriginal serves the subtitles as they were uploaded, the WebVTT ones are converted anyway as it's UTF-8 only.
+	CharsetOriginal = "original"
+)
+
+// ErrInvalid is returned when a Config holds unsupported values.
+var ErrInvalid = errors.New("invalid user config")
+
+// Config is the per install configuration carried by the userConfig path segment.
+type Config struct {
+	// Version is the schema version, configs issued before versioning was introduced have version 0.
+	Version int `json:"version"`
+	// APIKey is the SubX API key.
+	APIKey string `json:"apiKey"`
+	// MaxResults limits the number of subtitles listed, zero means MaxResults.
+	MaxResults int `json:"maxResults,omitempty"`
+	// Dialect is the preferred Spanish dialect, its subtitles are listed first.
+	Dialect string `json:"dialect,omitempty"`
+	// HideHearingImpaired hides the subtitles meant for the hearing impaired.
+	HideHearingImpaired bool `json:"hideHearingImpaired,omitempty"`
+	// Format is the subtitles file format, FormatSRT if empty.
+	Format string `json:"format,omitempty"`
+	// Charset is the subtitles charset handling, CharsetUTF8 if empty.
+	Charset string `json:"charset,omitempty"`
+	// PublicStats reports whether the titles being watched are shared in the public "now watching" stats, true if nil.
+	PublicStats *bool `json:"publicStats,omitempty"`
+}
+
+// Parse opens a token issued by Sealer.Seal and returns its validated Config, migrated to CurrentVersion.
+// An empty token, as used by the installs without a userConfig path segment, yields the default Config.
+func Parse(sealer *Sealer, token string) (*Config, error) {
+	if token == "" {
+		config := new(Config)
+		config.Normalize()
+		return config, nil
+	}
+
+	plaintext, err := sealer.Open(token)
+	if err != nil {
+		return nil, err
+	}
+
+	config := new(Config)
+	if err = json.Unmarshal(plaintext, config); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	config.Normalize()
+	if err = config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Seal normalizes and validates the Config, and returns it as a token to use as the userConfig path segment.
+func Seal(sealer *Sealer, config *Config) (string, error) {
+	config.Normalize()
+	if err := config.Validate(); err != nil {
+		return "", err
+	}
+
+	plaintext, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to json.Marshal: %w", err)
+	}
+
+	return sealer.Seal(plaintext)
+}
+
+// Normalize migrates the Config to CurrentVersion and fills in the defaults.
+func (c *Config) Normalize() {
+	if c.Version == 0 {
+		c.Version = CurrentVersion
+	}
+
+	c.APIKey = strings.TrimSpace(c.APIKey)
+	c.Dialect = strings.ToLower(strings.TrimSpace(c.Dialect))
+	c.Format = strings.ToLower(strings.TrimSpace(c.Format))
+	c.Charset = strings.ToLower(strings.TrimSpace(c.Charset))
+
+	if c.MaxResults == 0 {
+		c.MaxResults = MaxResults
+	}
+	if c.Format == "" {
+		c.Format = FormatSRT
+	}
+	if c.Charset == "" {
+		c.Charset = CharsetUTF8
+	}
+}
+
+// Validate checks the Config holds supported values.
+func (c *Config) Validate() error {
+	if c.Version > CurrentVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalid, c.Version)
+	}
+	if c.MaxResults < 1 || c.MaxResults > MaxResults {
+		return fmt.Errorf("%w: max results must be between 1 and %d", ErrInvalid, MaxResults)
+	}
+	if c.Dialect != DialectAny && c.Dialect != DialectLatam && c.Dialect != DialectSpain {
+		return fmt.Errorf("%w: unsupported dialect %q", ErrInvalid, c.Dialect)
+	}
+	if c.Format != FormatSRT && c.Format != FormatVTT {
+		return fmt.Errorf("%w: unsupported format %q", ErrInvalid, c.Format)
+	}
+	if c.Charset != CharsetUTF8 && c.Charset != CharsetOriginal {
+		return fmt.Errorf("%w: unsupported charset %q", ErrInvalid, c.Charset)
+	}
+
+	return nil
+}
+
+// SharesPublicStats reports whether the titles being watched are shared in the public "now watching" stats.
+func (c *Config) SharesPublicStats() bool {
+	return c.PublicStats == nil || *c.PublicStats
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying config.
+func NewContext(ctx context.Context, config *Config) context.Context {
+	return context.WithValue(ctx, contextKey{}, config)
+}
+
+// FromContext returns the Config carried by ctx, or nil if there is none.
+func FromContext(ctx context.Context) *Config {
+	config, _ := ctx.Value(contextKey{}).(*Config)
+	return config
+}
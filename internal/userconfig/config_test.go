@@ -0,0 +1,75 @@
+package userconfig_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMigratesLegacyConfig(t *testing.T) {
+	sealer, err := userconfig.NewSealer([][]byte{newKey}, true)
+	require.NoError(t, err)
+
+	config, err := userconfig.Parse(sealer, base64.RawURLEncoding.EncodeToString([]byte(`{"apiKey":" secret "}`)))
+	require.NoError(t, err)
+	assert.Equal(t, &userconfig.Config{
+		Version:    userconfig.CurrentVersion,
+		APIKey:     "secret",
+		MaxResults: userconfig.MaxResults,
+		Format:     userconfig.FormatSRT,
+		Charset:    userconfig.CharsetUTF8,
+	}, config)
+	assert.True(t, config.SharesPublicStats())
+}
+
+func TestSealAndParse(t *testing.T) {
+	sealer, err := userconfig.NewSealer([][]byte{newKey}, false)
+	require.NoError(t, err)
+
+	publicStats := false
+	token, err := userconfig.Seal(sealer, &userconfig.Config{
+		APIKey:              "secret",
+		MaxResults:          10,
+		Dialect:             "LATAM",
+		HideHearingImpaired: true,
+		Format:              userconfig.FormatVTT,
+		Charset:             userconfig.CharsetOriginal,
+		PublicStats:         &publicStats,
+	})
+	require.NoError(t, err)
+
+	config, err := userconfig.Parse(sealer, token)
+	require.NoError(t, err)
+	assert.Equal(t, userconfig.DialectLatam, config.Dialect)
+	assert.Equal(t, 10, config.MaxResults)
+	assert.True(t, config.HideHearingImpaired)
+	assert.Equal(t, userconfig.FormatVTT, config.Format)
+	assert.Equal(t, userconfig.CharsetOriginal, config.Charset)
+	assert.False(t, config.SharesPublicStats())
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config userconfig.Config
+	}{
+		{"future version", userconfig.Config{Version: userconfig.CurrentVersion + 1}},
+		{"too many results", userconfig.Config{MaxResults: userconfig.MaxResults + 1}},
+		{"negative results", userconfig.Config{MaxResults: -1}},
+		{"unknown dialect", userconfig.Config{Dialect: "rioplatense"}},
+		{"unknown format", userconfig.Config{Format: "ass"}},
+		{"unknown charset", userconfig.Config{Charset: "latin1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Normalize()
+			err := tt.config.Validate()
+			assert.True(t, errors.Is(err, userconfig.ErrInvalid), "expected ErrInvalid, got %v", err)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package userconfig
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDecrypt is returned when an encrypted user config token can't be decrypted, because it was tampered with or
+// encrypted with a key that is no longer configured.
+var ErrDecrypt = errors.New("failed to decrypt user config token")
+
+// ErrMalformed is returned when a user config token is neither a valid encrypted token nor a valid legacy token.
+var ErrMalformed = errors.New("malformed user config token")
+
+const (
+	// tokenPrefix marks the encrypted tokens, legacy tokens are plain base64 and never contain a dot.
+	tokenPrefix = "e1."
+	// tokenAdditionalData binds the encrypted tokens to their purpose.
+	tokenAdditionalData = "stremio-subdivx:userconfig:e1"
+	// keySize is the size of the AES-256 keys.
+	keySize = 32
+)
+
+type sealerKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Sealer issues AES-GCM encrypted user config tokens, and opens them with any of the configured keys so they can be rotated.
+type Sealer struct {
+	keys        []sealerKey
+	acceptPlain bool
+}
+
+/*
+NewSealer creates a new instance of the Sealer struct.
+
+Parameters:
+  - keys: The 32 bytes AES-256 keys, the first one encrypts new tokens and all of them decrypt. Without keys tokens are issued in the legacy base64 format, which requires acceptPlain.
+  - acceptPlain: Whether legacy base64 tokens are accepted, meant to be enabled only while migrating to encrypted tokens.
+
+Returns:
+  - A pointer to the newly created Sealer instance.
+*/
+func NewSealer(keys [][]byte, acceptPlain bool) (*Sealer, error) {
+	s := &Sealer{
+		keys:        make([]sealerKey, 0, len(keys)),
+		acceptPlain: acceptPlain,
+	}
+
+	for i, key := range keys {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("user config key %d must be %d bytes long, got %d", i, keySize, len(key))
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to aes.NewCipher: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to cipher.NewGCM: %w", err)
+		}
+
+		sum := sha256.Sum256(key)
+		s.keys = append(s.keys, sealerKey{
+			id:   base64.RawURLEncoding.EncodeToString(sum[:6]),
+			aead: aead,
+		})
+	}
+
+	if len(s.keys) == 0 && !acceptPlain {
+		return nil, errors.New("at least one user config key is required when legacy tokens are not accepted")
+	}
+
+	return s, nil
+}
+
+// ParseKeys parses a comma separated list of base64 encoded keys.
+func ParseKeys(s string) ([][]byte, error) {
+	var keys [][]byte
+	for _, encoded := range strings.Split(s, ",") {
+		encoded = strings.TrimSpace(encoded)
+		if encoded == "" {
+			continue
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			key, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode user config key %d: %w", len(keys), err)
+			}
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Seal returns a URL safe token holding plaintext, encrypted with the active key.
+func (s *Sealer) Seal(plaintext []byte) (string, error) {
+	if len(s.keys) == 0 {
+		return base64.RawURLEncoding.EncodeToString(plaintext), nil
+	}
+
+	key := s.keys[0]
+	nonce := make([]byte, key.aead.NonceSize(), key.aead.NonceSize()+len(plaintext)+key.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to rand.Read: %w", err)
+	}
+
+	sealed := key.aead.Seal(nonce, nonce, plaintext, []byte(tokenAdditionalData))
+
+	return tokenPrefix + key.id + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open returns the plaintext held by a token issued by Seal, or by a legacy base64 token when they are accepted.
+// It returns ErrDecrypt for encrypted tokens that fail authentication, and ErrMalformed for anything else that's not a token.
+func (s *Sealer) Open(token string) ([]byte, error) {
+	rest, encrypted := strings.CutPrefix(token, tokenPrefix)
+	if !encrypted {
+		return s.openPlain(token)
+	}
+
+	keyID, encoded, ok := strings.Cut(rest, ".")
+	if !ok {
+		return nil, ErrMalformed
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	for _, key := range s.keys {
+		if key.id != keyID {
+			continue
+		}
+
+		if len(sealed) < key.aead.NonceSize() {
+			return nil, ErrMalformed
+		}
+
+		plaintext, err := key.aead.Open(nil, sealed[:key.aead.NonceSize()], sealed[key.aead.NonceSize():], []byte(tokenAdditionalData))
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+
+		return plaintext, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown key %q", ErrDecrypt, keyID)
+}
+
+func (s *Sealer) openPlain(token string) ([]byte, error) {
+	if !s.acceptPlain {
+		return nil, fmt.Errorf("%w: legacy tokens are not accepted", ErrMalformed)
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		decoded, err = base64.URLEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,117 @@
+package userconfig_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte{1}, 32)
+	newKey = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestSealerRoundTrip(t *testing.T) {
+	sealer, err := userconfig.NewSealer([][]byte{newKey}, false)
+	require.NoError(t, err)
+
+	token, err := sealer.Seal([]byte(`{"apiKey":"secret"}`))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "e1."))
+	assert.NotContains(t, token, "secret")
+	assert.Equal(t, token, strings.Map(func(r rune) rune {
+		if strings.ContainsRune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_.", r) {
+			return r
+		}
+		return -1
+	}, token), "token must be URL safe")
+
+	plaintext, err := sealer.Open(token)
+	require.NoError(t, err)
+	assert.Equal(t, `{"apiKey":"secret"}`, string(plaintext))
+}
+
+func TestSealerKeyRotation(t *testing.T) {
+	oldSealer, err := userconfig.NewSealer([][]byte{oldKey}, false)
+	require.NoError(t, err)
+	token, err := oldSealer.Seal([]byte(`{"apiKey":"secret"}`))
+	require.NoError(t, err)
+
+	rotatedSealer, err := userconfig.NewSealer([][]byte{newKey, oldKey}, false)
+	require.NoError(t, err)
+	plaintext, err := rotatedSealer.Open(token)
+	require.NoError(t, err)
+	assert.Equal(t, `{"apiKey":"secret"}`, string(plaintext))
+
+	retiredSealer, err := userconfig.NewSealer([][]byte{newKey}, false)
+	require.NoError(t, err)
+	_, err = retiredSealer.Open(token)
+	assert.True(t, errors.Is(err, userconfig.ErrDecrypt), "expected ErrDecrypt, got %v", err)
+}
+
+func TestSealerRejectsTamperedTokens(t *testing.T) {
+	sealer, err := userconfig.NewSealer([][]byte{newKey}, true)
+	require.NoError(t, err)
+	token, err := sealer.Seal([]byte(`{"apiKey":"secret"}`))
+	require.NoError(t, err)
+
+	tampered := []byte(token)
+	middle := len(tampered) - 10
+	if tampered[middle] == 'A' {
+		tampered[middle] = 'B'
+	} else {
+		tampered[middle] = 'A'
+	}
+
+	_, err = sealer.Open(string(tampered))
+	assert.True(t, errors.Is(err, userconfig.ErrDecrypt), "expected ErrDecrypt, got %v", err)
+
+	_, err = sealer.Open("e1.garbage")
+	assert.True(t, errors.Is(err, userconfig.ErrMalformed), "expected ErrMalformed, got %v", err)
+}
+
+func TestSealerLegacyTokens(t *testing.T) {
+	legacy := base64.RawURLEncoding.EncodeToString([]byte(`{"apiKey":"secret"}`))
+
+	migrating, err := userconfig.NewSealer([][]byte{newKey}, true)
+	require.NoError(t, err)
+	plaintext, err := migrating.Open(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, `{"apiKey":"secret"}`, string(plaintext))
+
+	plaintext, err = migrating.Open(base64.URLEncoding.EncodeToString([]byte(`{"apiKey":"padded"}`)))
+	require.NoError(t, err)
+	assert.Equal(t, `{"apiKey":"padded"}`, string(plaintext))
+
+	migrated, err := userconfig.NewSealer([][]byte{newKey}, false)
+	require.NoError(t, err)
+	_, err = migrated.Open(legacy)
+	assert.True(t, errors.Is(err, userconfig.ErrMalformed), "expected ErrMalformed, got %v", err)
+}
+
+func TestNewSealerValidatesKeys(t *testing.T) {
+	_, err := userconfig.NewSealer([][]byte{[]byte("short")}, true)
+	assert.Error(t, err)
+
+	_, err = userconfig.NewSealer(nil, false)
+	assert.Error(t, err)
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := userconfig.ParseKeys(base64.StdEncoding.EncodeToString(newKey) + ", " + base64.RawURLEncoding.EncodeToString(oldKey))
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{newKey, oldKey}, keys)
+
+	keys, err = userconfig.ParseKeys("")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	_, err = userconfig.ParseKeys("not base64!")
+	assert.Error(t, err)
+}
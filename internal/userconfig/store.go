@@ -0,0 +1,72 @@
+package userconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+)
+
+// storeCacheVersion is the schema version of the stored user config tokens.
+const storeCacheVersion = 1
+
+// Store keeps the user config tokens under opaque references, so URLs can point to a user config without carrying it.
+type Store struct {
+	backend cache.Backend
+	ttl     time.Duration
+}
+
+/*
+NewStore creates a new instance of the Store struct.
+
+Parameters:
+  - backend: The cache backend the tokens are stored in, they are stored as issued by Sealer.Seal so they stay encrypted at rest.
+  - ttl: How long a token is kept after it was last put.
+
+Returns:
+  - A pointer to the newly created Store instance.
+*/
+func NewStore(backend cache.Backend, ttl time.Duration) *Store {
+	return &Store{
+		backend: backend,
+		ttl:     ttl,
+	}
+}
+
+// Ref returns the opaque reference of a user config token, the same token always gets the same reference.
+func Ref(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// AnonymousRef is the reference of the empty token, shared by every install without a user config so it doesn't
+// tell them apart.
+var AnonymousRef = Ref("")
+
+// Put stores the user config token and returns its reference as returned by Ref.
+func (s *Store) Put(ctx context.Context, token string) (string, error) {
+	ref := Ref(token)
+
+	if err := s.backend.Set(ctx, storeKey(ref), []byte(token), s.ttl); err != nil {
+		return "", fmt.Errorf("failed to cache.Backend.Set: %w", err)
+	}
+
+	return ref, nil
+}
+
+// Get returns the user config token stored under ref, or cache.ErrNotFound if it's unknown or expired.
+func (s *Store) Get(ctx context.Context, ref string) (string, error) {
+	token, err := s.backend.Get(ctx, storeKey(ref))
+	if err != nil {
+		return "", fmt.Errorf("failed to cache.Backend.Get: %w", err)
+	}
+
+	return string(token), nil
+}
+
+func storeKey(ref string) string {
+	return cache.NewKey("userconfig.ref", storeCacheVersion, ref).String()
+}
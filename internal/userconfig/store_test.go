@@ -0,0 +1,33 @@
+package userconfig_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := userconfig.NewStore(cache.NewMemoryBackend(1024), time.Hour)
+
+	ref, err := store.Put(ctx, "e1.key.token")
+	require.NoError(t, err)
+	assert.NotContains(t, ref, "token")
+
+	sameRef, err := store.Put(ctx, "e1.key.token")
+	require.NoError(t, err)
+	assert.Equal(t, ref, sameRef)
+
+	token, err := store.Get(ctx, ref)
+	require.NoError(t, err)
+	assert.Equal(t, "e1.key.token", token)
+
+	_, err = store.Get(ctx, "unknown")
+	assert.True(t, errors.Is(err, cache.ErrNotFound), "expected cache.ErrNotFound, got %v", err)
+}
@@ -0,0 +1,211 @@
+package videohash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+)
+
+// storeCacheVersion is the schema version of the stored download counts.
+const storeCacheVersion = 1
+
+// maxSubtitlesPerVideo bounds the download counts kept per video file, the least downloaded subtitles are dropped first.
+const maxSubtitlesPerVideo = 20
+
+// hashRegexp matches the OpenSubtitles hash Stremio sends as videoHash.
+var hashRegexp = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// Video identifies a video file by the OpenSubtitles hash and the size Stremio sends with the subtitles requests.
+type Video struct {
+	// Hash is the OpenSubtitles hash of the file.
+	Hash string
+	// Size is the size of the file in bytes, zero if unknown.
+	Size int64
+}
+
+// Valid reports whether the video carries a well formed hash, the size is optional.
+func (v Video) Valid() bool {
+	return hashRegexp.MatchString(v.Hash) && v.Size >= 0
+}
+
+// String returns the video identity, the hash alone doesn't cover the whole file so the size is part of it.
+func (v Video) String() string {
+	return v.Hash + "-" + strconv.FormatInt(v.Size, 10)
+}
+
+// Store remembers which subtitles were downloaded for a video file, counting each install once per subtitle, so the
+// subtitle proven to match a release can be listed first to the next viewers of the same file.
+type Store struct {
+	backend cache.Backend
+	ttl     time.Duration
+
+	// mu serializes the counts read-modify-write, the backends don't support atomic updates.
+	mu sync.Mutex
+}
+
+/*
+NewStore creates a new instance of the Store struct.
+
+Parameters:
+  - backend: The cache backend the download counts are kept in.
+  - ttl: How long the counts of a video file are kept after its last download.
+
+Returns:
+  - A pointer to the newly created Store instance.
+*/
+func NewStore(backend cache.Backend, ttl time.Duration) *Store {
+	return &Store{
+		backend: backend,
+		ttl:     ttl,
+	}
+}
+
+// RecordDownload counts a download of subtitleID for the video by the install referenced by userRef, repeated downloads
+// of the same install don't count. Invalid videos and the downloads without an install, or of the anonymous installs
+// sharing userconfig.AnonymousRef, are ignored. The videos without a size are sized as in Best.
+func (s *Store) RecordDownload(ctx context.Context, video Video, subtitleID string, userRef string) error {
+	if s == nil || !video.Valid() || subtitleID == "" || userRef == "" || userRef == userconfig.AnonymousRef {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	video, err := s.sized(ctx, video)
+	if err != nil {
+		return err
+	}
+
+	seenKey := cache.NewKey("videohash.download", storeCacheVersion, video.String(), subtitleID, userRef).String()
+	_, err = s.backend.Get(ctx, seenKey)
+	if err == nil {
+		return nil
+	} else if !errors.Is(err, cache.ErrNotFound) {
+		return fmt.Errorf("failed to cache.Backend.Get: %w", err)
+	}
+
+	counts, err := s.counts(ctx, video)
+	if err != nil {
+		return err
+	}
+	counts[subtitleID]++
+	for len(counts) > maxSubtitlesPerVideo {
+		delete(counts, leastDownloaded(counts, subtitleID))
+	}
+
+	b, err := json.Marshal(counts)
+	if err != nil {
+		return fmt.Errorf("failed to json.Marshal: %w", err)
+	}
+	if err = s.backend.Set(ctx, countsKey(video), b, s.ttl); err != nil {
+		return fmt.Errorf("failed to cache.Backend.Set: %w", err)
+	}
+	if err = s.backend.Set(ctx, seenKey, []byte{1}, s.ttl); err != nil {
+		return fmt.Errorf("failed to cache.Backend.Set: %w", err)
+	}
+	if video.Size > 0 {
+		if err = s.backend.Set(ctx, sizeKey(video), []byte(strconv.FormatInt(video.Size, 10)), s.ttl); err != nil {
+			return fmt.Errorf("failed to cache.Backend.Set: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Best returns the subtitle downloaded by most installs for the video, or cache.ErrNotFound if there's none.
+// Ties go to the lowest subtitle ID so the result is stable. The videos without a size, as the OpenSubtitles clients
+// send them, take the size last downloaded with their hash.
+func (s *Store) Best(ctx context.Context, video Video) (string, error) {
+	if s == nil || !video.Valid() {
+		return "", cache.ErrNotFound
+	}
+
+	video, err := s.sized(ctx, video)
+	if err != nil {
+		return "", err
+	}
+
+	counts, err := s.counts(ctx, video)
+	if err != nil {
+		return "", err
+	}
+
+	var best string
+	for id, count := range counts {
+		if best == "" || count > counts[best] || (count == counts[best] && id < best) {
+			best = id
+		}
+	}
+	if best == "" {
+		return "", cache.ErrNotFound
+	}
+
+	return best, nil
+}
+
+func (s *Store) counts(ctx context.Context, video Video) (map[string]int, error) {
+	counts := make(map[string]int)
+
+	b, err := s.backend.Get(ctx, countsKey(video))
+	if errors.Is(err, cache.ErrNotFound) {
+		return counts, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to cache.Backend.Get: %w", err)
+	}
+
+	if err = json.Unmarshal(b, &counts); err != nil {
+		return nil, fmt.Errorf("failed to json.Unmarshal: %w", err)
+	}
+
+	return counts, nil
+}
+
+// sized returns the video with the size last downloaded with its hash when it has none, the OpenSubtitles hash
+// already mixes the file size in so it's enough to tell the files apart.
+func (s *Store) sized(ctx context.Context, video Video) (Video, error) {
+	if video.Size > 0 {
+		return video, nil
+	}
+
+	b, err := s.backend.Get(ctx, sizeKey(video))
+	if errors.Is(err, cache.ErrNotFound) {
+		return video, nil
+	} else if err != nil {
+		return video, fmt.Errorf("failed to cache.Backend.Get: %w", err)
+	}
+
+	size, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return video, fmt.Errorf("failed to strconv.ParseInt: %w", err)
+	}
+	video.Size = size
+
+	return video, nil
+}
+
+// leastDownloaded returns the subtitle with the lowest count, other than keep.
+func leastDownloaded(counts map[string]int, keep string) string {
+	var least string
+	for id, count := range counts {
+		if id != keep && (least == "" || count < counts[least] || (count == counts[least] && id > least)) {
+			least = id
+		}
+	}
+	return least
+}
+
+func countsKey(video Video) string {
+	return cache.NewKey("videohash.counts", storeCacheVersion, video.String()).String()
+}
+
+func sizeKey(video Video) string {
+	return cache.NewKey("videohash.size", storeCacheVersion, video.Hash).String()
+}
@@ -0,0 +1,74 @@
+package videohash_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ogero/stremio-subdivx/internal/cache"
+	"github.com/ogero/stremio-subdivx/internal/userconfig"
+	"github.com/ogero/stremio-subdivx/internal/videohash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := videohash.NewStore(cache.NewMemoryBackend(64*1024), time.Hour)
+	video := videohash.Video{Hash: "8e245d9679d31e12", Size: 1073741824}
+
+	_, err := store.Best(ctx, video)
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+
+	require.NoError(t, store.RecordDownload(ctx, video, "100", "install-a"))
+	require.NoError(t, store.RecordDownload(ctx, video, "200", "install-b"))
+	require.NoError(t, store.RecordDownload(ctx, video, "200", "install-c"))
+
+	// Repeated downloads of an install count once
+	for range 3 {
+		require.NoError(t, store.RecordDownload(ctx, video, "100", "install-a"))
+	}
+
+	best, err := store.Best(ctx, video)
+	require.NoError(t, err)
+	assert.Equal(t, "200", best)
+
+	// Another size is another file
+	_, err = store.Best(ctx, videohash.Video{Hash: video.Hash, Size: 1})
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+
+	// The hash alone, as the OpenSubtitles clients send it, takes the size last downloaded with it
+	unsized := videohash.Video{Hash: video.Hash}
+	best, err = store.Best(ctx, unsized)
+	require.NoError(t, err)
+	assert.Equal(t, "200", best)
+
+	require.NoError(t, store.RecordDownload(ctx, unsized, "100", "install-d"))
+	require.NoError(t, store.RecordDownload(ctx, unsized, "100", "install-e"))
+	best, err = store.Best(ctx, video)
+	require.NoError(t, err)
+	assert.Equal(t, "100", best)
+
+	_, err = store.Best(ctx, videohash.Video{Hash: "0123456789abcdef"})
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+}
+
+func TestStoreIgnoresInvalidVideos(t *testing.T) {
+	ctx := context.Background()
+	store := videohash.NewStore(cache.NewMemoryBackend(64*1024), time.Hour)
+	video := videohash.Video{Hash: "not-a-hash"}
+
+	require.NoError(t, store.RecordDownload(ctx, video, "100", "install-a"))
+	_, err := store.Best(ctx, video)
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+
+	var disabled *videohash.Store
+	assert.NoError(t, disabled.RecordDownload(ctx, video, "100", "install-a"))
+
+	// The installs without a user config can't be told apart, their downloads don't count
+	video = videohash.Video{Hash: "8e245d9679d31e12", Size: 1073741824}
+	require.NoError(t, store.RecordDownload(ctx, video, "100", ""))
+	require.NoError(t, store.RecordDownload(ctx, video, "100", userconfig.AnonymousRef))
+	_, err = store.Best(ctx, video)
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+}
@@ -0,0 +1,143 @@
+package stremio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidManifest is returned when a manifest doesn't comply with the Stremio manifest spec.
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+var versionRegexp = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// configTypes are the setting types the Stremio configure page supports.
+var configTypes = []string{"text", "number", "password", "checkbox", "select"}
+
+/*
+LoadManifest overlays the manifest file at path onto manifest.
+
+The file is JSON, or YAML when its extension is .yaml or .yml, and uses the Stremio manifest field names. Only the fields
+present in the file are overridden, and unknown fields are rejected so typos don't go unnoticed.
+
+Parameters:
+  - path: The path of the manifest file.
+  - manifest: The manifest the file overrides, usually filled with the addon defaults.
+
+Returns:
+  - An error if the file can't be read or decoded, the result is not validated.
+*/
+func LoadManifest(path string, manifest *Manifest) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to os.ReadFile: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		// YAML goes through JSON so both formats share the json field names and the Resource decoding
+		var document any
+		if err = yaml.Unmarshal(data, &document); err != nil {
+			return fmt.Errorf("failed to yaml.Unmarshal: %w", err)
+		}
+		if data, err = json.Marshal(document); err != nil {
+			return fmt.Errorf("failed to json.Marshal: %w", err)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(manifest); err != nil {
+		return fmt.Errorf("failed to json.Decoder.Decode: %w", err)
+	}
+
+	return nil
+}
+
+// Validate checks the manifest complies with the Stremio manifest spec.
+func (m *Manifest) Validate() error {
+	if m.ID == "" || strings.ContainsAny(m.ID, " \t\r\n/") {
+		return fmt.Errorf("%w: id must be a non empty dot separated identifier, got %q", ErrInvalidManifest, m.ID)
+	}
+	if !versionRegexp.MatchString(m.Version) {
+		return fmt.Errorf("%w: version must be semver, got %q", ErrInvalidManifest, m.Version)
+	}
+	if strings.TrimSpace(m.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidManifest)
+	}
+	if strings.TrimSpace(m.Description) == "" {
+		return fmt.Errorf("%w: description is required", ErrInvalidManifest)
+	}
+	if len(m.Types) == 0 {
+		return fmt.Errorf("%w: types are required", ErrInvalidManifest)
+	}
+	if len(m.Resources) == 0 {
+		return fmt.Errorf("%w: resources are required", ErrInvalidManifest)
+	}
+	for i, resource := range m.Resources {
+		if resource.Name == "" {
+			return fmt.Errorf("%w: resources[%d] name is required", ErrInvalidManifest, i)
+		}
+	}
+	for i, catalog := range m.Catalogs {
+		if catalog.ID == "" || catalog.Type == "" {
+			return fmt.Errorf("%w: catalogs[%d] id and type are required", ErrInvalidManifest, i)
+		}
+	}
+	for i, catalog := range m.AddonCatalogs {
+		if catalog.ID == "" || catalog.Type == "" {
+			return fmt.Errorf("%w: addonCatalogs[%d] id and type are required", ErrInvalidManifest, i)
+		}
+	}
+	for i, item := range m.Config {
+		if item.Key == "" {
+			return fmt.Errorf("%w: config[%d] key is required", ErrInvalidManifest, i)
+		}
+		if !slices.Contains(configTypes, item.Type) {
+			return fmt.Errorf("%w: config[%d] type must be one of %s, got %q", ErrInvalidManifest, i, strings.Join(configTypes, ", "), item.Type)
+		}
+		if item.Type == "select" && len(item.Options) == 0 {
+			return fmt.Errorf("%w: config[%d] select options are required", ErrInvalidManifest, i)
+		}
+	}
+	if err := validateURL(m.Logo); err != nil {
+		return fmt.Errorf("%w: logo %w", ErrInvalidManifest, err)
+	}
+	if err := validateURL(m.Background); err != nil {
+		return fmt.Errorf("%w: background %w", ErrInvalidManifest, err)
+	}
+	if m.ContactEmail != "" {
+		if _, err := mail.ParseAddress(m.ContactEmail); err != nil {
+			return fmt.Errorf("%w: contactEmail %w", ErrInvalidManifest, err)
+		}
+	}
+
+	return nil
+}
+
+// validateURL checks an optional manifest URL is absolute and served over HTTP(S).
+func validateURL(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an absolute http(s) url, got %q", value)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package stremio_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogero/stremio-subdivx/pkg/stremio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func defaultManifest() *stremio.Manifest {
+	return &stremio.Manifest{
+		ID:          "ar.xor.subdivx.go",
+		Version:     "0.0.12",
+		Name:        "Subdivx",
+		Description: "Subdivx subtitles addon",
+		Types:       []string{"movie", "series"},
+		Catalogs:    []stremio.CatalogItem{},
+		IDPrefixes:  []string{"tt"},
+		Resources:   []stremio.Resource{{Name: "subtitles"}},
+	}
+}
+
+func TestLoadManifestYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+id: ar.xor.subdivx.staging
+name: Subdivx (staging)
+logo: https://example.com/logo.png
+contactEmail: ops@example.com
+resources:
+  - subtitles
+  - name: stream
+    types: [movie]
+`), 0o600))
+
+	manifest := defaultManifest()
+	require.NoError(t, stremio.LoadManifest(path, manifest))
+	require.NoError(t, manifest.Validate())
+
+	assert.Equal(t, "ar.xor.subdivx.staging", manifest.ID)
+	assert.Equal(t, "Subdivx (staging)", manifest.Name)
+	assert.Equal(t, "Subdivx subtitles addon", manifest.Description)
+	assert.Equal(t, []stremio.Resource{{Name: "subtitles"}, {Name: "stream", Types: []string{"movie"}}}, manifest.Resources)
+
+	b, err := json.Marshal(manifest.Resources)
+	require.NoError(t, err)
+	assert.JSONEq(t, `["subtitles",{"name":"stream","types":["movie"]}]`, string(b))
+}
+
+func TestLoadManifestRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"nmae":"Subdivx"}`), 0o600))
+
+	assert.Error(t, stremio.LoadManifest(path, defaultManifest()))
+}
+
+func TestManifestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(m *stremio.Manifest)
+	}{
+		{name: "missing id", mutate: func(m *stremio.Manifest) { m.ID = "" }},
+		{name: "non semver version", mutate: func(m *stremio.Manifest) { m.Version = "v1" }},
+		{name: "missing types", mutate: func(m *stremio.Manifest) { m.Types = nil }},
+		{name: "unnamed resource", mutate: func(m *stremio.Manifest) { m.Resources = []stremio.Resource{{}} }},
+		{name: "relative logo", mutate: func(m *stremio.Manifest) { m.Logo = "/logo.png" }},
+		{name: "bad contact email", mutate: func(m *stremio.Manifest) { m.ContactEmail = "ops" }},
+		{name: "select without options", mutate: func(m *stremio.Manifest) {
+			m.Config = []stremio.ConfigItem{{Key: "dialect", Type: "select"}}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := defaultManifest()
+			tt.mutate(manifest)
+			assert.ErrorIs(t, manifest.Validate(), stremio.ErrInvalidManifest)
+		})
+	}
+
+	assert.NoError(t, defaultManifest().Validate())
+}
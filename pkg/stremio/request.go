@@ -0,0 +1,188 @@
+package stremio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Resource names of the Stremio addon protocol.
+const (
+	ResourceCatalog      = "catalog"
+	ResourceMeta         = "meta"
+	ResourceStream       = "stream"
+	ResourceSubtitles    = "subtitles"
+	ResourceAddonCatalog = "addon_catalog"
+)
+
+// resources are the resource names ParseRequestPath tells apart from a config segment.
+var resources = []string{ResourceCatalog, ResourceMeta, ResourceStream, ResourceSubtitles, ResourceAddonCatalog}
+
+// ErrInvalidRequest is returned when a path doesn't follow the Stremio resource request grammar.
+var ErrInvalidRequest = errors.New("invalid resource request")
+
+// Extra represents the extra arguments of a resource request, the last path segment of the request.
+type Extra struct {
+	// Filename is the name of the video file, sent with subtitles requests.
+	Filename string
+	// VideoHash is the OpenSubtitles hash of the video file, sent with subtitles requests.
+	VideoHash string
+	// VideoSize is the size in bytes of the video file, sent with subtitles requests.
+	VideoSize int64
+	// Search is the catalog search query.
+	Search string
+	// Genre is the catalog genre filter.
+	Genre string
+	// Skip is the number of catalog items to skip, for pagination.
+	Skip int
+	// Values holds every extra argument, including the unknown ones.
+	Values url.Values
+}
+
+// ParseExtra parses the extra arguments segment of a resource request, such as "filename=a.mkv&videoSize=123".
+// Malformed numbers are ignored, Stremio sends whatever the player knows.
+func ParseExtra(segment string) (Extra, error) {
+	values, err := url.ParseQuery(segment)
+	if err != nil {
+		return Extra{}, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+
+	extra := Extra{
+		Filename:  values.Get("filename"),
+		VideoHash: values.Get("videoHash"),
+		Search:    values.Get("search"),
+		Genre:     values.Get("genre"),
+		Values:    values,
+	}
+	extra.VideoSize, _ = strconv.ParseInt(values.Get("videoSize"), 10, 64)
+	extra.Skip, _ = strconv.Atoi(values.Get("skip"))
+
+	return extra, nil
+}
+
+// Request represents a resource request of the Stremio addon protocol.
+type Request struct {
+	// Config is the addon configuration path segment, empty for unconfigured installs.
+	Config string
+	// Resource is the requested resource, such as ResourceSubtitles.
+	Resource string
+	// Type is the content type, such as "movie" or "series".
+	Type string
+	// ID is the content ID, such as "tt0903747" or "tt0903747:1:2" for an episode.
+	ID string
+	// Extra holds the extra arguments.
+	Extra Extra
+}
+
+/*
+ParseRequestPath parses a resource request path following the /{config}/{resource}/{type}/{id}/{extra}.json grammar.
+
+The config and extra segments are optional. A four segments path is told apart by its first segment: it's the resource
+when it's a known resource name, and the config otherwise.
+
+Parameters:
+  - path: The escaped request URL path, relative to where the addon is mounted, as returned by url.URL.EscapedPath.
+
+Returns:
+  - The parsed request, or an error wrapping ErrInvalidRequest.
+*/
+func ParseRequestPath(path string) (*Request, error) {
+	path, ok := strings.CutSuffix(strings.TrimPrefix(path, "/"), ".json")
+	if !ok {
+		return nil, fmt.Errorf("%w: missing .json suffix", ErrInvalidRequest)
+	}
+
+	segments := strings.Split(path, "/")
+
+	// The extra segment is left escaped, ParseExtra decodes it as a query string and decoding it twice would turn the
+	// escaped "&", "+" and "%" of the filenames into separators, spaces or errors
+	var config, extra string
+	switch {
+	case len(segments) == 5:
+		config, extra = segments[0], segments[4]
+		segments = segments[1:4]
+	case len(segments) == 4 && slices.Contains(resources, segments[0]):
+		extra = segments[3]
+		segments = segments[:3]
+	case len(segments) == 4:
+		config = segments[0]
+		segments = segments[1:]
+	case len(segments) != 3:
+		return nil, fmt.Errorf("%w: unexpected number of path segments", ErrInvalidRequest)
+	}
+
+	var err error
+	if config, err = url.PathUnescape(config); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	for i, segment := range segments {
+		if segments[i], err = url.PathUnescape(segment); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+		}
+	}
+
+	request := &Request{
+		Config:   config,
+		Resource: segments[0],
+		Type:     segments[1],
+		ID:       segments[2],
+	}
+	if request.Resource == "" || request.Type == "" || request.ID == "" {
+		return nil, fmt.Errorf("%w: empty resource, type or id", ErrInvalidRequest)
+	}
+
+	if request.Extra, err = ParseExtra(extra); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// VideoID represents a content ID split in its title ID and, for episodes, its season and episode numbers.
+type VideoID struct {
+	// TitleID is the ID of the movie or series, such as "tt0903747".
+	TitleID string
+	// Season is the season number, zero for movies.
+	Season int
+	// Episode is the episode number, zero for movies.
+	Episode int
+}
+
+// ParseVideoID splits a content ID such as "tt0903747:1:2" into its title ID, season and episode.
+func ParseVideoID(id string) (VideoID, error) {
+	parts := strings.Split(id, ":")
+	videoID := VideoID{TitleID: parts[0]}
+
+	switch len(parts) {
+	case 1:
+	case 3:
+		var err error
+		if videoID.Season, err = strconv.Atoi(parts[1]); err != nil {
+			return VideoID{}, fmt.Errorf("%w: season %w", ErrInvalidRequest, err)
+		}
+		if videoID.Episode, err = strconv.Atoi(parts[2]); err != nil {
+			return VideoID{}, fmt.Errorf("%w: episode %w", ErrInvalidRequest, err)
+		}
+	default:
+		return VideoID{}, fmt.Errorf("%w: unexpected id %q", ErrInvalidRequest, id)
+	}
+
+	return videoID, nil
+}
+
+type requestContextKey struct{}
+
+// NewContext returns a copy of ctx carrying the resource request.
+func NewContext(ctx context.Context, request *Request) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, request)
+}
+
+// FromContext returns the resource request stored by Router, nil if there's none.
+func FromContext(ctx context.Context) *Request {
+	request, _ := ctx.Value(requestContextKey{}).(*Request)
+	return request
+}
@@ -0,0 +1,113 @@
+package stremio_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ogero/stremio-subdivx/pkg/stremio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequestPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected stremio.Request
+	}{
+		{
+			path:     "/subtitles/movie/tt0111161.json",
+			expected: stremio.Request{Resource: "subtitles", Type: "movie", ID: "tt0111161"},
+		},
+		{
+			path: "/subtitles/series/tt0903747:1:2/filename=Breaking%20Bad%20S01E02.mkv&videoHash=8e245d9679d31e12&videoSize=1073741824.json",
+			expected: stremio.Request{Resource: "subtitles", Type: "series", ID: "tt0903747:1:2", Extra: stremio.Extra{
+				Filename:  "Breaking Bad S01E02.mkv",
+				VideoHash: "8e245d9679d31e12",
+				VideoSize: 1073741824,
+			}},
+		},
+		{
+			path: "/subtitles/series/tt0203259:1:1/filename=Law%20%26%20Order%20S01E01.mkv.json",
+			expected: stremio.Request{Resource: "subtitles", Type: "series", ID: "tt0203259:1:1", Extra: stremio.Extra{
+				Filename: "Law & Order S01E01.mkv",
+			}},
+		},
+		{
+			path: "/subtitles/movie/tt0232500/filename=Fast%2BFurious.2001.mkv.json",
+			expected: stremio.Request{Resource: "subtitles", Type: "movie", ID: "tt0232500", Extra: stremio.Extra{
+				Filename: "Fast+Furious.2001.mkv",
+			}},
+		},
+		{
+			path: "/subtitles/movie/tt0111161/filename=100%25.Movie.mkv.json",
+			expected: stremio.Request{Resource: "subtitles", Type: "movie", ID: "tt0111161", Extra: stremio.Extra{
+				Filename: "100%.Movie.mkv",
+			}},
+		},
+		{
+			path:     "/e1.key.token/subtitles/movie/tt0111161.json",
+			expected: stremio.Request{Config: "e1.key.token", Resource: "subtitles", Type: "movie", ID: "tt0111161"},
+		},
+		{
+			path: "/e1.key.token/catalog/movie/top/search=the%20wire&skip=100.json",
+			expected: stremio.Request{Config: "e1.key.token", Resource: "catalog", Type: "movie", ID: "top", Extra: stremio.Extra{
+				Search: "the wire",
+				Skip:   100,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			request, err := stremio.ParseRequestPath(tt.path)
+			require.NoError(t, err)
+
+			request.Extra.Values = nil
+			assert.Equal(t, tt.expected, *request)
+		})
+	}
+
+	for _, path := range []string{"/subtitles/movie/tt0111161", "/subtitles/movie.json", "/a/b/c/d/e/f.json", "/subtitles//tt0111161.json"} {
+		_, err := stremio.ParseRequestPath(path)
+		assert.ErrorIs(t, err, stremio.ErrInvalidRequest, path)
+	}
+}
+
+func TestParseVideoID(t *testing.T) {
+	videoID, err := stremio.ParseVideoID("tt0903747:1:2")
+	require.NoError(t, err)
+	assert.Equal(t, stremio.VideoID{TitleID: "tt0903747", Season: 1, Episode: 2}, videoID)
+
+	videoID, err = stremio.ParseVideoID("tt0111161")
+	require.NoError(t, err)
+	assert.Equal(t, stremio.VideoID{TitleID: "tt0111161"}, videoID)
+
+	_, err = stremio.ParseVideoID("tt0903747:one:2")
+	assert.ErrorIs(t, err, stremio.ErrInvalidRequest)
+}
+
+func TestRouter(t *testing.T) {
+	router := stremio.NewRouter(&stremio.Manifest{ID: "org.example"})
+	router.Prefix = "/addon"
+	router.Handle(stremio.ResourceSubtitles, func(w http.ResponseWriter, r *http.Request, request *stremio.Request) {
+		assert.Same(t, request, stremio.FromContext(r.Context()))
+		_, _ = w.Write([]byte(request.Config + "|" + request.ID + "|" + request.Extra.Filename))
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := serve("/addon/cfg/subtitles/movie/tt0111161/filename=a%2Fb.mkv.json")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "cfg|tt0111161|a/b.mkv", w.Body.String())
+
+	w = serve("/addon/cfg/manifest.json")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"org.example"`)
+
+	assert.Equal(t, http.StatusNotFound, serve("/addon/stream/movie/tt0111161.json").Code)
+	assert.Equal(t, http.StatusNotFound, serve("/addon/subtitles/movie").Code)
+}
@@ -0,0 +1,65 @@
+package stremio
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ResourceHandler handles a parsed resource request, the request is also available through FromContext.
+type ResourceHandler func(w http.ResponseWriter, r *http.Request, request *Request)
+
+// Router dispatches the Stremio resource requests to a handler per resource, without depending on any HTTP router.
+// It can be mounted on any path, paths are parsed relative to Prefix.
+type Router struct {
+	// Prefix is stripped from the request paths before parsing them.
+	Prefix string
+	// Manifest is served on manifest.json and {config}/manifest.json when set.
+	Manifest *Manifest
+
+	handlers map[string]ResourceHandler
+}
+
+// NewRouter creates a Router serving manifest, which can be nil to serve it elsewhere.
+func NewRouter(manifest *Manifest) *Router {
+	return &Router{
+		Manifest: manifest,
+		handlers: make(map[string]ResourceHandler),
+	}
+}
+
+// Handle registers the handler of a resource, such as ResourceSubtitles.
+func (rt *Router) Handle(resource string, handler ResourceHandler) {
+	rt.handlers[resource] = handler
+}
+
+// ServeHTTP parses the request path and calls the handler of its resource, unknown resources and malformed paths are
+// answered with 404.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.EscapedPath(), rt.Prefix)
+
+	if rt.Manifest != nil && (path == "/manifest.json" || (strings.HasSuffix(path, "/manifest.json") && strings.Count(path, "/") == 2)) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(rt.Manifest)
+		return
+	}
+
+	request, err := ParseRequestPath(path)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	handler, ok := rt.handlers[request.Resource]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	handler(w, r.WithContext(NewContext(r.Context(), request)), request)
+}
@@ -1,5 +1,9 @@
 package stremio
 
+import (
+	"encoding/json"
+)
+
 // Manifest represents a Stremio addon manifest
 type Manifest struct {
 	ID            string        `json:"id"`
@@ -9,22 +13,178 @@ type Manifest struct {
 	Types         []string      `json:"types"`
 	IDPrefixes    []string      `json:"idPrefixes"`
 	Catalogs      []CatalogItem `json:"catalogs"`
-	Resources     []string      `json:"resources"`
+	AddonCatalogs []CatalogItem `json:"addonCatalogs,omitempty"`
+	Resources     []Resource    `json:"resources"`
+	Config        []ConfigItem  `json:"config,omitempty"`
+	Background    string        `json:"background,omitempty"`
+	Logo          string        `json:"logo,omitempty"`
+	ContactEmail  string        `json:"contactEmail,omitempty"`
 	BehaviorHints BehaviorHints `json:"behaviorHints"`
 }
 
 // CatalogItem represents a Stremio manifest catalog item
 type CatalogItem struct {
-	ID   string `json:"id"`
-	Type string `json:"type"`
+	ID    string      `json:"id"`
+	Type  string      `json:"type"`
+	Name  string      `json:"name,omitempty"`
+	Extra []ExtraItem `json:"extra,omitempty"`
+}
+
+// ExtraItem represents an extra property a Stremio catalog can be requested with
+type ExtraItem struct {
+	Name         string   `json:"name"`
+	IsRequired   bool     `json:"isRequired,omitempty"`
+	Options      []string `json:"options,omitempty"`
+	OptionsLimit int      `json:"optionsLimit,omitempty"`
+}
+
+// Resource represents a Stremio manifest resource, serialized as its bare name unless it narrows the types or ID prefixes
+type Resource struct {
+	Name       string   `json:"name"`
+	Types      []string `json:"types,omitempty"`
+	IDPrefixes []string `json:"idPrefixes,omitempty"`
+}
+
+func (r Resource) MarshalJSON() ([]byte, error) {
+	if len(r.Types) == 0 && len(r.IDPrefixes) == 0 {
+		return json.Marshal(r.Name)
+	}
+
+	type resource Resource
+	return json.Marshal(resource(r))
+}
+
+func (r *Resource) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*r = Resource{Name: name}
+		return nil
+	}
+
+	type resource Resource
+	return json.Unmarshal(data, (*resource)(r))
+}
+
+// ConfigItem represents a setting of the Stremio configure page generated for the addon
+type ConfigItem struct {
+	Key      string   `json:"key"`
+	Type     string   `json:"type"`
+	Default  string   `json:"default,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	Required bool     `json:"required,omitempty"`
 }
 
 // BehaviorHints represents Stremio manifest behavior hints
 type BehaviorHints struct {
+	Adult                 bool `json:"adult,omitempty"`
+	P2P                   bool `json:"p2p,omitempty"`
 	Configurable          bool `json:"configurable"`
 	ConfigurationRequired bool `json:"configurationRequired"`
 }
 
+// MetaPreview represents the summary of a Stremio meta item listed by a catalog
+type MetaPreview struct {
+	ID          string   `json:"id"`
+	Type        string   `json:"type"`
+	Name        string   `json:"name"`
+	Poster      string   `json:"poster,omitempty"`
+	PosterShape string   `json:"posterShape,omitempty"`
+	Genres      []string `json:"genres,omitempty"`
+	IMDBRating  string   `json:"imdbRating,omitempty"`
+	ReleaseInfo string   `json:"releaseInfo,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// Meta represents a Stremio meta item with its details
+type Meta struct {
+	MetaPreview
+	Background    string             `json:"background,omitempty"`
+	Logo          string             `json:"logo,omitempty"`
+	Released      string             `json:"released,omitempty"`
+	Runtime       string             `json:"runtime,omitempty"`
+	Language      string             `json:"language,omitempty"`
+	Country       string             `json:"country,omitempty"`
+	Website       string             `json:"website,omitempty"`
+	Director      []string           `json:"director,omitempty"`
+	Cast          []string           `json:"cast,omitempty"`
+	Links         []MetaLink         `json:"links,omitempty"`
+	Videos        []Video            `json:"videos,omitempty"`
+	BehaviorHints *MetaBehaviorHints `json:"behaviorHints,omitempty"`
+}
+
+// MetaLink represents a link shown in a Stremio meta item details
+type MetaLink struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	URL      string `json:"url"`
+}
+
+// MetaBehaviorHints represents Stremio meta item behavior hints
+type MetaBehaviorHints struct {
+	DefaultVideoID string `json:"defaultVideoId,omitempty"`
+}
+
+// Video represents a video of a Stremio meta item, such as a series episode
+type Video struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Released  string `json:"released,omitempty"`
+	Thumbnail string `json:"thumbnail,omitempty"`
+	Overview  string `json:"overview,omitempty"`
+	Season    int    `json:"season,omitempty"`
+	Episode   int    `json:"episode,omitempty"`
+}
+
+// Stream represents a Stremio stream source
+type Stream struct {
+	URL           string               `json:"url,omitempty"`
+	YTID          string               `json:"ytId,omitempty"`
+	InfoHash      string               `json:"infoHash,omitempty"`
+	FileIdx       *int                 `json:"fileIdx,omitempty"`
+	ExternalURL   string               `json:"externalUrl,omitempty"`
+	Name          string               `json:"name,omitempty"`
+	Description   string               `json:"description,omitempty"`
+	Subtitles     []Subtitle           `json:"subtitles,omitempty"`
+	Sources       []string             `json:"sources,omitempty"`
+	BehaviorHints *StreamBehaviorHints `json:"behaviorHints,omitempty"`
+}
+
+// StreamBehaviorHints represents Stremio stream behavior hints
+type StreamBehaviorHints struct {
+	CountryWhitelist []string `json:"countryWhitelist,omitempty"`
+	NotWebReady      bool     `json:"notWebReady,omitempty"`
+	BingeGroup       string   `json:"bingeGroup,omitempty"`
+	VideoHash        string   `json:"videoHash,omitempty"`
+	VideoSize        int64    `json:"videoSize,omitempty"`
+	Filename         string   `json:"filename,omitempty"`
+}
+
+// CacheHints represents the caching hints Stremio honours on every resource response, in seconds
+type CacheHints struct {
+	CacheMaxAge     int `json:"cacheMaxAge,omitempty"`
+	StaleRevalidate int `json:"staleRevalidate,omitempty"`
+	StaleError      int `json:"staleError,omitempty"`
+}
+
+// MetaResponse represents the response of the meta resource
+type MetaResponse struct {
+	Meta Meta `json:"meta"`
+	CacheHints
+}
+
+// CatalogResponse represents the response of the catalog resource
+type CatalogResponse struct {
+	Metas []MetaPreview `json:"metas"`
+	CacheHints
+}
+
+// StreamsResponse represents the response of the stream resource
+type StreamsResponse struct {
+	Streams []Stream `json:"streams"`
+	CacheHints
+}
+
 // Subtitle represents a Stremio subtitle
 type Subtitle struct {
 	ID   string `json:"id"`
@@ -36,4 +196,5 @@ type Subtitle struct {
 // Each entry contains details about the subtitle such as ID, language, and URL.
 type Subtitles struct {
 	Subtitles []Subtitle `json:"subtitles"`
+	CacheHints
 }
@@ -0,0 +1,155 @@
+package subx
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// EpisodeInfo is what a release name or a subtitle description says about the episodes it covers.
+type EpisodeInfo struct {
+	// Season is the season number, zero for the specials or if unknown.
+	Season int
+	// Episodes are the episode numbers covered, more than one for multi-episode files and ranges.
+	Episodes []int
+	// SeasonPack reports whether the whole season is covered, Episodes is empty then.
+	SeasonPack bool
+	// AirDate is the air date of the daily shows episodes named after it, as YYYY-MM-DD.
+	AirDate string
+}
+
+// Contains reports whether the info covers the episode of the season, season packs cover every episode.
+func (e EpisodeInfo) Contains(season int, episode int) bool {
+	if e.Season != season {
+		return false
+	}
+	return e.SeasonPack || slices.Contains(e.Episodes, episode)
+}
+
+// EpisodeQuery is the episode a viewer is watching.
+type EpisodeQuery struct {
+	// Season is the season number, zero for the specials.
+	Season int
+	// Episode is the episode number, zero if unknown.
+	Episode int
+	// AirDate is the air date of the episode as YYYY-MM-DD, parsed from the video filename of the daily shows.
+	AirDate string
+}
+
+// Matches reports whether the info covers the queried episode. Episodes named after their air date match by date
+// when the query has one, as their season and episode numbers rarely line up with the catalogs.
+func (e EpisodeInfo) Matches(q EpisodeQuery) bool {
+	if e.AirDate != "" && q.AirDate != "" {
+		return e.AirDate == q.AirDate
+	}
+	if q.Episode == 0 && q.AirDate == "" {
+		return true
+	}
+	return q.Episode > 0 && e.Contains(q.Season, q.Episode)
+}
+
+// maxEpisodesPerRange bounds the episode ranges, larger ones are most likely misparsed numbers.
+const maxEpisodesPerRange = 50
+
+var (
+	// S02E05, S02E05E06, S02E05-E07, S02E05-07, S02 E05
+	seasonEpisodeRegexp = regexp.MustCompile(`\bs(\d{1,2})[ ._-]?e(\d{1,3})((?:[ ._]?(?:-[ ._]?e?|e)\d{1,3})*)\b`)
+	// 2x05, 2x05-07, 2x05x06
+	crossEpisodeRegexp = regexp.MustCompile(`\b(\d{1,2})x(\d{2,3})((?:[-x]\d{2,3})*)\b`)
+	// Temporada 2 Capítulo 5, Temporada 2 Episodios 5 al 7, Season 2 Episode 5, Season 2 Episodes 5-7
+	longEpisodeRegexp = regexp.MustCompile(`\b(?:temporada|season|temp\.?)\s*(\d{1,2})\s*[,:-]?\s*(?:cap[ií]tulos?|episodios?|episodes?|cap\.?|ep\.?)\s*(\d{1,3})(?:\s*(?:-|al|a|y|to|and|&)\s*(\d{1,3}))?`)
+	// 2024.05.13, 2024-05-13, 13.05.2024, 13/05/2024
+	airDateRegexp         = regexp.MustCompile(`\b((?:19|20)\d{2})[ ._-](0[1-9]|1[0-2])[ ._-](0[1-9]|[12]\d|3[01])\b`)
+	reversedAirDateRegexp = regexp.MustCompile(`\b(0[1-9]|[12]\d|3[01])[ ._/-](0[1-9]|1[0-2])[ ._/-]((?:19|20)\d{2})\b`)
+	// Especial 2, Special 2, SP02
+	specialRegexp = regexp.MustCompile(`\b(?:especial|special|sp)[ ._-]?(\d{1,2})\b`)
+	// S02, Temporada 2, Season 2, 2nd Season, Segunda Temporada
+	seasonRegexp        = regexp.MustCompile(`\b(?:s(\d{1,2})|(?:temporada|season)\s*(\d{1,2})|(\d{1,2})(?:st|nd|rd|th|ra|da|ta|va|na|ma)?\s*(?:temporada|season))\b`)
+	ordinalSeasonRegexp = regexp.MustCompile(`\b(primera|segunda|tercera|cuarta|quinta|sexta|s[eé]ptima|octava|novena|d[eé]cima)\s+temporada\b`)
+	rangeSuffixRegexp   = regexp.MustCompile(`[ ._]?(-)?[ ._]?[ex]?(\d{1,3})`)
+)
+
+var spanishOrdinals = map[string]int{
+	"primera": 1, "segunda": 2, "tercera": 3, "cuarta": 4, "quinta": 5,
+	"sexta": 6, "septima": 7, "séptima": 7, "octava": 8, "novena": 9, "decima": 10, "décima": 10,
+}
+
+/*
+ParseEpisodes extracts the season and episodes of a release name or subtitle description.
+
+It understands the English and Spanish conventions: "S02E05", "2x05", "Temporada 2 Capítulo 5", "Season 2 Episode 5",
+the multi-episode files and ranges like "S02E05E06", "S02E05-E07" or "Temporada 2 Capítulos 5 al 7", the specials like
+"S00E02" or "Especial 2", the daily shows episodes like "2024.05.13" or "13.05.2024", and the season packs like "S02",
+"Temporada 2" or "Segunda Temporada".
+
+Parameters:
+  - s: The text to parse.
+
+Returns:
+  - The parsed info, and false when the text says nothing about the episodes.
+*/
+func ParseEpisodes(s string) (EpisodeInfo, bool) {
+	s = strings.ToLower(s)
+
+	if m := seasonEpisodeRegexp.FindStringSubmatch(s); m != nil {
+		return episodeInfo(m[1], m[2], m[3])
+	}
+	if m := airDateRegexp.FindStringSubmatch(s); m != nil {
+		return EpisodeInfo{AirDate: m[1] + "-" + m[2] + "-" + m[3]}, true
+	}
+	if m := reversedAirDateRegexp.FindStringSubmatch(s); m != nil {
+		return EpisodeInfo{AirDate: m[3] + "-" + m[2] + "-" + m[1]}, true
+	}
+	if m := crossEpisodeRegexp.FindStringSubmatch(s); m != nil {
+		return episodeInfo(m[1], m[2], m[3])
+	}
+	if m := longEpisodeRegexp.FindStringSubmatch(s); m != nil {
+		suffix := ""
+		if m[3] != "" {
+			suffix = "-" + m[3]
+		}
+		return episodeInfo(m[1], m[2], suffix)
+	}
+	if m := specialRegexp.FindStringSubmatch(s); m != nil {
+		return episodeInfo("0", m[1], "")
+	}
+
+	if m := seasonRegexp.FindStringSubmatch(s); m != nil {
+		for _, group := range m[1:] {
+			if season, err := strconv.Atoi(group); err == nil && season > 0 {
+				return EpisodeInfo{Season: season, SeasonPack: true}, true
+			}
+		}
+	}
+	if m := ordinalSeasonRegexp.FindStringSubmatch(s); m != nil {
+		return EpisodeInfo{Season: spanishOrdinals[m[1]], SeasonPack: true}, true
+	}
+
+	return EpisodeInfo{}, false
+}
+
+// episodeInfo builds the info of a season, a first episode and the suffix listing the next episodes or the range end.
+func episodeInfo(seasonGroup string, episodeGroup string, suffix string) (EpisodeInfo, bool) {
+	season, _ := strconv.Atoi(seasonGroup)
+	episode, _ := strconv.Atoi(episodeGroup)
+	info := EpisodeInfo{Season: season, Episodes: []int{episode}}
+
+	last := episode
+	for _, m := range rangeSuffixRegexp.FindAllStringSubmatch(suffix, -1) {
+		next, _ := strconv.Atoi(m[2])
+		if next <= last {
+			break
+		}
+		if m[1] == "-" && next-last <= maxEpisodesPerRange {
+			for e := last + 1; e <= next; e++ {
+				info.Episodes = append(info.Episodes, e)
+			}
+		} else {
+			info.Episodes = append(info.Episodes, next)
+		}
+		last = next
+	}
+
+	return info, true
+}
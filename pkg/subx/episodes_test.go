@@ -0,0 +1,148 @@
+package subx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEpisodes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected EpisodeInfo
+		ok       bool
+	}{
+		{input: "Breaking.Bad.S02E05.720p.BluRay", expected: EpisodeInfo{Season: 2, Episodes: []int{5}}, ok: true},
+		{input: "The Wire S01E05 2002 1080p", expected: EpisodeInfo{Season: 1, Episodes: []int{5}}, ok: true},
+		{input: "Show.S01E01E02.WEB", expected: EpisodeInfo{Season: 1, Episodes: []int{1, 2}}, ok: true},
+		{input: "Show S01E01-E03", expected: EpisodeInfo{Season: 1, Episodes: []int{1, 2, 3}}, ok: true},
+		{input: "Show S01E01-03 x264", expected: EpisodeInfo{Season: 1, Episodes: []int{1, 2, 3}}, ok: true},
+		{input: "Lost 2x05 LOL", expected: EpisodeInfo{Season: 2, Episodes: []int{5}}, ok: true},
+		{input: "Lost 2x05-06 1920x1080", expected: EpisodeInfo{Season: 2, Episodes: []int{5, 6}}, ok: true},
+		{input: "Subtítulos de la Temporada 2 Capítulo 5", expected: EpisodeInfo{Season: 2, Episodes: []int{5}}, ok: true},
+		{input: "Temporada 3, capitulos 1 al 4", expected: EpisodeInfo{Season: 3, Episodes: []int{1, 2, 3, 4}}, ok: true},
+		{input: "Season 1 Episode 10", expected: EpisodeInfo{Season: 1, Episodes: []int{10}}, ok: true},
+		{input: "Dark S02 Completa", expected: EpisodeInfo{Season: 2, SeasonPack: true}, ok: true},
+		{input: "Todos los capítulos de la temporada 4", expected: EpisodeInfo{Season: 4, SeasonPack: true}, ok: true},
+		{input: "Segunda temporada completa", expected: EpisodeInfo{Season: 2, SeasonPack: true}, ok: true},
+		{input: "Sherlock S00E01 La novia abominable", expected: EpisodeInfo{Season: 0, Episodes: []int{1}}, ok: true},
+		{input: "Doctor Who Especial 2 de Navidad", expected: EpisodeInfo{Season: 0, Episodes: []int{2}}, ok: true},
+		{input: "Friends 2x12-13 El de después del Super Bowl", expected: EpisodeInfo{Season: 2, Episodes: []int{12, 13}}, ok: true},
+		{input: "Los Simpsons 35x01", expected: EpisodeInfo{Season: 35, Episodes: []int{1}}, ok: true},
+		{input: "Lost temporada 1 capitulos 1 y 2, Piloto", expected: EpisodeInfo{Season: 1, Episodes: []int{1, 2}}, ok: true},
+		{input: "The.Daily.Show.2024.05.13.720p.WEB.h264-EDITH", expected: EpisodeInfo{AirDate: "2024-05-13"}, ok: true},
+		{input: "Last Week Tonight emitido el 13.05.2024", expected: EpisodeInfo{AirDate: "2024-05-13"}, ok: true},
+		{input: "Movie.2019.1080p.x264", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			info, ok := ParseEpisodes(tt.input)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, info)
+		})
+	}
+}
+
+func TestEpisodeInfoContains(t *testing.T) {
+	assert.True(t, EpisodeInfo{Season: 1, Episodes: []int{1, 2}}.Contains(1, 2))
+	assert.False(t, EpisodeInfo{Season: 1, Episodes: []int{1, 2}}.Contains(2, 2))
+	assert.True(t, EpisodeInfo{Season: 3, SeasonPack: true}.Contains(3, 9))
+}
+
+func TestEpisodeInfoMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     EpisodeInfo
+		query    EpisodeQuery
+		expected bool
+	}{
+		{name: "episode", info: EpisodeInfo{Season: 1, Episodes: []int{2}}, query: EpisodeQuery{Season: 1, Episode: 2}, expected: true},
+		{name: "other episode", info: EpisodeInfo{Season: 1, Episodes: []int{2}}, query: EpisodeQuery{Season: 1, Episode: 3}, expected: false},
+		{name: "double episode", info: EpisodeInfo{Season: 1, Episodes: []int{1, 2}}, query: EpisodeQuery{Season: 1, Episode: 2}, expected: true},
+		{name: "special", info: EpisodeInfo{Season: 0, Episodes: []int{1}}, query: EpisodeQuery{Season: 0, Episode: 1}, expected: true},
+		{name: "special is not the first episode", info: EpisodeInfo{Season: 0, Episodes: []int{1}}, query: EpisodeQuery{Season: 1, Episode: 1}, expected: false},
+		{name: "season pack", info: EpisodeInfo{Season: 2, SeasonPack: true}, query: EpisodeQuery{Season: 2, Episode: 8}, expected: true},
+		{name: "air date", info: EpisodeInfo{AirDate: "2024-05-13"}, query: EpisodeQuery{Season: 29, Episode: 88, AirDate: "2024-05-13"}, expected: true},
+		{name: "other air date", info: EpisodeInfo{AirDate: "2024-05-13"}, query: EpisodeQuery{Season: 29, Episode: 89, AirDate: "2024-05-14"}, expected: false},
+		{name: "air date without query date", info: EpisodeInfo{AirDate: "2024-05-13"}, query: EpisodeQuery{Season: 29, Episode: 88}, expected: false},
+		{name: "numbered episode with query date", info: EpisodeInfo{Season: 29, Episodes: []int{88}}, query: EpisodeQuery{Season: 29, Episode: 88, AirDate: "2024-05-13"}, expected: true},
+		{name: "no episode", info: EpisodeInfo{Season: 1, Episodes: []int{2}}, query: EpisodeQuery{Season: 1}, expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.info.Matches(tt.query))
+		})
+	}
+}
+
+func TestFillEpisodes(t *testing.T) {
+	subtitle := &Subtitle{Title: "Dark", Description: "Temporada 2 Capítulo 5, versión WEB"}
+	subtitle.fillEpisodes()
+	assert.Equal(t, 2, subtitle.Season)
+	assert.Equal(t, 5, subtitle.Episode)
+	assert.True(t, subtitle.MatchesEpisode(EpisodeQuery{Season: 2, Episode: 5}))
+	assert.False(t, subtitle.MatchesEpisode(EpisodeQuery{Season: 2, Episode: 6}))
+
+	subtitle = &Subtitle{Title: "Dark", Season: 2, Description: "Temporada 2 completa"}
+	subtitle.fillEpisodes()
+	assert.True(t, subtitle.SeasonPack)
+	assert.True(t, subtitle.MatchesEpisode(EpisodeQuery{Season: 2, Episode: 6}))
+
+	subtitle = &Subtitle{Title: "Dark", Season: 2, Description: "Todos los capítulos"}
+	subtitle.fillEpisodes()
+	assert.False(t, subtitle.SeasonPack, "only the parsed season packs are season packs")
+	assert.False(t, subtitle.MatchesEpisode(EpisodeQuery{Season: 2, Episode: 6}))
+	assert.True(t, subtitle.MatchesEpisode(EpisodeQuery{Season: 2}))
+
+	subtitle = &Subtitle{Title: "Dark", Season: 2, Description: "Dark.S03E01.720p"}
+	subtitle.fillEpisodes()
+	assert.False(t, subtitle.SeasonPack, "a season disagreeing with SubX isn't a season pack")
+	assert.False(t, subtitle.MatchesEpisode(EpisodeQuery{Season: 2, Episode: 1}))
+
+	subtitle = &Subtitle{Title: "Dark", Season: 1, Episode: 3, Description: "S02E05"}
+	subtitle.fillEpisodes()
+	assert.Equal(t, []int{3}, subtitle.Episodes, "the SubX metadata wins over a parsed episode that disagrees")
+
+	subtitle = &Subtitle{Title: "Dark", Season: 1, Episode: 1, Description: "Dark.S01E01E02.720p.WEB"}
+	subtitle.fillEpisodes()
+	assert.Equal(t, []int{1, 2}, subtitle.Episodes, "the multi-episode files starting with the SubX episode are expanded")
+	assert.True(t, subtitle.MatchesEpisode(EpisodeQuery{Season: 1, Episode: 2}))
+
+	subtitle = &Subtitle{Title: "Dark", Season: 1, Episode: 3, Description: "Dark.S01E01E02.720p.WEB"}
+	subtitle.fillEpisodes()
+	assert.Equal(t, []int{3}, subtitle.Episodes)
+
+	subtitle = &Subtitle{Title: "The Daily Show", Description: "The.Daily.Show.2024.05.13.720p.WEB.h264-EDITH"}
+	subtitle.fillEpisodes()
+	assert.Equal(t, "2024-05-13", subtitle.AirDate)
+	assert.True(t, subtitle.MatchesEpisode(EpisodeQuery{Season: 29, Episode: 88, AirDate: "2024-05-13"}))
+
+	subtitle = &Subtitle{Title: "Sherlock", Episode: 1, Description: "La novia abominable"}
+	subtitle.fillEpisodes()
+	assert.True(t, subtitle.MatchesEpisode(EpisodeQuery{Season: 0, Episode: 1}), "SubX listing an episode without season is a special")
+	assert.False(t, subtitle.MatchesEpisode(EpisodeQuery{Season: 1, Episode: 1}))
+}
+
+func TestExtractSubtitlePicksEpisodeFromArchive(t *testing.T) {
+	archive := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(archive)
+	for _, name := range []string{"Dark.S02E01.srt", "Dark.S02E05.srt", "Dark.S02E06.srt"} {
+		file, err := zipWriter.Create(name)
+		require.NoError(t, err)
+		_, err = io.WriteString(file, name)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+
+	subtitle, err := extractSubtitle(archive.Bytes(), "pack.zip", 2, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "Dark.S02E05.srt", subtitle.Name)
+
+	subtitle, err = extractSubtitle(archive.Bytes(), "pack.zip", 2, 9)
+	require.NoError(t, err)
+	assert.Equal(t, "Dark.S02E01.srt", subtitle.Name, "falls back to the first subtitle")
+}
@@ -0,0 +1,323 @@
+package subx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultQuotaWindow is the window the SubX limits are assumed to refill over when the responses don't tell.
+	defaultQuotaWindow = time.Hour
+	// quotaIdleTimeout is how long the quota of an API key is kept after its last request.
+	quotaIdleTimeout = 24 * time.Hour
+)
+
+// RateLimitError is returned when an API key is out of quota, either because SubX said so or because the local
+// tracking predicts it. It matches ErrRateLimited with errors.Is.
+type RateLimitError struct {
+	// RetryAfter is how long until a request is expected to be accepted again, zero if unknown.
+	RetryAfter time.Duration
+	// Local reports whether the request was throttled locally, without reaching SubX.
+	Local bool
+}
+
+func (e *RateLimitError) Error() string {
+	source := "subx"
+	if e.Local {
+		source = "local quota tracking"
+	}
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s: reported by %s, retry after %s", ErrRateLimited, source, e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("%s: reported by %s", ErrRateLimited, source)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+type reserveContextKey struct{}
+
+// WithReserve returns a copy of ctx whose requests call reserve right before they're sent to SubX, once the API key
+// quota allows them, and aren't sent when it fails. It lets the callers count only the requests that reach SubX, and
+// not the ones served from a cache.
+func WithReserve(ctx context.Context, reserve func(ctx context.Context) error) context.Context {
+	return context.WithValue(ctx, reserveContextKey{}, reserve)
+}
+
+// reserveFromContext calls the reserve function of WithReserve, if ctx has one.
+func reserveFromContext(ctx context.Context) error {
+	reserve, ok := ctx.Value(reserveContextKey{}).(func(ctx context.Context) error)
+	if !ok {
+		return nil
+	}
+	return reserve(ctx)
+}
+
+// Quota is the known SubX quota of an API key.
+type Quota struct {
+	// Limit is the number of requests allowed per Window.
+	Limit int
+	// Remaining is the number of requests left right now.
+	Remaining int
+	// Window is the period Limit refills over.
+	Window time.Duration
+	// ResetAt is when the quota is expected to be fully refilled.
+	ResetAt time.Time
+	// UpdatedAt is when SubX last reported the quota, zero if it never did.
+	UpdatedAt time.Time
+}
+
+type quotaState struct {
+	limit        float64
+	tokens       float64
+	window       time.Duration
+	last         time.Time
+	blockedUntil time.Time
+	updatedAt    time.Time
+}
+
+// QuotaTracker tracks the SubX quota of every API key from the rate limit response headers, and keeps a local token
+// bucket per API key so requests are throttled before SubX rejects them.
+type QuotaTracker struct {
+	// DefaultLimit is the quota assumed for API keys SubX didn't report one for yet, zero doesn't throttle them.
+	DefaultLimit int
+	// DefaultWindow is the window the quota is assumed to refill over when SubX doesn't report it.
+	DefaultWindow time.Duration
+
+	now    func() time.Time
+	mu     sync.Mutex
+	quotas map[string]*quotaState
+}
+
+// NewQuotaTracker creates a QuotaTracker assuming defaultLimit requests every defaultWindow until SubX reports the quota.
+func NewQuotaTracker(defaultLimit int, defaultWindow time.Duration) *QuotaTracker {
+	if defaultWindow <= 0 {
+		defaultWindow = defaultQuotaWindow
+	}
+	return &QuotaTracker{
+		DefaultLimit:  defaultLimit,
+		DefaultWindow: defaultWindow,
+		now:           time.Now,
+		quotas:        make(map[string]*quotaState),
+	}
+}
+
+// quotaKey hashes the API key so it's not kept in memory in plain text.
+func quotaKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:16])
+}
+
+// refill adds the tokens earned since the last request, must be called holding the lock.
+func (q *quotaState) refill(now time.Time) {
+	if q.limit > 0 && q.window > 0 {
+		q.tokens = math.Min(q.limit, q.tokens+now.Sub(q.last).Seconds()*q.limit/q.window.Seconds())
+	}
+	q.last = now
+}
+
+// Reserve takes a request from the API key quota, it returns a *RateLimitError when the quota is known to be exhausted.
+func (t *QuotaTracker) Reserve(apiKey string) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	key := quotaKey(apiKey)
+
+	q, ok := t.quotas[key]
+	if !ok {
+		if t.DefaultLimit <= 0 {
+			return nil
+		}
+		q = &quotaState{limit: float64(t.DefaultLimit), tokens: float64(t.DefaultLimit), window: t.DefaultWindow, last: now}
+		t.quotas[key] = q
+	}
+
+	if now.Before(q.blockedUntil) {
+		return &RateLimitError{RetryAfter: q.blockedUntil.Sub(now), Local: true}
+	}
+
+	q.refill(now)
+	if q.limit <= 0 {
+		return nil
+	}
+	if q.tokens < 1 {
+		return &RateLimitError{RetryAfter: time.Duration((1 - q.tokens) * q.window.Seconds() / q.limit * float64(time.Second)), Local: true}
+	}
+	q.tokens--
+
+	return nil
+}
+
+// Update syncs the API key quota with the rate limit headers of a SubX response.
+// It understands the X-RateLimit-*, the IETF RateLimit-* and the Retry-After headers.
+func (t *QuotaTracker) Update(apiKey string, res *http.Response) {
+	if t == nil {
+		return
+	}
+
+	limit, hasLimit := headerInt(res.Header, "X-RateLimit-Limit", "RateLimit-Limit")
+	remaining, hasRemaining := headerInt(res.Header, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	reset, hasReset := headerInt(res.Header, "X-RateLimit-Reset", "RateLimit-Reset")
+	window, hasWindow := policyWindow(res.Header)
+	retryAfter, hasRetryAfter := retryAfterHeader(res.Header, t.now())
+
+	if !hasLimit && !hasRemaining && !hasRetryAfter && res.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.sweep(now)
+
+	key := quotaKey(apiKey)
+	q, ok := t.quotas[key]
+	if !ok {
+		q = &quotaState{limit: float64(t.DefaultLimit), tokens: float64(t.DefaultLimit), window: t.DefaultWindow, last: now}
+		t.quotas[key] = q
+	}
+	q.refill(now)
+	q.updatedAt = now
+
+	var resetAt time.Time
+	if hasReset {
+		// Values that look like a unix timestamp are absolute, anything else is seconds from now
+		if reset > 1_000_000_000 {
+			resetAt = time.Unix(int64(reset), 0)
+		} else {
+			resetAt = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+
+	if hasLimit {
+		q.limit = float64(limit)
+	}
+	if hasWindow {
+		q.window = window
+	}
+	if hasRemaining {
+		q.tokens = float64(remaining)
+	}
+
+	switch {
+	case hasRetryAfter:
+		q.tokens = 0
+		q.blockedUntil = now.Add(retryAfter)
+	case res.StatusCode == http.StatusTooManyRequests || (hasRemaining && remaining == 0):
+		q.tokens = 0
+		if !resetAt.IsZero() {
+			q.blockedUntil = resetAt
+		}
+	}
+}
+
+// Quota returns the known quota of the API key, false if SubX never reported one and there's no default.
+func (t *QuotaTracker) Quota(apiKey string) (Quota, bool) {
+	if t == nil {
+		return Quota{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	q, ok := t.quotas[quotaKey(apiKey)]
+	if !ok {
+		if t.DefaultLimit <= 0 {
+			return Quota{}, false
+		}
+		return Quota{Limit: t.DefaultLimit, Remaining: t.DefaultLimit, Window: t.DefaultWindow, ResetAt: now}, true
+	}
+
+	q.refill(now)
+	quota := Quota{
+		Limit:     int(q.limit),
+		Remaining: int(q.tokens),
+		Window:    q.window,
+		ResetAt:   now,
+		UpdatedAt: q.updatedAt,
+	}
+	if q.limit > 0 && q.window > 0 {
+		quota.ResetAt = now.Add(time.Duration((q.limit - q.tokens) * q.window.Seconds() / q.limit * float64(time.Second)))
+	}
+	if now.Before(q.blockedUntil) {
+		quota.Remaining = 0
+		quota.ResetAt = q.blockedUntil
+	}
+
+	return quota, true
+}
+
+// sweep drops the quotas of the API keys idle for quotaIdleTimeout, must be called holding the lock.
+func (t *QuotaTracker) sweep(now time.Time) {
+	for key, q := range t.quotas {
+		if now.Sub(q.last) > quotaIdleTimeout {
+			delete(t.quotas, key)
+		}
+	}
+}
+
+// headerInt returns the first integer of the first present header, ignoring the parameters like in "100;w=3600".
+func headerInt(header http.Header, names ...string) (int, bool) {
+	for _, name := range names {
+		value := header.Get(name)
+		if value == "" {
+			continue
+		}
+		value, _, _ = strings.Cut(value, ",")
+		value, _, _ = strings.Cut(value, ";")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			continue
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+// policyWindow returns the window of a "RateLimit-Policy: 100;w=3600" header.
+func policyWindow(header http.Header) (time.Duration, bool) {
+	for _, name := range []string{"RateLimit-Policy", "X-RateLimit-Policy"} {
+		for _, param := range strings.Split(header.Get(name), ";") {
+			value, ok := strings.CutPrefix(strings.TrimSpace(param), "w=")
+			if !ok {
+				continue
+			}
+			seconds, err := strconv.Atoi(value)
+			if err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// retryAfterHeader parses a Retry-After header holding either seconds or an HTTP date.
+func retryAfterHeader(header http.Header, now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
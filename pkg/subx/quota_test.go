@@ -0,0 +1,126 @@
+package subx
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestQuotaTracker(defaultLimit int, now *time.Time) *QuotaTracker {
+	tracker := NewQuotaTracker(defaultLimit, time.Hour)
+	tracker.now = func() time.Time { return *now }
+	return tracker
+}
+
+func quotaResponse(status int, headers map[string]string) *http.Response {
+	res := &http.Response{StatusCode: status, Header: http.Header{}}
+	for name, value := range headers {
+		res.Header.Set(name, value)
+	}
+	return res
+}
+
+func TestQuotaTrackerFollowsHeaders(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	tracker := newTestQuotaTracker(0, &now)
+
+	_, known := tracker.Quota("api-key")
+	assert.False(t, known)
+	require.NoError(t, tracker.Reserve("api-key"), "unknown quotas must not be throttled")
+
+	tracker.Update("api-key", quotaResponse(http.StatusOK, map[string]string{
+		"RateLimit-Limit":     "100",
+		"RateLimit-Remaining": "2",
+		"RateLimit-Reset":     "1800",
+		"RateLimit-Policy":    "100;w=3600",
+	}))
+
+	quota, known := tracker.Quota("api-key")
+	require.True(t, known)
+	assert.Equal(t, 100, quota.Limit)
+	assert.Equal(t, 2, quota.Remaining)
+	assert.Equal(t, time.Hour, quota.Window)
+	assert.Equal(t, now, quota.UpdatedAt)
+
+	require.NoError(t, tracker.Reserve("api-key"))
+	require.NoError(t, tracker.Reserve("api-key"))
+	err := tracker.Reserve("api-key")
+	assert.True(t, errors.Is(err, ErrRateLimited), "expected ErrRateLimited, got %v", err)
+	var rateLimitErr *RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr))
+	assert.True(t, rateLimitErr.Local)
+	assert.Equal(t, 36*time.Second, rateLimitErr.RetryAfter)
+
+	now = now.Add(36 * time.Second)
+	assert.NoError(t, tracker.Reserve("api-key"), "the bucket must refill at limit/window")
+
+	require.NoError(t, tracker.Reserve("other-api-key"), "api keys must have independent quotas")
+}
+
+func TestQuotaTrackerBlocksUntilRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	tracker := newTestQuotaTracker(0, &now)
+
+	tracker.Update("api-key", quotaResponse(http.StatusTooManyRequests, map[string]string{
+		"Retry-After": "120",
+	}))
+
+	err := tracker.Reserve("api-key")
+	var rateLimitErr *RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr), "expected *RateLimitError, got %v", err)
+	assert.Equal(t, 120*time.Second, rateLimitErr.RetryAfter)
+
+	quota, known := tracker.Quota("api-key")
+	require.True(t, known)
+	assert.Equal(t, 0, quota.Remaining)
+	assert.Equal(t, now.Add(120*time.Second), quota.ResetAt)
+
+	now = now.Add(121 * time.Second)
+	assert.NoError(t, tracker.Reserve("api-key"))
+}
+
+func TestQuotaTrackerDefaultLimit(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	tracker := newTestQuotaTracker(1, &now)
+
+	quota, known := tracker.Quota("api-key")
+	require.True(t, known)
+	assert.Equal(t, 1, quota.Remaining)
+
+	require.NoError(t, tracker.Reserve("api-key"))
+	assert.True(t, errors.Is(tracker.Reserve("api-key"), ErrRateLimited))
+}
+
+func TestSubXTracksQuotas(t *testing.T) {
+	subx := &SubX{
+		HttpClient: &http.Client{
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				res := quotaResponse(http.StatusOK, map[string]string{
+					"X-RateLimit-Limit":     "10",
+					"X-RateLimit-Remaining": "0",
+					"X-RateLimit-Reset":     "60",
+				})
+				res.Body = http.NoBody
+				return res, nil
+			}),
+		},
+		BaseURL: "http://subx.test",
+		Quotas:  NewQuotaTracker(0, time.Hour),
+	}
+
+	_, err := subx.DownloadSubtitle(t.Context(), "api-key", "subtitle-id")
+	require.Error(t, err, "the empty download must fail")
+	assert.False(t, errors.Is(err, ErrRateLimited))
+
+	_, err = subx.DownloadSubtitle(t.Context(), "api-key", "subtitle-id")
+	assert.True(t, errors.Is(err, ErrRateLimited), "expected ErrRateLimited, got %v", err)
+
+	quota, known := subx.Quota("api-key")
+	require.True(t, known)
+	assert.Equal(t, 10, quota.Limit)
+	assert.Equal(t, 0, quota.Remaining)
+}
@@ -26,6 +26,18 @@ const (
 	maxSubtitleArchiveSize = 5 * 1024 * 1024
 	maxSubtitleFileSize    = 500 * 1024
 	maxErrorBodySize       = 4 * 1024
+	// validationIMDBID is the title ValidateAPIKey searches, Breaking Bad, a popular title SubX always has subtitles
+	// for, so a valid key gets a regular response instead of an empty one that could hide an API change.
+	validationIMDBID = "tt0903747"
+)
+
+var (
+	// ErrUnauthorized is returned when SubX rejects the API key.
+	ErrUnauthorized = errors.New("subx api key rejected")
+	// ErrRateLimited is returned when SubX rate limits the API key.
+	ErrRateLimited = errors.New("subx rate limit exceeded")
+	// ErrUnavailable is returned when SubX can't be reached or fails to handle the request.
+	ErrUnavailable = errors.New("subx unavailable")
 )
 
 // Subtitles holds the total number of records and the matching subtitles.
@@ -47,6 +59,20 @@ type Subtitle struct {
 	PostedAt         string
 	Downloads        int
 	DescriptionWords []string
+	// Episodes are the episodes covered, from the SubX metadata or parsed from the title and description.
+	Episodes []int
+	// SeasonPack reports whether the subtitle covers the whole Season.
+	SeasonPack bool
+	// AirDate is the air date of the daily show episode covered, as YYYY-MM-DD.
+	AirDate string
+}
+
+// NewSubtitle fills the fields of the subtitle derived from its metadata, the description words and the episodes
+// covered, and returns it. It's meant for the subtitles built from other sources than the SubX search too.
+func NewSubtitle(subtitle *Subtitle) *Subtitle {
+	subtitle.DescriptionWords = alphaNumericDistinctLowercaseWords(subtitle.Title + " " + subtitle.Description)
+	subtitle.fillEpisodes()
+	return subtitle
 }
 
 // SubtitleContents holds content of a subtitle.
@@ -88,6 +114,33 @@ type SubX struct {
 	HttpClient  *http.Client
 	BaseURL     string
 	SearchLimit int
+	// Quotas tracks the quota of every API key and throttles the requests that would exceed it, nil disables tracking.
+	Quotas *QuotaTracker
+}
+
+// Quota returns the known quota of the API key, false if it's unknown.
+func (s *SubX) Quota(apiKey string) (Quota, bool) {
+	return s.Quotas.Quota(apiKey)
+}
+
+// do sends the request on behalf of the API key, unless its quota is exhausted or the WithReserve function of the request
+// context fails, and tracks the quota reported in the response.
+func (s *SubX) do(req *http.Request, apiKey string) (*http.Response, error) {
+	if err := s.Quotas.Reserve(apiKey); err != nil {
+		return nil, err
+	}
+	if err := reserveFromContext(req.Context()); err != nil {
+		return nil, err
+	}
+
+	res, err := s.HttpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to http.Client.Do: %w", ErrUnavailable, err)
+	}
+
+	s.Quotas.Update(apiKey, res)
+
+	return res, nil
 }
 
 // SearchSubtitles fetches subtitles using explicit SubX search filters.
@@ -127,9 +180,9 @@ func (s *SubX) SearchSubtitles(ctx context.Context, apiKey string, params Search
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Accept", "application/json")
 
-	res, err := s.HttpClient.Do(req)
+	res, err := s.do(req, apiKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to http.Client.Do: %w", err)
+		return nil, err
 	}
 	defer res.Body.Close()
 
@@ -163,26 +216,53 @@ func (s *SubX) SearchSubtitles(ctx context.Context, apiKey string, params Search
 		Subtitles:    make([]*Subtitle, 0, len(subxResponse.Items)),
 	}
 	for _, item := range subxResponse.Items {
-		subtitles.Subtitles = append(subtitles.Subtitles, &Subtitle{
-			ID:               item.ID,
-			VideoType:        item.VideoType,
-			Title:            item.Title,
-			Season:           item.Season,
-			Episode:          item.Episode,
-			IMDBID:           item.IMDBID,
-			Description:      item.Description,
-			UploaderName:     item.UploaderName,
-			PostedAt:         item.PostedAt,
-			Downloads:        item.Downloads,
-			DescriptionWords: alphaNumericDistinctLowercaseWords(item.Title + " " + item.Description),
-		})
+		subtitle := &Subtitle{
+			ID:           item.ID,
+			VideoType:    item.VideoType,
+			Title:        item.Title,
+			Season:       item.Season,
+			Episode:      item.Episode,
+			IMDBID:       item.IMDBID,
+			Description:  item.Description,
+			UploaderName: item.UploaderName,
+			PostedAt:     item.PostedAt,
+			Downloads:    item.Downloads,
+		}
+		subtitles.Subtitles = append(subtitles.Subtitles, NewSubtitle(subtitle))
 	}
 
 	return subtitles, nil
 }
 
+// ValidateAPIKey checks the API key against SubX with the cheapest authenticated call available, a single result search.
+// It returns ErrUnauthorized, ErrRateLimited or ErrUnavailable when SubX can't confirm the key is valid.
+func (s *SubX) ValidateAPIKey(ctx context.Context, apiKey string) error {
+	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("").Start(ctx, "subx.SubX.ValidateAPIKey")
+	defer span.End()
+
+	if apiKey == "" {
+		return ErrUnauthorized
+	}
+
+	_, err := s.SearchSubtitles(ctx, apiKey, SearchParams{
+		IMDBID: validationIMDBID,
+		Limit:  1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subx.SubX.SearchSubtitles: %w", err)
+	}
+
+	return nil
+}
+
 // DownloadSubtitle retrieves a specific subtitle file contents by its ID using the supplied token.
 func (s *SubX) DownloadSubtitle(ctx context.Context, apiKey string, ID string) (*SubtitleContents, error) {
+	return s.DownloadEpisodeSubtitle(ctx, apiKey, ID, 0, 0)
+}
+
+// DownloadEpisodeSubtitle retrieves a specific subtitle file contents by its ID using the supplied token, picking the
+// file of the season and episode from the archives holding several episodes, such as the season packs.
+func (s *SubX) DownloadEpisodeSubtitle(ctx context.Context, apiKey string, ID string, season int, episode int) (*SubtitleContents, error) {
 	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer("").Start(ctx, "subx.SubX.DownloadSubtitle")
 	defer span.End()
 
@@ -201,9 +281,9 @@ func (s *SubX) DownloadSubtitle(ctx context.Context, apiKey string, ID string) (
 	}
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 
-	res, err := s.HttpClient.Do(req)
+	res, err := s.do(req, apiKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to http.Client.Do: %w", err)
+		return nil, err
 	}
 	defer res.Body.Close()
 
@@ -225,7 +305,7 @@ func (s *SubX) DownloadSubtitle(ctx context.Context, apiKey string, ID string) (
 		return nil, errors.New("subtitle download is empty")
 	}
 
-	subtitle, err := extractSubtitle(data, filename)
+	subtitle, err := extractSubtitle(data, filename, season, episode)
 	if err != nil {
 		return nil, err
 	}
@@ -233,7 +313,9 @@ func (s *SubX) DownloadSubtitle(ctx context.Context, apiKey string, ID string) (
 	return subtitle, nil
 }
 
-func extractSubtitle(data []byte, filename string) (*SubtitleContents, error) {
+// extractSubtitle returns the subtitle file of a download, for archives the file named after the season and episode
+// when set, and the first subtitle file otherwise.
+func extractSubtitle(data []byte, filename string, season int, episode int) (*SubtitleContents, error) {
 	if len(data) == 0 {
 		return nil, errors.New("subtitle download is empty")
 	}
@@ -254,6 +336,7 @@ func extractSubtitle(data []byte, filename string) (*SubtitleContents, error) {
 	}
 	defer archive.Close()
 
+	var first *SubtitleContents
 	for {
 		err = archive.Entry()
 		if err != nil {
@@ -283,13 +366,26 @@ func extractSubtitle(data []byte, filename string) (*SubtitleContents, error) {
 			return nil, errors.New("subtitle is empty")
 		}
 
-		return &SubtitleContents{
+		contents := &SubtitleContents{
 			Name: path.Base(name),
 			Data: subtitleData,
-		}, nil
+		}
+		if season == 0 && episode == 0 {
+			return contents, nil
+		}
+		if info, ok := ParseEpisodes(path.Base(name)); ok && !info.SeasonPack && info.Contains(season, episode) {
+			return contents, nil
+		}
+		if first == nil {
+			first = contents
+		}
+	}
+
+	if first == nil {
+		return nil, errors.New("no subtitle file found in archive")
 	}
 
-	return nil, errors.New("no subtitle file found in archive")
+	return first, nil
 }
 
 func downloadFilename(contentDisposition string) string {
@@ -309,13 +405,25 @@ func downloadFilename(contentDisposition string) string {
 	return filename
 }
 
+// invalidStatusError describes an unexpected response, wrapping ErrUnauthorized, ErrRateLimited or ErrUnavailable when the status code maps to them.
 func invalidStatusError(res *http.Response) error {
+	err := fmt.Errorf("invalid status code: %d", res.StatusCode)
+	switch {
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		err = fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	case res.StatusCode == http.StatusTooManyRequests:
+		retryAfter, _ := retryAfterHeader(res.Header, time.Now())
+		err = fmt.Errorf("%w: %w", &RateLimitError{RetryAfter: retryAfter}, err)
+	case res.StatusCode >= http.StatusInternalServerError:
+		err = fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
 	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
 	if len(body) == 0 {
-		return fmt.Errorf("invalid status code: %d", res.StatusCode)
+		return err
 	}
 
-	return fmt.Errorf("invalid status code: %d: %s", res.StatusCode, strings.TrimSpace(string(body)))
+	return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(body)))
 }
 
 func isSubtitle(filename string) bool {
@@ -376,3 +484,99 @@ func (f *Subtitle) Score(s string) int {
 	}
 	return score
 }
+
+// EpisodeInfo returns the episodes the subtitle covers.
+func (f *Subtitle) EpisodeInfo() EpisodeInfo {
+	return EpisodeInfo{Season: f.Season, Episodes: f.Episodes, SeasonPack: f.SeasonPack, AirDate: f.AirDate}
+}
+
+// MatchesEpisode reports whether the subtitle covers the queried episode, see EpisodeInfo.Matches.
+func (f *Subtitle) MatchesEpisode(q EpisodeQuery) bool {
+	return f.EpisodeInfo().Matches(q)
+}
+
+// fillEpisodes sets Episodes, SeasonPack and AirDate from the SubX metadata, parsing the title and description when it
+// lacks the season or the episode. The complete SubX metadata wins, the parsed episodes only extend it to the
+// multi-episode files starting with its episode. SeasonPack is only set by the parsed season packs.
+func (f *Subtitle) fillEpisodes() {
+	info, ok := ParseEpisodes(f.Title)
+	if !ok {
+		info, ok = ParseEpisodes(f.Description)
+	}
+
+	if f.Season > 0 && f.Episode > 0 {
+		f.Episodes = []int{f.Episode}
+		if ok && info.Season == f.Season && len(info.Episodes) > 1 && info.Episodes[0] == f.Episode {
+			f.Episodes = info.Episodes
+		}
+		return
+	}
+
+	if !ok || (f.Season > 0 && info.AirDate == "" && info.Season != f.Season) {
+		// SubX knowing the episode but not the season means it's a special. Knowing the season but not the episode
+		// doesn't tell which episodes are covered, those subtitles are only listed when no episode is queried
+		if f.Season == 0 && f.Episode > 0 {
+			f.Episodes = []int{f.Episode}
+		}
+		return
+	}
+
+	if info.AirDate != "" {
+		f.AirDate = info.AirDate
+		return
+	}
+
+	f.Season, f.Episodes, f.SeasonPack = info.Season, info.Episodes, info.SeasonPack
+	if len(info.Episodes) > 0 {
+		f.Episode = info.Episodes[0]
+	}
+}
+
+// Dialects reported by Subtitle.Dialect.
+const (
+	DialectUnknown = ""
+	DialectLatam   = "latam"
+	DialectSpain   = "spain"
+)
+
+var (
+	latamWords           = []string{"latino", "latinoamerica", "latinoamericano", "latam", "neutro", "mexico", "méxico"}
+	spainWords           = []string{"españa", "espana", "castellano", "castilian", "spain"}
+	hearingImpairedWords = []string{"sdh", "sordos", "hipoacusicos", "hipoacúsicos", "cc"}
+)
+
+// lowercaseWords splits s into its lowercase words, keeping the non ASCII letters unlike alphaNumericDistinctLowercaseWords.
+func lowercaseWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsAnyWord reports whether any of the words of the subtitle title and description is in words.
+func (f *Subtitle) containsAnyWord(words []string) bool {
+	for _, word := range lowercaseWords(f.Title + " " + f.Description) {
+		for _, w := range words {
+			if word == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Dialect guesses the Spanish dialect of the subtitle from its description, DialectUnknown if it's not mentioned.
+func (f *Subtitle) Dialect() string {
+	switch {
+	case f.containsAnyWord(latamWords):
+		return DialectLatam
+	case f.containsAnyWord(spainWords):
+		return DialectSpain
+	default:
+		return DialectUnknown
+	}
+}
+
+// HearingImpaired reports whether the subtitle description mentions it's meant for the hearing impaired.
+func (f *Subtitle) HearingImpaired() bool {
+	return f.containsAnyWord(hearingImpairedWords)
+}
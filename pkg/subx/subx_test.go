@@ -49,7 +49,7 @@ func TestDownloadSubtitleExtractsArchiveWithUnarr(t *testing.T) {
 }
 
 func TestExtractSubtitleFallsBackToRawSubtitle(t *testing.T) {
-	subtitle, err := extractSubtitle([]byte("Mock subtitle content"), "subtitle.srt")
+	subtitle, err := extractSubtitle([]byte("Mock subtitle content"), "subtitle.srt", 0, 0)
 	require.NoError(t, err)
 
 	assert.Equal(t, "subtitle.srt", subtitle.Name)
@@ -86,11 +86,77 @@ func TestExtractSubtitleRejectsOversizedArchivedSubtitle(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, zipWriter.Close())
 
-	_, err = extractSubtitle(archive.Bytes(), "subtitle.zip")
+	_, err = extractSubtitle(archive.Bytes(), "subtitle.zip", 0, 0)
 	require.Error(t, err)
 	assert.True(t, errors.Is(err, ErrReadBeyondLimit), "expected ErrReadBeyondLimit, got %v", err)
 }
 
+func TestValidateAPIKeyMapsFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		transport error
+		wantErr   error
+	}{
+		{"valid", http.StatusOK, nil, nil},
+		{"unauthorized", http.StatusUnauthorized, nil, ErrUnauthorized},
+		{"forbidden", http.StatusForbidden, nil, ErrUnauthorized},
+		{"rate limited", http.StatusTooManyRequests, nil, ErrRateLimited},
+		{"server error", http.StatusBadGateway, nil, ErrUnavailable},
+		{"network error", 0, errors.New("connection refused"), ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subx := &SubX{
+				HttpClient: &http.Client{
+					Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+						if tt.transport != nil {
+							return nil, tt.transport
+						}
+						assert.Equal(t, "Bearer api-key", r.Header.Get("Authorization"))
+						assert.Equal(t, "1", r.URL.Query().Get("limit"))
+						return &http.Response{
+							StatusCode: tt.status,
+							Body:       io.NopCloser(strings.NewReader(`{"items":[],"total":0}`)),
+						}, nil
+					}),
+				},
+				BaseURL: "http://subx.test",
+			}
+
+			err := subx.ValidateAPIKey(context.Background(), "api-key")
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tt.wantErr), "expected %v, got %v", tt.wantErr, err)
+		})
+	}
+}
+
+func TestSubtitleDialectAndHearingImpaired(t *testing.T) {
+	tests := []struct {
+		description     string
+		dialect         string
+		hearingImpaired bool
+	}{
+		{"Versión WEB-DL, español latino", DialectLatam, false},
+		{"Castellano de España, con SDH", DialectSpain, true},
+		{"Subtítulos para sordos (neutro)", DialectLatam, true},
+		{"WEB-DL 1080p sincronizados", DialectUnknown, false},
+		{"Escena del cc-tv corregida", DialectUnknown, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			subtitle := &Subtitle{Description: tt.description}
+			assert.Equal(t, tt.dialect, subtitle.Dialect())
+			assert.Equal(t, tt.hearingImpaired, subtitle.HearingImpaired())
+		})
+	}
+}
+
 type roundTripFunc func(r *http.Request) (*http.Response, error)
 
 func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {